@@ -33,8 +33,9 @@ type Admin interface {
 
 	//kun:op DELETE /services/{serviceName}
 	//kun:op DELETE /routes/{routeName}/service
+	//kun:param cascade in=query
 	//kun:success statusCode=204
-	DeleteService(ctx context.Context, serviceName, routeName string) (err error)
+	DeleteService(ctx context.Context, serviceName, routeName string, cascade bool) (err error)
 
 	//kun:op POST /routes
 	//kun:op POST /services/{serviceName}/routes
@@ -58,8 +59,9 @@ type Admin interface {
 
 	//kun:op DELETE /routes/{routeName}
 	//kun:op DELETE /services/{serviceName}/routes/{routeName}
+	//kun:param cascade in=query
 	//kun:success statusCode=204
-	DeleteRoute(ctx context.Context, serviceName, routeName string) (err error)
+	DeleteRoute(ctx context.Context, serviceName, routeName string, cascade bool) (err error)
 
 	//kun:op POST /plugins
 	//kun:op POST /routes/{routeName}/plugins
@@ -114,4 +116,22 @@ type Admin interface {
 	//kun:op DELETE /services/{serviceName}/upstream
 	//kun:success statusCode=204
 	//DeleteUpstream(ctx context.Context, upstreamName, serviceName string) (err error)
+
+	// RenameService renames a service and rewrites all the references to it.
+	//
+	//kun:op POST /services/{serviceName}/rename
+	//kun:success body=changes
+	RenameService(ctx context.Context, serviceName, newName string) (changes []*olaf.Change, err error)
+
+	// RenameRoute renames a route and rewrites all the references to it.
+	//
+	//kun:op POST /routes/{routeName}/rename
+	//kun:success body=changes
+	RenameRoute(ctx context.Context, routeName, newName string) (changes []*olaf.Change, err error)
+
+	// Batch applies all the operations in order, or none of them if any fails.
+	//
+	//kun:op POST /batch
+	//kun:body ops
+	Batch(ctx context.Context, ops []*olaf.Operation) (err error)
 }
@@ -0,0 +1,672 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/olaf"
+)
+
+// KindAudit is the kind of the audit entries (see NewAuditHandler).
+const KindAudit = "audit"
+
+// RequestIDHeader is the header carrying the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+type contextKeyRequestID struct{}
+
+// NewContextWithRequestID returns a new context that carries requestID.
+func NewContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestID{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(contextKeyRequestID{}).(string)
+	return requestID, ok
+}
+
+// RequestIDMiddleware stores the request ID in the request context (see
+// RequestIDFromContext). The ID is taken from the X-Request-ID header, or
+// generated if absent, and is echoed in the response header.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = olaf.NewID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(NewContextWithRequestID(r.Context(), requestID)))
+	})
+}
+
+// AuditEntry records a change made through the admin API.
+type AuditEntry struct {
+	// The Unix time when the change was made.
+	Time      int64  `json:"time"`
+	Identity  string `json:"identity,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// The operation: "create", "update", "delete" or "rename".
+	Op   string `json:"op"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+
+	// The entity before and after the change, null if not existing.
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+	// The entities changed by a rename.
+	Changes []*olaf.Change `json:"changes,omitempty"`
+
+	// The error message, if the change failed.
+	Error string `json:"error,omitempty"`
+}
+
+// AuditQuery filters the audit entries. Zero fields match all the entries.
+type AuditQuery struct {
+	Identity  string
+	RequestID string
+	Kind      string
+	Name      string
+	// The time range in Unix time, both inclusive.
+	Since int64
+	Until int64
+	// The maximum number of the (most recent) entries to return.
+	Limit int
+}
+
+func (q *AuditQuery) matches(e *AuditEntry) bool {
+	switch {
+	case q.Identity != "" && e.Identity != q.Identity,
+		q.RequestID != "" && e.RequestID != q.RequestID,
+		q.Kind != "" && e.Kind != q.Kind,
+		q.Name != "" && e.Name != q.Name,
+		q.Since != 0 && e.Time < q.Since,
+		q.Until != 0 && e.Time > q.Until:
+		return false
+	}
+	return true
+}
+
+// AuditLog stores the audit entries.
+type AuditLog interface {
+	Write(entry *AuditEntry) error
+	// Query returns the matched entries in chronological order.
+	Query(q *AuditQuery) ([]*AuditEntry, error)
+}
+
+// AuditFile is an AuditLog writing the entries to a local file, one JSON
+// object per line. Once the file exceeds maxSize bytes, it is rotated to
+// <filename>.1 (shifting the older ones to <filename>.2 and so on), and at
+// most maxBackups rotated files are kept.
+type AuditFile struct {
+	filename   string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenAuditFile opens (or creates) the audit file for appending.
+func OpenAuditFile(filename string, maxSize int64, maxBackups int) (*AuditFile, error) {
+	a := &AuditFile{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditFile) open() error {
+	f, err := os.OpenFile(a.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	a.f, a.size = f, info.Size()
+	return nil
+}
+
+func (a *AuditFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", a.filename, i)
+}
+
+func (a *AuditFile) rotate() error {
+	if err := a.f.Close(); err != nil {
+		return err
+	}
+	if a.maxBackups > 0 {
+		os.Remove(a.backup(a.maxBackups)) // nolint:errcheck
+		for i := a.maxBackups - 1; i > 0; i-- {
+			os.Rename(a.backup(i), a.backup(i+1)) // nolint:errcheck
+		}
+		if err := os.Rename(a.filename, a.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(a.filename); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+func (a *AuditFile) Write(entry *AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.maxSize > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := a.f.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+func (a *AuditFile) Query(q *AuditQuery) ([]*AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var entries []*AuditEntry
+	// Read from the oldest file to the newest one.
+	for i := a.maxBackups; i >= 0; i-- {
+		filename := a.filename
+		if i > 0 {
+			filename = a.backup(i)
+		}
+		matched, err := readAuditFile(filename, q)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, matched...)
+	}
+
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries, nil
+}
+
+func (a *AuditFile) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f.Close()
+}
+
+func readAuditFile(filename string, q *AuditQuery) ([]*AuditEntry, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		e := new(AuditEntry)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// NewAuditHandler returns a handler serving the audit entries, which are
+// filtered by the query parameters identity, request_id, kind, name, since,
+// until (in Unix time) and limit. If policy is not nil, the caller must be
+// allowed to read the kind "audit".
+func NewAuditHandler(auditLog AuditLog, policy *Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy != nil {
+			identity, ok := IdentityFromContext(r.Context())
+			if !ok {
+				Codec{}.EncodeFailureResponse(w, ErrUnauthenticated) // nolint:errcheck
+				return
+			}
+			if err := policy.Authorize(identity, VerbRead, KindAudit, "", nil); err != nil {
+				Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+				return
+			}
+		}
+
+		params := r.URL.Query()
+		q := &AuditQuery{
+			Identity:  params.Get("identity"),
+			RequestID: params.Get("request_id"),
+			Kind:      params.Get("kind"),
+			Name:      params.Get("name"),
+		}
+		for _, p := range []struct {
+			field string
+			v     *int64
+		}{{"since", &q.Since}, {"until", &q.Until}} {
+			if s := params.Get(p.field); s != "" {
+				i, err := strconv.ParseInt(s, 10, 64)
+				if err != nil {
+					Codec{}.EncodeFailureResponse(w, olaf.InvalidField(KindAudit, p.field, "must be a Unix time")) // nolint:errcheck
+					return
+				}
+				*p.v = i
+			}
+		}
+		if s := params.Get("limit"); s != "" {
+			limit, err := strconv.Atoi(s)
+			if err != nil || limit < 0 {
+				Codec{}.EncodeFailureResponse(w, olaf.InvalidField(KindAudit, "limit", "must be a non-negative integer")) // nolint:errcheck
+				return
+			}
+			q.Limit = limit
+		}
+
+		entries, err := auditLog.Query(q)
+		if err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+		if entries == nil {
+			entries = []*AuditEntry{}
+		}
+		Codec{}.EncodeSuccessResponse(w, http.StatusOK, entries) // nolint:errcheck
+	})
+}
+
+// NewAudit returns an Admin, which records every change made through next
+// into auditLog. Reads are not recorded.
+//
+// The identity and the request ID are got from the context (see AuthMiddleware
+// and RequestIDMiddleware). For a batch, one entry is recorded per operation,
+// and the before/after states are the ones before/after the whole batch. The
+// dependents deleted in cascade are recorded as well.
+//
+// To record the calls denied by RBAC, wrap the one returned by NewRBAC (the
+// before/after states are then only recorded if the caller can read them).
+func NewAudit(auditLog AuditLog, next Admin) Admin {
+	return &audit{log: auditLog, next: next}
+}
+
+type audit struct {
+	log  AuditLog
+	next Admin
+}
+
+var auditNow = func() int64 { return time.Now().Unix() }
+
+func (a *audit) record(ctx context.Context, op, kind, name string, before, after interface{}, changes []*olaf.Change, err error) {
+	e := &AuditEntry{
+		Time:    auditNow(),
+		Op:      op,
+		Kind:    kind,
+		Name:    name,
+		Before:  marshalEntity(before),
+		After:   marshalEntity(after),
+		Changes: changes,
+	}
+	e.Identity, _ = IdentityFromContext(ctx)
+	e.RequestID, _ = RequestIDFromContext(ctx)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := a.log.Write(e); err != nil {
+		// The change has already been made, so just report the failure.
+		log.Printf("audit: failed to write entry: %v", err)
+	}
+}
+
+func marshalEntity(v interface{}) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+// after returns the entity of the given kind and name (or ID) after a change,
+// or nil if the change failed.
+func (a *audit) after(ctx context.Context, kind, name string, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return a.get(ctx, kind, name)
+}
+
+// dependents returns the dependents of the entity of the given kind and name,
+// which will be deleted along with the entity if cascade is true.
+func (a *audit) dependents(ctx context.Context, kind, name string, cascade bool) (deps []interface{}) {
+	if !cascade {
+		return nil
+	}
+	data, err := a.next.GetConfig(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, ref := range olaf.FindDependents(data, kind, name) {
+		switch ref.Kind {
+		case olaf.KindRoute:
+			deps = append(deps, data.Routes[ref.Name])
+		case olaf.KindPlugin:
+			deps = append(deps, data.Plugins[ref.Name])
+		}
+	}
+	return deps
+}
+
+// recordDependents records the deletions of the dependents, if the entity,
+// which they depend on, has been deleted successfully.
+func (a *audit) recordDependents(ctx context.Context, deps []interface{}, err error) {
+	if err != nil {
+		return
+	}
+	for _, dep := range deps {
+		a.record(ctx, olaf.OpDelete, entityKind(dep), entityName(dep), dep, nil, nil, nil)
+	}
+}
+
+// get returns the entity of the given kind and name (or ID), or nil if not
+// found.
+func (a *audit) get(ctx context.Context, kind, name string) interface{} {
+	var (
+		v   interface{}
+		err error
+	)
+	switch kind {
+	case olaf.KindService:
+		v, err = a.next.GetService(ctx, name, "")
+	case olaf.KindRoute:
+		v, err = a.next.GetRoute(ctx, "", name)
+	case olaf.KindPlugin:
+		v, err = a.next.GetPlugin(ctx, "", "", name)
+	case olaf.KindUpstream:
+		v, err = a.next.GetUpstream(ctx, name, "")
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+func (a *audit) GetConfig(ctx context.Context) (data *olaf.Data, err error) {
+	return a.next.GetConfig(ctx)
+}
+
+func (a *audit) CreateService(ctx context.Context, svc *olaf.Service) (err error) {
+	err = a.next.CreateService(ctx, svc)
+	a.record(ctx, olaf.OpCreate, olaf.KindService, svc.Name, nil, a.after(ctx, olaf.KindService, svc.Name, err), nil, err)
+	return err
+}
+
+func (a *audit) ListServices(ctx context.Context) (services []*olaf.Service, err error) {
+	return a.next.ListServices(ctx)
+}
+
+func (a *audit) GetService(ctx context.Context, serviceName, routeName string) (service *olaf.Service, err error) {
+	return a.next.GetService(ctx, serviceName, routeName)
+}
+
+func (a *audit) UpdateService(ctx context.Context, serviceName, routeName string, svc *olaf.Service) (err error) {
+	name := serviceName
+	var before interface{}
+	if old, err := a.next.GetService(ctx, serviceName, routeName); err == nil {
+		name, before = old.Name, old
+	}
+	err = a.next.UpdateService(ctx, serviceName, routeName, svc)
+	a.record(ctx, olaf.OpUpdate, olaf.KindService, name, before, a.after(ctx, olaf.KindService, name, err), nil, err)
+	return err
+}
+
+func (a *audit) DeleteService(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	name := serviceName
+	var before interface{}
+	if old, err := a.next.GetService(ctx, serviceName, routeName); err == nil {
+		name, before = old.Name, old
+	}
+	deps := a.dependents(ctx, olaf.KindService, name, cascade)
+	err = a.next.DeleteService(ctx, serviceName, routeName, cascade)
+	a.record(ctx, olaf.OpDelete, olaf.KindService, name, before, nil, nil, err)
+	a.recordDependents(ctx, deps, err)
+	return err
+}
+
+func (a *audit) CreateRoute(ctx context.Context, serviceName string, route *olaf.Route) (err error) {
+	err = a.next.CreateRoute(ctx, serviceName, route)
+	a.record(ctx, olaf.OpCreate, olaf.KindRoute, route.Name, nil, a.after(ctx, olaf.KindRoute, route.Name, err), nil, err)
+	return err
+}
+
+func (a *audit) ListRoutes(ctx context.Context, serviceName string) (routes []*olaf.Route, err error) {
+	return a.next.ListRoutes(ctx, serviceName)
+}
+
+func (a *audit) GetRoute(ctx context.Context, serviceName, routeName string) (route *olaf.Route, err error) {
+	return a.next.GetRoute(ctx, serviceName, routeName)
+}
+
+func (a *audit) UpdateRoute(ctx context.Context, serviceName, routeName string, route *olaf.Route) (err error) {
+	name := routeName
+	var before interface{}
+	if old, err := a.next.GetRoute(ctx, serviceName, routeName); err == nil {
+		name, before = old.Name, old
+	}
+	err = a.next.UpdateRoute(ctx, serviceName, routeName, route)
+	a.record(ctx, olaf.OpUpdate, olaf.KindRoute, name, before, a.after(ctx, olaf.KindRoute, name, err), nil, err)
+	return err
+}
+
+func (a *audit) DeleteRoute(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	name := routeName
+	var before interface{}
+	if old, err := a.next.GetRoute(ctx, serviceName, routeName); err == nil {
+		name, before = old.Name, old
+	}
+	deps := a.dependents(ctx, olaf.KindRoute, name, cascade)
+	err = a.next.DeleteRoute(ctx, serviceName, routeName, cascade)
+	a.record(ctx, olaf.OpDelete, olaf.KindRoute, name, before, nil, nil, err)
+	a.recordDependents(ctx, deps, err)
+	return err
+}
+
+func (a *audit) CreatePlugin(ctx context.Context, serviceName, routeName string, p *olaf.Plugin) (plugin *olaf.Plugin, err error) {
+	plugin, err = a.next.CreatePlugin(ctx, serviceName, routeName, p)
+	name := p.Name
+	var after interface{}
+	if err == nil {
+		name, after = plugin.Name, plugin
+	}
+	a.record(ctx, olaf.OpCreate, olaf.KindPlugin, name, nil, after, nil, err)
+	return plugin, err
+}
+
+func (a *audit) ListPlugins(ctx context.Context, serviceName, routeName string) (plugins []*olaf.Plugin, err error) {
+	return a.next.ListPlugins(ctx, serviceName, routeName)
+}
+
+func (a *audit) GetPlugin(ctx context.Context, serviceName, routeName, pluginName string) (plugin *olaf.Plugin, err error) {
+	return a.next.GetPlugin(ctx, serviceName, routeName, pluginName)
+}
+
+func (a *audit) UpdatePlugin(ctx context.Context, serviceName, routeName, pluginName string, plugin *olaf.Plugin) (err error) {
+	name := pluginName
+	var before interface{}
+	if old, err := a.next.GetPlugin(ctx, serviceName, routeName, pluginName); err == nil {
+		name, before = old.Name, old
+	}
+	err = a.next.UpdatePlugin(ctx, serviceName, routeName, pluginName, plugin)
+	a.record(ctx, olaf.OpUpdate, olaf.KindPlugin, name, before, a.after(ctx, olaf.KindPlugin, name, err), nil, err)
+	return err
+}
+
+func (a *audit) DeletePlugin(ctx context.Context, serviceName, routeName, pluginName string) (err error) {
+	name := pluginName
+	var before interface{}
+	if old, err := a.next.GetPlugin(ctx, serviceName, routeName, pluginName); err == nil {
+		name, before = old.Name, old
+	}
+	err = a.next.DeletePlugin(ctx, serviceName, routeName, pluginName)
+	a.record(ctx, olaf.OpDelete, olaf.KindPlugin, name, before, nil, nil, err)
+	return err
+}
+
+func (a *audit) ListUpstreams(ctx context.Context) (upstreams []*olaf.Upstream, err error) {
+	return a.next.ListUpstreams(ctx)
+}
+
+func (a *audit) GetUpstream(ctx context.Context, upstreamName, serviceName string) (upstream *olaf.Upstream, err error) {
+	return a.next.GetUpstream(ctx, upstreamName, serviceName)
+}
+
+func (a *audit) UpdateUpstream(ctx context.Context, upstreamName, serviceName string, upstream *olaf.Upstream) (err error) {
+	name := upstreamName
+	if name == "" {
+		name = serviceName
+	}
+	var before interface{}
+	if old, err := a.next.GetUpstream(ctx, upstreamName, serviceName); err == nil {
+		name, before = old.ID, old
+	}
+	err = a.next.UpdateUpstream(ctx, upstreamName, serviceName, upstream)
+	var after interface{}
+	if err == nil {
+		after, _ = a.next.GetUpstream(ctx, upstreamName, serviceName)
+	}
+	a.record(ctx, olaf.OpUpdate, olaf.KindUpstream, name, before, after, nil, err)
+	return err
+}
+
+func (a *audit) RenameService(ctx context.Context, serviceName, newName string) (changes []*olaf.Change, err error) {
+	name := serviceName
+	var before interface{}
+	if old, err := a.next.GetService(ctx, serviceName, ""); err == nil {
+		name, before = old.Name, old
+	}
+	changes, err = a.next.RenameService(ctx, serviceName, newName)
+	a.record(ctx, olaf.OpRename, olaf.KindService, name, before, a.after(ctx, olaf.KindService, newName, err), changes, err)
+	return changes, err
+}
+
+func (a *audit) RenameRoute(ctx context.Context, routeName, newName string) (changes []*olaf.Change, err error) {
+	name := routeName
+	var before interface{}
+	if old, err := a.next.GetRoute(ctx, "", routeName); err == nil {
+		name, before = old.Name, old
+	}
+	changes, err = a.next.RenameRoute(ctx, routeName, newName)
+	a.record(ctx, olaf.OpRename, olaf.KindRoute, name, before, a.after(ctx, olaf.KindRoute, newName, err), changes, err)
+	return changes, err
+}
+
+func (a *audit) Batch(ctx context.Context, ops []*olaf.Operation) (err error) {
+	names := make([]string, len(ops))
+	befores := make([]interface{}, len(ops))
+	deps := make([][]interface{}, len(ops))
+	for i, op := range ops {
+		names[i] = op.Name
+		if op.Op == olaf.OpCreate {
+			continue
+		}
+		if before := a.get(ctx, op.Kind, op.Name); before != nil {
+			befores[i] = before
+			names[i] = entityName(before)
+		}
+		if op.Op == olaf.OpDelete {
+			deps[i] = a.dependents(ctx, op.Kind, names[i], op.Cascade)
+		}
+	}
+
+	err = a.next.Batch(ctx, ops)
+
+	for i, op := range ops {
+		// The names of the created entities are got afterwards, since they
+		// may be generated by next (e.g. the ones of plugins).
+		if op.Op == olaf.OpCreate {
+			names[i] = createdName(op)
+		}
+		afterName := names[i]
+		if op.Op == olaf.OpRename {
+			afterName = op.NewName
+		}
+		var after interface{}
+		if op.Op != olaf.OpDelete {
+			after = a.after(ctx, op.Kind, afterName, err)
+		}
+		a.record(ctx, op.Op, op.Kind, names[i], befores[i], after, nil, err)
+		a.recordDependents(ctx, deps[i], err)
+	}
+	return err
+}
+
+// entityName returns the name of the entity got by audit.get.
+func entityName(v interface{}) string {
+	switch e := v.(type) {
+	case *olaf.Service:
+		return e.Name
+	case *olaf.Route:
+		return e.Name
+	case *olaf.Plugin:
+		return e.Name
+	case *olaf.Upstream:
+		return e.ID
+	}
+	return ""
+}
+
+// entityKind returns the kind of the entity got by audit.get.
+func entityKind(v interface{}) string {
+	switch v.(type) {
+	case *olaf.Service:
+		return olaf.KindService
+	case *olaf.Route:
+		return olaf.KindRoute
+	case *olaf.Plugin:
+		return olaf.KindPlugin
+	case *olaf.Upstream:
+		return olaf.KindUpstream
+	}
+	return ""
+}
+
+// createdName returns the name of the entity to be created by op.
+func createdName(op *olaf.Operation) string {
+	switch {
+	case op.Kind == olaf.KindService && op.Service != nil:
+		return op.Service.Name
+	case op.Kind == olaf.KindRoute && op.Route != nil:
+		return op.Route.Name
+	case op.Kind == olaf.KindPlugin && op.Plugin != nil:
+		return op.Plugin.Name
+	}
+	return op.Name
+}
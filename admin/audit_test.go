@@ -0,0 +1,257 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+func TestAudit(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	writeFile(t, configFile, testRBACConfig)
+
+	// Keep the file small enough to be rotated on every write.
+	auditLog, err := OpenAuditFile(filepath.Join(dir, "audit.log"), 1, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer auditLog.Close()
+
+	a := NewAudit(auditLog, yaml.New(configFile))
+	ctx := NewContextWithRequestID(NewContextWithIdentity(context.Background(), "alice"), "req-1")
+
+	if err := a.UpdateRoute(ctx, "", "web", &olaf.Route{Matcher: olaf.Matcher{Paths: []string{"/web2"}}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := a.RenameService(ctx, "billing", "invoice"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := a.DeleteService(ctx, "team-a-web", "", false); err == nil {
+		t.Fatalf("err: got (<nil>), want (non-nil)")
+	}
+
+	cases := []struct {
+		name      string
+		in        *AuditQuery
+		wantNames []string
+	}{
+		{
+			name:      "all (across the rotated files)",
+			in:        &AuditQuery{},
+			wantNames: []string{"web", "billing", "team-a-web"},
+		},
+		{
+			name:      "by kind",
+			in:        &AuditQuery{Kind: olaf.KindService},
+			wantNames: []string{"billing", "team-a-web"},
+		},
+		{
+			name:      "by limit",
+			in:        &AuditQuery{Limit: 1},
+			wantNames: []string{"team-a-web"},
+		},
+		{
+			name: "by identity",
+			in:   &AuditQuery{Identity: "bob"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entries, err := auditLog.Query(c.in)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name)
+			}
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Fatalf("Names: got (%v), want (%v)", names, c.wantNames)
+			}
+		})
+	}
+
+	entries, _ := auditLog.Query(&AuditQuery{Name: "web"})
+	e := entries[0]
+	if e.Identity != "alice" || e.RequestID != "req-1" || e.Op != olaf.OpUpdate || e.Error != "" {
+		t.Fatalf("Entry: got (%+v)", e)
+	}
+	var before, after olaf.Route
+	if err := json.Unmarshal(e.Before, &before); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := json.Unmarshal(e.After, &after); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if before.Paths[0] != "/web" || after.Paths[0] != "/web2" {
+		t.Fatalf("Paths: got (%v -> %v), want (/web -> /web2)", before.Paths, after.Paths)
+	}
+
+	entries, _ = auditLog.Query(&AuditQuery{Name: "team-a-web"})
+	if entries[0].Error == "" || string(entries[0].Before) == "null" || string(entries[0].After) != "null" {
+		t.Fatalf("Entry: got (%+v), want a failed entry with no after state", entries[0])
+	}
+}
+
+func TestAudit_Batch(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	writeFile(t, configFile, testRBACConfig)
+
+	auditLog, err := OpenAuditFile(filepath.Join(dir, "audit.log"), 0, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer auditLog.Close()
+
+	store := yaml.New(configFile)
+	a := NewAudit(auditLog, store)
+	ctx := NewContextWithIdentity(context.Background(), "alice")
+
+	_, err = store.CreatePlugin(ctx, "", "web", &olaf.Plugin{Name: "limit", Type: "rate_limit"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	err = a.Batch(ctx, []*olaf.Operation{
+		{
+			Op:     olaf.OpCreate,
+			Kind:   olaf.KindPlugin,
+			Plugin: &olaf.Plugin{Type: "rate_limit", ServiceName: "team-b-web"},
+		},
+		{Op: olaf.OpDelete, Kind: olaf.KindService, Name: "team-a-web", Cascade: true},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	entries, err := auditLog.Query(&AuditQuery{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Op+" "+e.Kind+" "+e.Name)
+	}
+	want := []string{
+		"create plugin team-b-web_plugin_0",
+		"delete service team-a-web",
+		"delete route web",
+		"delete plugin limit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Entries: got (%v), want (%v)", got, want)
+	}
+}
+
+func TestAuditHandler(t *testing.T) {
+	auditLog, err := OpenAuditFile(filepath.Join(t.TempDir(), "audit.log"), 0, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer auditLog.Close()
+
+	for _, e := range []*AuditEntry{
+		{Time: 100, Identity: "alice", Op: olaf.OpCreate, Kind: olaf.KindService, Name: "a"},
+		{Time: 200, Identity: "bob", Op: olaf.OpDelete, Kind: olaf.KindService, Name: "b"},
+	} {
+		if err := auditLog.Write(e); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	server := httptest.NewServer(NewAuditHandler(auditLog, nil))
+	defer server.Close()
+
+	cases := []struct {
+		name       string
+		inQuery    string
+		wantStatus int
+		wantNames  []string
+	}{
+		{
+			name:       "by since",
+			inQuery:    "?since=150",
+			wantStatus: http.StatusOK,
+			wantNames:  []string{"b"},
+		},
+		{
+			name:       "by identity",
+			inQuery:    "?identity=alice",
+			wantStatus: http.StatusOK,
+			wantNames:  []string{"a"},
+		},
+		{
+			name:       "invalid until",
+			inQuery:    "?until=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + c.inQuery)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", resp.StatusCode, c.wantStatus)
+			}
+			if c.wantStatus != http.StatusOK {
+				return
+			}
+
+			var entries []*AuditEntry
+			if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name)
+			}
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Fatalf("Names: got (%v), want (%v)", names, c.wantNames)
+			}
+		})
+	}
+}
+
+func TestAudit_Denied(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	policyFile := filepath.Join(dir, "policy.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	writeFile(t, policyFile, testPolicy)
+
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	auditLog, err := OpenAuditFile(filepath.Join(dir, "audit.log"), 0, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer auditLog.Close()
+
+	a := NewAudit(auditLog, NewRBAC(policy, yaml.New(configFile)))
+	ctx := NewContextWithIdentity(context.Background(), "bob")
+	if err := a.DeleteService(ctx, "billing", "", false); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Err: got (%v), want (%v)", err, ErrForbidden)
+	}
+
+	entries, _ := auditLog.Query(&AuditQuery{Identity: "bob"})
+	if len(entries) != 1 || entries[0].Name != "billing" || entries[0].Error == "" {
+		t.Fatalf("Entries: got (%+v), want a failed entry of billing", entries)
+	}
+}
@@ -0,0 +1,201 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator authenticates the caller of a request.
+type Authenticator interface {
+	// Authenticate returns the identity of the caller. If the request carries
+	// no credential known to the authenticator, ErrUnauthenticated is returned.
+	Authenticate(r *http.Request) (identity string, err error)
+}
+
+// AuthenticatorFunc is an adapter to allow the use of ordinary functions as
+// Authenticators.
+type AuthenticatorFunc func(r *http.Request) (string, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (string, error) { return f(r) }
+
+// Authenticators tries the authenticators in order, and the first one that
+// succeeds wins.
+type Authenticators []Authenticator
+
+func (a Authenticators) Authenticate(r *http.Request) (string, error) {
+	for _, authn := range a {
+		identity, err := authn.Authenticate(r)
+		if err == nil {
+			return identity, nil
+		}
+		if !errors.Is(err, ErrUnauthenticated) {
+			return "", err
+		}
+	}
+	return "", ErrUnauthenticated
+}
+
+type contextKeyIdentity struct{}
+
+// NewContextWithIdentity returns a new context that carries identity.
+func NewContextWithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, contextKeyIdentity{}, identity)
+}
+
+// IdentityFromContext returns the identity of the caller stored in ctx, if any.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(contextKeyIdentity{}).(string)
+	return identity, ok
+}
+
+// AuthMiddleware returns a middleware, which rejects the requests that can not
+// be authenticated by authn. For authenticated requests, the identity of the
+// caller is stored in the request context (see IdentityFromContext).
+func AuthMiddleware(authn Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, err := authn.Authenticate(r)
+			if err != nil {
+				Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContextWithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+// APIKeyHeader is the header carrying the API key. The key can also be
+// carried as a bearer token in the Authorization header.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by static API keys. It maps keys to the
+// identities of their owners.
+type APIKeys map[string]string
+
+// LoadAPIKeys loads API keys from a file, each line of which is in the form
+// of `<identity>:<key>`. Empty lines and lines starting with `#` are ignored.
+func LoadAPIKeys(filename string) (APIKeys, error) {
+	keys := make(APIKeys)
+	err := readColonFile(filename, func(identity, key string) {
+		keys[key] = identity
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (a APIKeys) Authenticate(r *http.Request) (string, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return "", ErrUnauthenticated
+		}
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	// Compare all the keys in constant time to avoid leaking timing info.
+	var identity string
+	for k, id := range a {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			identity = id
+		}
+	}
+	if identity == "" {
+		return "", ErrUnauthenticated
+	}
+	return identity, nil
+}
+
+// Htpasswd authenticates requests by HTTP basic auth, against the users in
+// an htpasswd file. Only bcrypt and SHA-1 (`{SHA}`) hashes are supported.
+type Htpasswd map[string]string
+
+// LoadHtpasswd loads users from an htpasswd file.
+func LoadHtpasswd(filename string) (Htpasswd, error) {
+	users := make(Htpasswd)
+	err := readColonFile(filename, func(username, hash string) {
+		users[username] = hash
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (h Htpasswd) Authenticate(r *http.Request) (string, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+	hash, ok := h[username]
+	if !ok || !checkPassword(hash, password) {
+		return "", ErrUnauthenticated
+	}
+	return username, nil
+}
+
+func checkPassword(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		want := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(hash, "{SHA}")), []byte(want)) == 1
+	default:
+		return false
+	}
+}
+
+// ClientCert authenticates requests by the verified TLS client certificates
+// (i.e. mTLS). The identity is the common name of the certificate subject.
+//
+// The server must be configured to verify client certificates, see
+// tls.Config.ClientAuth and tls.Config.ClientCAs.
+var ClientCert = AuthenticatorFunc(func(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", ErrUnauthenticated
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if cn == "" {
+		return "", ErrUnauthenticated
+	}
+	return cn, nil
+})
+
+// readColonFile reads a file, each line of which is in the form of `a:b`.
+func readColonFile(filename string, f func(a, b string)) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("%s:%d: malformed line", filename, n)
+		}
+		f(parts[0], parts[1])
+	}
+	return scanner.Err()
+}
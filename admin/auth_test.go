@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	authn := Authenticators{
+		APIKeys{"secret": "alice"},
+		// The password of bob is "password".
+		Htpasswd{"bob": "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g="},
+	}
+	handler := AuthMiddleware(authn)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		fmt.Fprint(w, identity)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	cases := []struct {
+		name         string
+		inCreds      []Credential
+		wantStatus   int
+		wantIdentity string
+	}{
+		{
+			name:       "no credential",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "valid API key",
+			inCreds:      []Credential{WithAPIKey("secret")},
+			wantStatus:   http.StatusOK,
+			wantIdentity: "alice",
+		},
+		{
+			name:       "invalid API key",
+			inCreds:    []Credential{WithAPIKey("guess")},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "valid basic auth",
+			inCreds:      []Credential{WithBasicAuth("bob", "password")},
+			wantStatus:   http.StatusOK,
+			wantIdentity: "bob",
+		},
+		{
+			name:       "invalid basic auth",
+			inCreds:    []Credential{WithBasicAuth("bob", "guess")},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := NewAuthHTTPClient(server.Client(), c.inCreds...)
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", resp.StatusCode, c.wantStatus)
+			}
+
+			if c.wantStatus != http.StatusOK {
+				e, err := decodeError(resp.Body)
+				if err != nil {
+					t.Fatalf("err: %v", err)
+				}
+				if !errors.Is(e, ErrUnauthenticated) {
+					t.Fatalf("Err: got (%v), want (%v)", e, ErrUnauthenticated)
+				}
+				return
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != c.wantIdentity {
+				t.Fatalf("Identity: got (%s), want (%s)", body, c.wantIdentity)
+			}
+		})
+	}
+}
@@ -1,35 +1,208 @@
 package admin
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/RussellLuo/kun/pkg/httpcodec"
 	"github.com/RussellLuo/olaf"
+	"gopkg.in/yaml.v3"
 )
 
+// MediaTypeYAML is the media type of YAML. The other common ones, such as
+// "application/x-yaml" and "text/yaml", are also accepted.
+const MediaTypeYAML = "application/yaml"
+
+var yamlMediaTypes = []string{MediaTypeYAML, "application/x-yaml", "text/yaml", "text/x-yaml"}
+
+// Codec encodes and decodes JSON by default, or YAML if requested.
+//
+// A request body is decoded as YAML if its Content-Type is YAML. A response
+// body is encoded as YAML if the client prefers YAML in the Accept header
+// (see NegotiationMiddleware). The YAML documents have the same fields as the
+// JSON ones, except for the config (see Admin.GetConfig) if MarshalConfig is
+// specified.
 type Codec struct {
 	httpcodec.JSON
+
+	// MarshalConfig, if not nil, encodes the config as YAML, e.g. in the
+	// declarative layout of the YAML store (see yaml.Marshal).
+	MarshalConfig func(data *olaf.Data) ([]byte, error)
+}
+
+// DecodeRequestBody decodes the request body as JSON or YAML, according to
+// the Content-Type header.
+func (c Codec) DecodeRequestBody(r *http.Request, out interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !containsString(yamlMediaTypes, mediaType) {
+		return c.JSON.DecodeRequestBody(r, out)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	// Convert YAML to JSON, to honor the JSON field names.
+	var v interface{}
+	if err := yaml.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("%w: %v", olaf.ErrInvalidOperation, err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", olaf.ErrInvalidOperation, err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("%w: %v", olaf.ErrInvalidOperation, err)
+	}
+	return nil
+}
+
+// EncodeSuccessResponse encodes body as JSON or YAML, according to the
+// negotiated media type.
+func (c Codec) EncodeSuccessResponse(w http.ResponseWriter, statusCode int, body interface{}) error {
+	if !isYAML(w) {
+		return c.JSON.EncodeSuccessResponse(w, statusCode, body)
+	}
+
+	var (
+		out []byte
+		err error
+	)
+	if data, ok := body.(*olaf.Data); ok && c.MarshalConfig != nil {
+		out, err = c.MarshalConfig(data)
+	} else {
+		out, err = marshalYAML(body)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", MediaTypeYAML)
+	w.WriteHeader(statusCode)
+	_, err = w.Write(out)
+	return err
+}
+
+// marshalYAML encodes v as YAML, with the same fields (in the same order) as
+// its JSON encoding.
+func marshalYAML(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is a subset of YAML, so it can be decoded into a node directly.
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// blockStyle changes the flow style of JSON into the block style of YAML,
+// where strings are quoted only if necessary.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		blockStyle(n)
+	}
 }
 
+// EncodeFailureResponse encodes err as a structured Error.
 func (c Codec) EncodeFailureResponse(w http.ResponseWriter, err error) error {
-	return c.JSON.EncodeSuccessResponse(w, codeFrom(err), map[string]string{
-		"error": err.Error(),
-	})
+	e := NewError(err)
+	return c.EncodeSuccessResponse(w, e.Status, e)
+}
+
+// DecodeFailureResponse decodes the structured Error from body.
+func (c Codec) DecodeFailureResponse(body io.ReadCloser, out *error) error {
+	e, err := decodeError(body)
+	if err != nil {
+		return err
+	}
+	*out = e
+	return nil
+}
+
+func NewCodecs(codec Codec) *httpcodec.DefaultCodecs {
+	return httpcodec.NewDefaultCodecs(codec)
+}
+
+// yamlResponseWriter marks the response to be encoded as YAML.
+type yamlResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Flush implements http.Flusher, for streaming responses (see
+// NewEventsHandler).
+func (w *yamlResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
-func codeFrom(err error) int {
-	switch err {
-	case olaf.ErrServiceExists, olaf.ErrRouteExists, olaf.ErrPluginExists:
-		return http.StatusBadRequest
-	case olaf.ErrServiceNotFound, olaf.ErrRouteNotFound, olaf.ErrPluginNotFound:
-		return http.StatusNotFound
-	case olaf.ErrMethodNotImplemented:
-		return http.StatusMethodNotAllowed
-	default:
-		return http.StatusInternalServerError
+// isYAML reports whether w, or any ResponseWriter it wraps (by an Unwrap
+// method), is marked by NegotiationMiddleware.
+func isYAML(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *yamlResponseWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
 	}
 }
 
-func NewCodecs() *httpcodec.DefaultCodecs {
-	return httpcodec.NewDefaultCodecs(Codec{})
+// NegotiationMiddleware returns a middleware, which makes Codec encode the
+// responses as YAML if the client prefers YAML to JSON in the Accept header.
+func NegotiationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if prefersYAML(r.Header.Get("Accept")) {
+			w = &yamlResponseWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// prefersYAML reports whether the media ranges in accept prefer YAML to JSON.
+// The wildcards, which mean JSON, take precedence over YAML only if they have
+// higher qualities.
+func prefersYAML(accept string) bool {
+	var yamlQ, jsonQ, wildcardQ float64 = -1, -1, -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case containsString(yamlMediaTypes, mediaType):
+			if q > yamlQ {
+				yamlQ = q
+			}
+		case mediaType == "application/json":
+			if q > jsonQ {
+				jsonQ = q
+			}
+		case mediaType == "*/*" || mediaType == "application/*":
+			if q > wildcardQ {
+				wildcardQ = q
+			}
+		}
+	}
+	return yamlQ > 0 && yamlQ > jsonQ && yamlQ >= wildcardQ
 }
@@ -0,0 +1,198 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+	olafyaml "github.com/RussellLuo/olaf/store/yaml"
+)
+
+func TestPrefersYAML(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{in: "", want: false},
+		{in: "application/json", want: false},
+		{in: "application/yaml", want: true},
+		{in: "text/yaml", want: true},
+		{in: "application/yaml, */*", want: true},
+		{in: "application/yaml;q=0.5, */*", want: false},
+		{in: "application/json;q=0.5, application/x-yaml", want: true},
+		{in: "application/yaml;q=0.5, application/json", want: false},
+		{in: "application/yaml;q=0", want: false},
+	}
+
+	for _, c := range cases {
+		if got := prefersYAML(c.in); got != c.want {
+			t.Fatalf("Accept %q: got (%v), want (%v)", c.in, got, c.want)
+		}
+	}
+}
+
+func TestCodec_DecodeRequestBody(t *testing.T) {
+	cases := []struct {
+		name             string
+		inContentType    string
+		inBody           string
+		wantRoute        *olaf.Route
+		wantErrIsInvalid bool
+	}{
+		{
+			name:          "json",
+			inContentType: "application/json",
+			inBody:        `{"name": "foo", "service_name": "bar", "paths": ["/foo"]}`,
+			wantRoute:     &olaf.Route{Name: "foo", ServiceName: "bar", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+		},
+		{
+			name:          "yaml",
+			inContentType: "application/yaml; charset=utf-8",
+			inBody:        "name: foo\nservice_name: bar\npaths: [/foo]\n",
+			wantRoute:     &olaf.Route{Name: "foo", ServiceName: "bar", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+		},
+		{
+			name:             "invalid yaml",
+			inContentType:    "text/yaml",
+			inBody:           "name: [foo\n",
+			wantErrIsInvalid: true,
+		},
+		{
+			name:             "mismatched type",
+			inContentType:    "text/yaml",
+			inBody:           "paths: /foo\n",
+			wantErrIsInvalid: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/routes", strings.NewReader(c.inBody))
+			r.Header.Set("Content-Type", c.inContentType)
+
+			route := new(olaf.Route)
+			err := Codec{}.DecodeRequestBody(r, route)
+			if c.wantErrIsInvalid {
+				if !errors.Is(err, olaf.ErrInvalidOperation) {
+					t.Fatalf("Err: got (%v), want (%v)", err, olaf.ErrInvalidOperation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(route, c.wantRoute) {
+				t.Fatalf("Route: got (%+v), want (%+v)", route, c.wantRoute)
+			}
+		})
+	}
+}
+
+func TestCodec_EncodeResponse(t *testing.T) {
+	data := &olaf.Data{
+		Services: map[string]*olaf.Service{
+			"bar": {Name: "bar", Upstream: &olaf.Upstream{Backends: []*olaf.Backend{{Dial: "localhost:8080"}}}},
+		},
+		Routes: map[string]*olaf.Route{
+			"foo": {Name: "foo", ServiceName: "bar", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+		},
+	}
+
+	cases := []struct {
+		name            string
+		inAccept        string
+		inWrapped       bool
+		inCodec         Codec
+		inBody          interface{}
+		inErr           error
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "json",
+			inBody:          &olaf.Route{Name: "foo", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"name":"foo"`,
+		},
+		{
+			name:            "yaml",
+			inAccept:        "application/yaml",
+			inBody:          &olaf.Route{Name: "foo", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+			wantStatus:      http.StatusOK,
+			wantContentType: MediaTypeYAML,
+			wantBody:        "name: foo\n",
+		},
+		{
+			name:            "yaml through a wrapped writer",
+			inAccept:        "application/yaml",
+			inWrapped:       true,
+			inBody:          &olaf.Route{Name: "foo", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+			wantStatus:      http.StatusOK,
+			wantContentType: MediaTypeYAML,
+			wantBody:        "name: foo\n",
+		},
+		{
+			name:            "yaml config",
+			inAccept:        "application/yaml",
+			inBody:          data,
+			wantStatus:      http.StatusOK,
+			wantContentType: MediaTypeYAML,
+			wantBody:        "routes:\n    foo:\n        id: \"\"\n        service_name: bar\n        name: foo\n",
+		},
+		{
+			name:            "yaml config in the declarative layout",
+			inAccept:        "application/yaml",
+			inCodec:         Codec{MarshalConfig: olafyaml.Marshal},
+			inBody:          data,
+			wantStatus:      http.StatusOK,
+			wantContentType: MediaTypeYAML,
+			wantBody:        "    routes:\n      - name: foo\n        paths:\n          - /foo\n",
+		},
+		{
+			name:            "yaml error",
+			inAccept:        "application/yaml",
+			inErr:           olaf.ErrRouteNotFound,
+			wantStatus:      http.StatusNotFound,
+			wantContentType: MediaTypeYAML,
+			wantBody:        "code: not_found\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := NegotiationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.inWrapped {
+					w = &statusResponseWriter{ResponseWriter: w}
+				}
+				if c.inErr != nil {
+					Codec{}.EncodeFailureResponse(w, c.inErr) // nolint:errcheck
+					return
+				}
+				c.inCodec.EncodeSuccessResponse(w, http.StatusOK, c.inBody) // nolint:errcheck
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.inAccept != "" {
+				r.Header.Set("Accept", c.inAccept)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", w.Code, c.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, c.wantContentType) {
+				t.Fatalf("Content-Type: got (%s), want (%s)", got, c.wantContentType)
+			}
+			if body := w.Body.String(); !strings.Contains(body, c.wantBody) {
+				t.Fatalf("Body: got (%s), want (...%s...)", body, c.wantBody)
+			}
+		})
+	}
+}
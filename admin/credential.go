@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// Credential sets the credential used by an HTTP client to authenticate
+// against the Admin API.
+type Credential func(*credentials)
+
+type credentials struct {
+	apiKey string
+
+	basicAuth bool
+	username  string
+	password  string
+
+	certs   []tls.Certificate
+	rootCAs *x509.CertPool
+}
+
+// WithAPIKey makes the client send key in the X-API-Key header.
+func WithAPIKey(key string) Credential {
+	return func(c *credentials) {
+		c.apiKey = key
+	}
+}
+
+// WithBasicAuth makes the client use HTTP basic auth.
+func WithBasicAuth(username, password string) Credential {
+	return func(c *credentials) {
+		c.basicAuth = true
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithClientCert makes the client present cert for mTLS. If rootCAs is not
+// nil, it is used to verify the server certificate.
+func WithClientCert(cert tls.Certificate, rootCAs *x509.CertPool) Credential {
+	return func(c *credentials) {
+		c.certs = append(c.certs, cert)
+		c.rootCAs = rootCAs
+	}
+}
+
+// NewAuthHTTPClient returns a copy of httpClient, which sends requests with
+// the given credentials. The result is typically passed to NewHTTPClient.
+func NewAuthHTTPClient(httpClient *http.Client, creds ...Credential) *http.Client {
+	c := new(credentials)
+	for _, cred := range creds {
+		cred(c)
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if len(c.certs) > 0 || c.rootCAs != nil {
+		t, ok := base.(*http.Transport)
+		if !ok {
+			t = http.DefaultTransport.(*http.Transport)
+		}
+		t = t.Clone()
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = new(tls.Config)
+		}
+		t.TLSClientConfig.Certificates = append(t.TLSClientConfig.Certificates, c.certs...)
+		if c.rootCAs != nil {
+			t.TLSClientConfig.RootCAs = c.rootCAs
+		}
+		base = t
+	}
+
+	client := *httpClient
+	client.Transport = &authTransport{base: base, creds: c}
+	return &client
+}
+
+// authTransport adds the credentials to each request.
+type authTransport struct {
+	base  http.RoundTripper
+	creds *credentials
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the original request.
+	req = req.Clone(req.Context())
+	if t.creds.apiKey != "" {
+		req.Header.Set(APIKeyHeader, t.creds.apiKey)
+	}
+	if t.creds.basicAuth {
+		req.SetBasicAuth(t.creds.username, t.creds.password)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -12,6 +12,41 @@ import (
 	"github.com/go-kit/kit/endpoint"
 )
 
+type BatchRequest struct {
+	Ops []*olaf.Operation `json:"ops"`
+}
+
+// ValidateBatchRequest creates a validator for BatchRequest.
+func ValidateBatchRequest(newSchema func(*BatchRequest) validating.Schema) httpoption.Validator {
+	return httpoption.FuncValidator(func(value interface{}) error {
+		req := value.(*BatchRequest)
+		return httpoption.Validate(newSchema(req))
+	})
+}
+
+type BatchResponse struct {
+	Err error `json:"-"`
+}
+
+func (r *BatchResponse) Body() interface{} { return r }
+
+// Failed implements endpoint.Failer.
+func (r *BatchResponse) Failed() error { return r.Err }
+
+// MakeEndpointOfBatch creates the endpoint for s.Batch.
+func MakeEndpointOfBatch(s Admin) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*BatchRequest)
+		err := s.Batch(
+			ctx,
+			req.Ops,
+		)
+		return &BatchResponse{
+			Err: err,
+		}, nil
+	}
+}
+
 type CreatePluginRequest struct {
 	ServiceName string       `json:"-"`
 	RouteName   string       `json:"-"`
@@ -167,6 +202,7 @@ func MakeEndpointOfDeletePlugin(s Admin) endpoint.Endpoint {
 type DeleteRouteRequest struct {
 	ServiceName string `json:"-"`
 	RouteName   string `json:"-"`
+	Cascade     bool   `json:"-"`
 }
 
 // ValidateDeleteRouteRequest creates a validator for DeleteRouteRequest.
@@ -194,6 +230,7 @@ func MakeEndpointOfDeleteRoute(s Admin) endpoint.Endpoint {
 			ctx,
 			req.ServiceName,
 			req.RouteName,
+			req.Cascade,
 		)
 		return &DeleteRouteResponse{
 			Err: err,
@@ -204,6 +241,7 @@ func MakeEndpointOfDeleteRoute(s Admin) endpoint.Endpoint {
 type DeleteServiceRequest struct {
 	ServiceName string `json:"-"`
 	RouteName   string `json:"-"`
+	Cascade     bool   `json:"-"`
 }
 
 // ValidateDeleteServiceRequest creates a validator for DeleteServiceRequest.
@@ -231,6 +269,7 @@ func MakeEndpointOfDeleteService(s Admin) endpoint.Endpoint {
 			ctx,
 			req.ServiceName,
 			req.RouteName,
+			req.Cascade,
 		)
 		return &DeleteServiceResponse{
 			Err: err,
@@ -541,6 +580,84 @@ func MakeEndpointOfListUpstreams(s Admin) endpoint.Endpoint {
 	}
 }
 
+type RenameRouteRequest struct {
+	RouteName string `json:"-"`
+	NewName   string `json:"new_name"`
+}
+
+// ValidateRenameRouteRequest creates a validator for RenameRouteRequest.
+func ValidateRenameRouteRequest(newSchema func(*RenameRouteRequest) validating.Schema) httpoption.Validator {
+	return httpoption.FuncValidator(func(value interface{}) error {
+		req := value.(*RenameRouteRequest)
+		return httpoption.Validate(newSchema(req))
+	})
+}
+
+type RenameRouteResponse struct {
+	Changes []*olaf.Change `json:"changes"`
+	Err     error          `json:"-"`
+}
+
+func (r *RenameRouteResponse) Body() interface{} { return r.Changes }
+
+// Failed implements endpoint.Failer.
+func (r *RenameRouteResponse) Failed() error { return r.Err }
+
+// MakeEndpointOfRenameRoute creates the endpoint for s.RenameRoute.
+func MakeEndpointOfRenameRoute(s Admin) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*RenameRouteRequest)
+		changes, err := s.RenameRoute(
+			ctx,
+			req.RouteName,
+			req.NewName,
+		)
+		return &RenameRouteResponse{
+			Changes: changes,
+			Err:     err,
+		}, nil
+	}
+}
+
+type RenameServiceRequest struct {
+	ServiceName string `json:"-"`
+	NewName     string `json:"new_name"`
+}
+
+// ValidateRenameServiceRequest creates a validator for RenameServiceRequest.
+func ValidateRenameServiceRequest(newSchema func(*RenameServiceRequest) validating.Schema) httpoption.Validator {
+	return httpoption.FuncValidator(func(value interface{}) error {
+		req := value.(*RenameServiceRequest)
+		return httpoption.Validate(newSchema(req))
+	})
+}
+
+type RenameServiceResponse struct {
+	Changes []*olaf.Change `json:"changes"`
+	Err     error          `json:"-"`
+}
+
+func (r *RenameServiceResponse) Body() interface{} { return r.Changes }
+
+// Failed implements endpoint.Failer.
+func (r *RenameServiceResponse) Failed() error { return r.Err }
+
+// MakeEndpointOfRenameService creates the endpoint for s.RenameService.
+func MakeEndpointOfRenameService(s Admin) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*RenameServiceRequest)
+		changes, err := s.RenameService(
+			ctx,
+			req.ServiceName,
+			req.NewName,
+		)
+		return &RenameServiceResponse{
+			Changes: changes,
+			Err:     err,
+		}, nil
+	}
+}
+
 type UpdatePluginRequest struct {
 	ServiceName string       `json:"-"`
 	RouteName   string       `json:"-"`
@@ -0,0 +1,177 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/RussellLuo/olaf"
+)
+
+// Machine-readable error codes.
+const (
+	CodeInvalid           = "invalid"
+	CodeAlreadyExists     = "already_exists"
+	CodeNotFound          = "not_found"
+	CodeHasDependents     = "has_dependents"
+	CodeBrokenReferences  = "broken_references"
+	CodeNotImplemented    = "not_implemented"
+	CodeUnauthenticated   = "unauthenticated"
+	CodeForbidden         = "forbidden"
+	CodeRevisionCompacted = "revision_compacted"
+	CodeInternal          = "internal"
+)
+
+// Error is the structured error returned by the Admin API.
+//
+// On the client side (see HTTPClient), the error is decoded back into an
+// Error, which wraps the same olaf error as the one on the server side. So
+// errors.Is and errors.As work in the same way on both sides.
+type Error struct {
+	Code    string `json:"code"`
+	Status  int    `json:"status"`
+	Message string `json:"error"`
+
+	// The entity that the error is about.
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// The index of the failed operation within a batch.
+	Index *int `json:"index,omitempty"`
+
+	// The invalid fields, if Code is CodeInvalid.
+	Fields []*olaf.FieldError `json:"fields,omitempty"`
+	// The entities still referring to the entity, if Code is CodeHasDependents.
+	Dependents []olaf.EntityRef `json:"dependents,omitempty"`
+	// The broken references, if Code is CodeBrokenReferences.
+	References []*olaf.ReferenceError `json:"references,omitempty"`
+	// The denied request and the rule that denied it, if Code is CodeForbidden.
+	Forbidden *ForbiddenError `json:"forbidden,omitempty"`
+
+	err error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.err }
+
+// sentinels maps the well-known errors to their codes.
+var sentinels = []struct {
+	err    error
+	code   string
+	kind   string
+	status int
+}{
+	{olaf.ErrServiceExists, CodeAlreadyExists, olaf.KindService, http.StatusBadRequest},
+	{olaf.ErrRouteExists, CodeAlreadyExists, olaf.KindRoute, http.StatusBadRequest},
+	{olaf.ErrPluginExists, CodeAlreadyExists, olaf.KindPlugin, http.StatusBadRequest},
+	{olaf.ErrServiceNotFound, CodeNotFound, olaf.KindService, http.StatusNotFound},
+	{olaf.ErrRouteNotFound, CodeNotFound, olaf.KindRoute, http.StatusNotFound},
+	{olaf.ErrPluginNotFound, CodeNotFound, olaf.KindPlugin, http.StatusNotFound},
+	{olaf.ErrUpstreamNotFound, CodeNotFound, olaf.KindUpstream, http.StatusNotFound},
+	{ErrWebhookNotFound, CodeNotFound, KindWebhook, http.StatusNotFound},
+	{olaf.ErrMethodNotImplemented, CodeNotImplemented, "", http.StatusMethodNotAllowed},
+	{ErrUnauthenticated, CodeUnauthenticated, "", http.StatusUnauthorized},
+	{ErrForbidden, CodeForbidden, "", http.StatusForbidden},
+	{olaf.ErrRevisionCompacted, CodeRevisionCompacted, "", http.StatusGone},
+}
+
+// NewError converts err into an Error.
+func NewError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	e = &Error{
+		Code:    CodeInternal,
+		Status:  http.StatusInternalServerError,
+		Message: err.Error(),
+		err:     err,
+	}
+
+	var depErr *olaf.DependentsError
+	var integrityErr olaf.IntegrityError
+	var validationErr *olaf.ValidationError
+	var forbiddenErr *ForbiddenError
+	switch {
+	case errors.As(err, &depErr):
+		e.Code, e.Status = CodeHasDependents, http.StatusConflict
+		e.Kind, e.Name = depErr.Kind, depErr.Name
+		e.Dependents = depErr.Dependents
+	case errors.As(err, &integrityErr):
+		e.Code, e.Status = CodeBrokenReferences, http.StatusBadRequest
+		e.References = integrityErr
+	case errors.As(err, &validationErr):
+		e.Code, e.Status = CodeInvalid, http.StatusBadRequest
+		e.Kind = validationErr.Kind
+		e.Fields = validationErr.Fields
+	case errors.Is(err, olaf.ErrInvalidOperation):
+		e.Code, e.Status = CodeInvalid, http.StatusBadRequest
+	case errors.As(err, &forbiddenErr):
+		e.Code, e.Status = CodeForbidden, http.StatusForbidden
+		e.Kind, e.Name = forbiddenErr.Kind, forbiddenErr.Name
+		e.Forbidden = forbiddenErr
+	default:
+		for _, s := range sentinels {
+			if errors.Is(err, s.err) {
+				e.Code, e.Status, e.Kind = s.code, s.status, s.kind
+				break
+			}
+		}
+	}
+
+	var entityErr *olaf.EntityError
+	if errors.As(err, &entityErr) {
+		e.Kind, e.Name = entityErr.Kind, entityErr.Name
+	}
+
+	var opErr *olaf.OperationError
+	if errors.As(err, &opErr) {
+		index := opErr.Index
+		e.Index = &index
+	}
+
+	return e
+}
+
+// decodeError decodes an Error from r, and restores the olaf error it wraps.
+func decodeError(r io.Reader) (*Error, error) {
+	e := new(Error)
+	if err := json.NewDecoder(r).Decode(e); err != nil {
+		return nil, err
+	}
+	e.err = e.cause()
+	return e, nil
+}
+
+// cause returns the olaf error corresponding to e.
+func (e *Error) cause() error {
+	switch e.Code {
+	case CodeHasDependents:
+		return &olaf.DependentsError{Kind: e.Kind, Name: e.Name, Dependents: e.Dependents}
+	case CodeBrokenReferences:
+		return olaf.IntegrityError(e.References)
+	case CodeInvalid:
+		if len(e.Fields) > 0 {
+			return &olaf.ValidationError{Kind: e.Kind, Fields: e.Fields}
+		}
+		return olaf.ErrInvalidOperation
+	case CodeForbidden:
+		if e.Forbidden != nil {
+			return e.Forbidden
+		}
+		return ErrForbidden
+	}
+
+	for _, s := range sentinels {
+		if s.code == e.Code && s.kind == e.Kind {
+			if e.Name != "" {
+				return &olaf.EntityError{Kind: e.Kind, Name: e.Name, Err: s.err}
+			}
+			return s.err
+		}
+	}
+	return nil
+}
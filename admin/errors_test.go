@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+func TestError_RoundTrip(t *testing.T) {
+	cases := []struct {
+		name       string
+		in         error
+		wantCode   string
+		wantStatus int
+		wantKind   string
+		wantName   string
+		wantIs     error
+	}{
+		{
+			name:       "not found with name",
+			in:         olaf.NotFoundError(olaf.KindRoute, "foo"),
+			wantCode:   CodeNotFound,
+			wantStatus: http.StatusNotFound,
+			wantKind:   olaf.KindRoute,
+			wantName:   "foo",
+			wantIs:     olaf.ErrRouteNotFound,
+		},
+		{
+			name:       "wrapped upstream not found",
+			in:         fmt.Errorf("oops: %w", olaf.ErrUpstreamNotFound),
+			wantCode:   CodeNotFound,
+			wantStatus: http.StatusNotFound,
+			wantKind:   olaf.KindUpstream,
+			wantIs:     olaf.ErrUpstreamNotFound,
+		},
+		{
+			name: "invalid field within a batch",
+			in: &olaf.OperationError{
+				Index: 1,
+				Op:    &olaf.Operation{Op: olaf.OpCreate, Kind: olaf.KindService},
+				Err:   olaf.InvalidField(olaf.KindService, "name", "is required"),
+			},
+			wantCode:   CodeInvalid,
+			wantStatus: http.StatusBadRequest,
+			wantKind:   olaf.KindService,
+			wantIs:     olaf.ErrInvalidOperation,
+		},
+		{
+			name:       "unknown",
+			in:         errors.New("oops"),
+			wantCode:   CodeInternal,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := NewError(c.in)
+			if e.Code != c.wantCode || e.Status != c.wantStatus || e.Kind != c.wantKind || e.Name != c.wantName {
+				t.Fatalf("Error: got (%s, %d, %s, %s), want (%s, %d, %s, %s)",
+					e.Code, e.Status, e.Kind, e.Name, c.wantCode, c.wantStatus, c.wantKind, c.wantName)
+			}
+
+			b, err := json.Marshal(e)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			got, err := decodeError(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			if got.Error() != c.in.Error() {
+				t.Fatalf("Message: got (%s), want (%s)", got.Error(), c.in.Error())
+			}
+			if c.wantIs != nil && !errors.Is(got, c.wantIs) {
+				t.Fatalf("Err: got (%v), want (%v)", got, c.wantIs)
+			}
+		})
+	}
+}
+
+func TestError_Dependents(t *testing.T) {
+	in := &olaf.DependentsError{
+		Kind:       olaf.KindService,
+		Name:       "production",
+		Dependents: []olaf.EntityRef{{Kind: olaf.KindRoute, Name: "foo"}},
+	}
+
+	b, err := json.Marshal(NewError(in))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	got, err := decodeError(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if got.Status != http.StatusConflict {
+		t.Fatalf("Status: got (%d), want (%d)", got.Status, http.StatusConflict)
+	}
+	var depErr *olaf.DependentsError
+	if !errors.As(got, &depErr) || !reflect.DeepEqual(depErr, in) {
+		t.Fatalf("Err: got (%#v), want (%#v)", depErr, in)
+	}
+}
@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RussellLuo/olaf"
+)
+
+// KindEvent is the kind of the events (see NewEventsHandler).
+const KindEvent = "event"
+
+// LastEventIDHeader is the header carrying the revision of the last event
+// received by the client, which is sent by EventSource on reconnection.
+const LastEventIDHeader = "Last-Event-ID"
+
+// heartbeatInterval is the interval of the comments sent to keep the idle
+// event streams alive.
+var heartbeatInterval = 15 * time.Second
+
+// NewEventsHandler returns a handler streaming the events of n as server-sent
+// events, whose IDs are the revisions and whose data are the JSON-encoded
+// olaf.Events. If the Last-Event-ID header is present, the stream resumes from
+// the event after it, otherwise only the new events are sent.
+//
+// If policy is not nil, only the events of the entities that the caller is
+// allowed to read are sent, in which case svc is used to find the services
+// to which the entities belong.
+func NewEventsHandler(n olaf.Notifier, policy *Policy, svc Admin) http.Handler {
+	var authz *rbac
+	if policy != nil {
+		authz = &rbac{policy: policy, next: svc}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since := int64(-1)
+		if s := r.Header.Get(LastEventIDHeader); s != "" {
+			rev, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || rev < 0 {
+				Codec{}.EncodeFailureResponse(w, olaf.InvalidField(KindEvent, LastEventIDHeader, "must be a revision")) // nolint:errcheck
+				return
+			}
+			since = rev
+		}
+
+		if authz != nil {
+			if _, ok := IdentityFromContext(r.Context()); !ok {
+				Codec{}.EncodeFailureResponse(w, ErrUnauthenticated) // nolint:errcheck
+				return
+			}
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			Codec{}.EncodeFailureResponse(w, fmt.Errorf("streaming not supported")) // nolint:errcheck
+			return
+		}
+
+		ctx := r.Context()
+		events, err := n.Watch(ctx, since)
+		if err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					// Either the client has gone, or it can not keep up
+					// with the events and should reconnect.
+					return
+				}
+				if authz != nil && authz.authorizeEvent(ctx, e) != nil {
+					continue
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+func writeEvent(w http.ResponseWriter, e *olaf.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Revision, e.Type, data)
+	return err
+}
+
+// authorizeEvent checks whether the caller is allowed to read the entity
+// of e.
+func (a *rbac) authorizeEvent(ctx context.Context, e *olaf.Event) error {
+	var svc *olaf.Service
+	switch entity := e.Entity.(type) {
+	case *olaf.Service:
+		svc = entity
+	case *olaf.Route:
+		svc = a.service(ctx, entity.ServiceName)
+		if svc == nil {
+			// The service may have been deleted.
+			svc = &olaf.Service{Name: entity.ServiceName}
+		}
+	case *olaf.Plugin:
+		svc = a.pluginService(ctx, entity)
+	}
+	return a.authorize(ctx, VerbRead, e.Kind, e.Name, svc)
+}
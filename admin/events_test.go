@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+const testEventsPolicy = `
+roles:
+- name: team-a-reader
+  rules:
+  - kinds: ["*"]
+    verbs: ["read"]
+    services: ["team-a-*"]
+bindings:
+- role: team-a-reader
+  identities: ["alice"]
+`
+
+func TestEventsHandler(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	policyFile := filepath.Join(dir, "policy.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	writeFile(t, policyFile, testEventsPolicy)
+
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	store := yaml.New(configFile)
+
+	ctx := context.Background()
+	if err := store.UpdateService(ctx, "team-b-web", "", &olaf.Service{Tags: []string{"team-b"}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := store.UpdateRoute(ctx, "", "web", &olaf.Route{Matcher: olaf.Matcher{Paths: []string{"/web2"}}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	handler := NewEventsHandler(store, policy, store)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(NewContextWithIdentity(r.Context(), "alice")))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name          string
+		inLastEventID string
+		wantStatus    int
+		wantEvent     []string
+	}{
+		{
+			name:          "resume from the beginning",
+			inLastEventID: "0",
+			wantStatus:    http.StatusOK,
+			// The update of team-b-web is filtered out.
+			wantEvent: []string{"id: 2", "event: update", `data: {"revision":2,"type":"update","kind":"route","name":"web"`},
+		},
+		{
+			name:          "resume from a revision beyond the current one",
+			inLastEventID: "500",
+			wantStatus:    http.StatusGone,
+		},
+		{
+			name:          "invalid Last-Event-ID",
+			inLastEventID: "latest",
+			wantStatus:    http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			req.Header.Set(LastEventIDHeader, c.inLastEventID)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", resp.StatusCode, c.wantStatus)
+			}
+			if c.wantStatus != http.StatusOK {
+				return
+			}
+
+			scanner := bufio.NewScanner(resp.Body)
+			for _, want := range c.wantEvent {
+				if !scanner.Scan() {
+					t.Fatalf("err: %v", scanner.Err())
+				}
+				if line := scanner.Text(); !strings.HasPrefix(line, want) {
+					t.Fatalf("Line: got (%s), want (%s...)", line, want)
+				}
+			}
+		})
+	}
+}
@@ -24,6 +24,20 @@ func NewHTTPRouter(svc Admin, codecs httpcodec.Codecs, opts ...httpoption.Option
 	var validator httpoption.Validator
 	var kitOptions []kithttp.ServerOption
 
+	codec = codecs.EncodeDecoder("Batch")
+	validator = options.RequestValidator("Batch")
+	r.Method(
+		"POST", "/batch",
+		kithttp.NewServer(
+			MakeEndpointOfBatch(svc),
+			decodeBatchRequest(codec, validator),
+			httpcodec.MakeResponseEncoder(codec, 200),
+			append(kitOptions,
+				kithttp.ServerErrorEncoder(httpcodec.MakeErrorEncoder(codec)),
+			)...,
+		),
+	)
+
 	codec = codecs.EncodeDecoder("CreatePlugin")
 	validator = options.RequestValidator("CreatePlugin")
 	r.Method(
@@ -444,6 +458,34 @@ func NewHTTPRouter(svc Admin, codecs httpcodec.Codecs, opts ...httpoption.Option
 		),
 	)
 
+	codec = codecs.EncodeDecoder("RenameRoute")
+	validator = options.RequestValidator("RenameRoute")
+	r.Method(
+		"POST", "/routes/{routeName}/rename",
+		kithttp.NewServer(
+			MakeEndpointOfRenameRoute(svc),
+			decodeRenameRouteRequest(codec, validator),
+			httpcodec.MakeResponseEncoder(codec, 200),
+			append(kitOptions,
+				kithttp.ServerErrorEncoder(httpcodec.MakeErrorEncoder(codec)),
+			)...,
+		),
+	)
+
+	codec = codecs.EncodeDecoder("RenameService")
+	validator = options.RequestValidator("RenameService")
+	r.Method(
+		"POST", "/services/{serviceName}/rename",
+		kithttp.NewServer(
+			MakeEndpointOfRenameService(svc),
+			decodeRenameServiceRequest(codec, validator),
+			httpcodec.MakeResponseEncoder(codec, 200),
+			append(kitOptions,
+				kithttp.ServerErrorEncoder(httpcodec.MakeErrorEncoder(codec)),
+			)...,
+		),
+	)
+
 	codec = codecs.EncodeDecoder("UpdatePlugin")
 	validator = options.RequestValidator("UpdatePlugin")
 	r.Method(
@@ -573,6 +615,22 @@ func NewHTTPRouter(svc Admin, codecs httpcodec.Codecs, opts ...httpoption.Option
 	return r
 }
 
+func decodeBatchRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var _req BatchRequest
+
+		if err := codec.DecodeRequestBody(r, &_req.Ops); err != nil {
+			return nil, err
+		}
+
+		if err := validator.Validate(&_req); err != nil {
+			return nil, err
+		}
+
+		return &_req, nil
+	}
+}
+
 func decodeCreatePluginRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
 	return func(_ context.Context, r *http.Request) (interface{}, error) {
 		var _req CreatePluginRequest
@@ -754,6 +812,11 @@ func decodeDeleteRouteRequest(codec httpcodec.Codec, validator httpoption.Valida
 			return nil, err
 		}
 
+		cascade := r.URL.Query()["cascade"]
+		if err := codec.DecodeRequestParam("cascade", cascade, &_req.Cascade); err != nil {
+			return nil, err
+		}
+
 		if err := validator.Validate(&_req); err != nil {
 			return nil, err
 		}
@@ -776,6 +839,11 @@ func decodeDeleteRoute1Request(codec httpcodec.Codec, validator httpoption.Valid
 			return nil, err
 		}
 
+		cascade := r.URL.Query()["cascade"]
+		if err := codec.DecodeRequestParam("cascade", cascade, &_req.Cascade); err != nil {
+			return nil, err
+		}
+
 		if err := validator.Validate(&_req); err != nil {
 			return nil, err
 		}
@@ -793,6 +861,11 @@ func decodeDeleteServiceRequest(codec httpcodec.Codec, validator httpoption.Vali
 			return nil, err
 		}
 
+		cascade := r.URL.Query()["cascade"]
+		if err := codec.DecodeRequestParam("cascade", cascade, &_req.Cascade); err != nil {
+			return nil, err
+		}
+
 		if err := validator.Validate(&_req); err != nil {
 			return nil, err
 		}
@@ -810,6 +883,11 @@ func decodeDeleteService1Request(codec httpcodec.Codec, validator httpoption.Val
 			return nil, err
 		}
 
+		cascade := r.URL.Query()["cascade"]
+		if err := codec.DecodeRequestParam("cascade", cascade, &_req.Cascade); err != nil {
+			return nil, err
+		}
+
 		if err := validator.Validate(&_req); err != nil {
 			return nil, err
 		}
@@ -1079,6 +1157,48 @@ func decodeListUpstreamsRequest(codec httpcodec.Codec, validator httpoption.Vali
 	}
 }
 
+func decodeRenameRouteRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var _req RenameRouteRequest
+
+		if err := codec.DecodeRequestBody(r, &_req); err != nil {
+			return nil, err
+		}
+
+		routeName := []string{chi.URLParam(r, "routeName")}
+		if err := codec.DecodeRequestParam("routeName", routeName, &_req.RouteName); err != nil {
+			return nil, err
+		}
+
+		if err := validator.Validate(&_req); err != nil {
+			return nil, err
+		}
+
+		return &_req, nil
+	}
+}
+
+func decodeRenameServiceRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var _req RenameServiceRequest
+
+		if err := codec.DecodeRequestBody(r, &_req); err != nil {
+			return nil, err
+		}
+
+		serviceName := []string{chi.URLParam(r, "serviceName")}
+		if err := codec.DecodeRequestParam("serviceName", serviceName, &_req.ServiceName); err != nil {
+			return nil, err
+		}
+
+		if err := validator.Validate(&_req); err != nil {
+			return nil, err
+		}
+
+		return &_req, nil
+	}
+}
+
 func decodeUpdatePluginRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
 	return func(_ context.Context, r *http.Request) (interface{}, error) {
 		var _req UpdatePluginRequest
@@ -36,6 +36,49 @@ func NewHTTPClient(codecs httpcodec.Codecs, httpClient *http.Client, baseURL str
 	}, nil
 }
 
+func (c *HTTPClient) Batch(ctx context.Context, ops []*olaf.Operation) (err error) {
+	codec := c.codecs.EncodeDecoder("Batch")
+
+	path := "/batch"
+	u := &url.URL{
+		Scheme: c.scheme,
+		Host:   c.host,
+		Path:   c.pathPrefix + path,
+	}
+
+	reqBody := ops
+	reqBodyReader, headers, err := codec.EncodeRequestBody(&reqBody)
+	if err != nil {
+		return err
+	}
+
+	_req, err := http.NewRequest("POST", u.String(), reqBodyReader)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range headers {
+		_req.Header.Set(k, v)
+	}
+
+	_resp, err := c.httpClient.Do(_req)
+	if err != nil {
+		return err
+	}
+	defer _resp.Body.Close()
+
+	if _resp.StatusCode < http.StatusOK || _resp.StatusCode > http.StatusNoContent {
+		var respErr error
+		err := codec.DecodeFailureResponse(_resp.Body, &respErr)
+		if err == nil {
+			err = respErr
+		}
+		return err
+	}
+
+	return nil
+}
+
 func (c *HTTPClient) CreatePlugin(ctx context.Context, serviceName string, routeName string, p *olaf.Plugin) (plugin *olaf.Plugin, err error) {
 	codec := c.codecs.EncodeDecoder("CreatePlugin")
 
@@ -205,7 +248,7 @@ func (c *HTTPClient) DeletePlugin(ctx context.Context, serviceName string, route
 	return nil
 }
 
-func (c *HTTPClient) DeleteRoute(ctx context.Context, serviceName string, routeName string) (err error) {
+func (c *HTTPClient) DeleteRoute(ctx context.Context, serviceName string, routeName string, cascade bool) (err error) {
 	codec := c.codecs.EncodeDecoder("DeleteRoute")
 
 	path := fmt.Sprintf("/routes/%s",
@@ -217,6 +260,12 @@ func (c *HTTPClient) DeleteRoute(ctx context.Context, serviceName string, routeN
 		Path:   c.pathPrefix + path,
 	}
 
+	q := u.Query()
+	for _, v := range codec.EncodeRequestParam("cascade", cascade) {
+		q.Add("cascade", v)
+	}
+	u.RawQuery = q.Encode()
+
 	_req, err := http.NewRequest("DELETE", u.String(), nil)
 	if err != nil {
 		return err
@@ -240,7 +289,7 @@ func (c *HTTPClient) DeleteRoute(ctx context.Context, serviceName string, routeN
 	return nil
 }
 
-func (c *HTTPClient) DeleteService(ctx context.Context, serviceName string, routeName string) (err error) {
+func (c *HTTPClient) DeleteService(ctx context.Context, serviceName string, routeName string, cascade bool) (err error) {
 	codec := c.codecs.EncodeDecoder("DeleteService")
 
 	path := fmt.Sprintf("/services/%s",
@@ -252,6 +301,12 @@ func (c *HTTPClient) DeleteService(ctx context.Context, serviceName string, rout
 		Path:   c.pathPrefix + path,
 	}
 
+	q := u.Query()
+	for _, v := range codec.EncodeRequestParam("cascade", cascade) {
+		q.Add("cascade", v)
+	}
+	u.RawQuery = q.Encode()
+
 	_req, err := http.NewRequest("DELETE", u.String(), nil)
 	if err != nil {
 		return err
@@ -625,6 +680,114 @@ func (c *HTTPClient) ListUpstreams(ctx context.Context) (upstreams []*olaf.Upstr
 	return respBody.Upstreams, nil
 }
 
+func (c *HTTPClient) RenameRoute(ctx context.Context, routeName string, newName string) (changes []*olaf.Change, err error) {
+	codec := c.codecs.EncodeDecoder("RenameRoute")
+
+	path := fmt.Sprintf("/routes/%s/rename",
+		codec.EncodeRequestParam("routeName", routeName)[0],
+	)
+	u := &url.URL{
+		Scheme: c.scheme,
+		Host:   c.host,
+		Path:   c.pathPrefix + path,
+	}
+
+	reqBody := struct {
+		NewName string `json:"new_name"`
+	}{
+		NewName: newName,
+	}
+	reqBodyReader, headers, err := codec.EncodeRequestBody(&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	_req, err := http.NewRequest("POST", u.String(), reqBodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range headers {
+		_req.Header.Set(k, v)
+	}
+
+	_resp, err := c.httpClient.Do(_req)
+	if err != nil {
+		return nil, err
+	}
+	defer _resp.Body.Close()
+
+	if _resp.StatusCode < http.StatusOK || _resp.StatusCode > http.StatusNoContent {
+		var respErr error
+		err := codec.DecodeFailureResponse(_resp.Body, &respErr)
+		if err == nil {
+			err = respErr
+		}
+		return nil, err
+	}
+
+	respBody := &RenameRouteResponse{}
+	err = codec.DecodeSuccessResponse(_resp.Body, respBody.Body())
+	if err != nil {
+		return nil, err
+	}
+	return respBody.Changes, nil
+}
+
+func (c *HTTPClient) RenameService(ctx context.Context, serviceName string, newName string) (changes []*olaf.Change, err error) {
+	codec := c.codecs.EncodeDecoder("RenameService")
+
+	path := fmt.Sprintf("/services/%s/rename",
+		codec.EncodeRequestParam("serviceName", serviceName)[0],
+	)
+	u := &url.URL{
+		Scheme: c.scheme,
+		Host:   c.host,
+		Path:   c.pathPrefix + path,
+	}
+
+	reqBody := struct {
+		NewName string `json:"new_name"`
+	}{
+		NewName: newName,
+	}
+	reqBodyReader, headers, err := codec.EncodeRequestBody(&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	_req, err := http.NewRequest("POST", u.String(), reqBodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range headers {
+		_req.Header.Set(k, v)
+	}
+
+	_resp, err := c.httpClient.Do(_req)
+	if err != nil {
+		return nil, err
+	}
+	defer _resp.Body.Close()
+
+	if _resp.StatusCode < http.StatusOK || _resp.StatusCode > http.StatusNoContent {
+		var respErr error
+		err := codec.DecodeFailureResponse(_resp.Body, &respErr)
+		if err == nil {
+			err = respErr
+		}
+		return nil, err
+	}
+
+	respBody := &RenameServiceResponse{}
+	err = codec.DecodeSuccessResponse(_resp.Body, respBody.Body())
+	if err != nil {
+		return nil, err
+	}
+	return respBody.Changes, nil
+}
+
 func (c *HTTPClient) UpdatePlugin(ctx context.Context, serviceName string, routeName string, pluginName string, plugin *olaf.Plugin) (err error) {
 	codec := c.codecs.EncodeDecoder("UpdatePlugin")
 
@@ -0,0 +1,345 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Loader is implemented by the stores that load (and reload) the config from
+// somewhere, e.g. a file.
+type Loader interface {
+	// Loads returns the numbers of the successful and failed loads.
+	Loads() (successes, failures int64)
+}
+
+// Metrics holds the Prometheus metrics of the Admin API:
+//
+//	olaf_admin_requests_total{operation,code}                counter
+//	olaf_admin_request_duration_seconds{operation}           histogram
+//	olaf_store_operation_duration_seconds{operation,result}  histogram
+//	olaf_config_loads_total{result}                          counter
+//	olaf_config_revision                                     gauge
+//	olaf_config_entities{kind}                               gauge
+//
+// The operations are the ones of the Admin API (e.g. CreatePlugin), no matter
+// which of the alternate paths is requested (see OASv3APIDoc). The results
+// are "success" or "failure".
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	storeDuration   *prometheus.HistogramVec
+
+	operations map[string]string
+}
+
+// NewMetrics creates the metrics. The config metrics are collected from svc,
+// which is usually the store itself, on every scrape. If svc also implements
+// Loader, the loads are counted, and if n is not nil, the revision is
+// reported.
+func NewMetrics(svc Admin, n olaf.Notifier) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "olaf",
+			Subsystem: "admin",
+			Name:      "requests_total",
+			Help:      "Total number of the Admin API requests.",
+		}, []string{"operation", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "olaf",
+			Subsystem: "admin",
+			Name:      "request_duration_seconds",
+			Help:      "Latencies of the Admin API requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "olaf",
+			Subsystem: "store",
+			Name:      "operation_duration_seconds",
+			Help:      "Latencies of the store operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "result"}),
+		operations: make(map[string]string),
+	}
+
+	for _, op := range oasOperations {
+		for _, path := range op.paths {
+			m.operations[op.method+" "+path] = op.id
+		}
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.storeDuration,
+		&configCollector{svc: svc, n: n},
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler returns a handler serving the metrics in the Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware returns a middleware, which records the request metrics. It must
+// be used by the chi router on which the Admin API is mounted, since the
+// operations are determined by the matched route patterns.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		begin := time.Now()
+		rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		op := m.operation(r)
+		m.requests.WithLabelValues(op, strconv.Itoa(rw.status)).Inc()
+		m.requestDuration.WithLabelValues(op).Observe(time.Since(begin).Seconds())
+	})
+}
+
+// operation returns the operation of r, which has been routed by chi.
+func (m *Metrics) operation(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return "unknown"
+	}
+	pattern := rctx.RoutePattern()
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if op, ok := m.operations[r.Method+" "+pattern]; ok {
+		return op
+	}
+	return "unknown"
+}
+
+func (m *Metrics) observe(op string, begin time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.storeDuration.WithLabelValues(op, result).Observe(time.Since(begin).Seconds())
+}
+
+// statusResponseWriter records the status code of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher, for streaming responses (see
+// NewEventsHandler).
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original ResponseWriter (see Codec).
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// configCollector collects the config metrics on every scrape.
+type configCollector struct {
+	svc Admin
+	n   olaf.Notifier
+}
+
+var (
+	configLoadsDesc = prometheus.NewDesc(
+		"olaf_config_loads_total",
+		"Total number of the config loads (and reloads).",
+		[]string{"result"}, nil,
+	)
+	configRevisionDesc = prometheus.NewDesc(
+		"olaf_config_revision",
+		"The revision of the config.",
+		nil, nil,
+	)
+	configEntitiesDesc = prometheus.NewDesc(
+		"olaf_config_entities",
+		"Number of the entities in the config.",
+		[]string{"kind"}, nil,
+	)
+)
+
+func (c *configCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- configLoadsDesc
+	ch <- configRevisionDesc
+	ch <- configEntitiesDesc
+}
+
+func (c *configCollector) Collect(ch chan<- prometheus.Metric) {
+	if l, ok := c.svc.(Loader); ok {
+		successes, failures := l.Loads()
+		ch <- prometheus.MustNewConstMetric(configLoadsDesc, prometheus.CounterValue, float64(successes), "success")
+		ch <- prometheus.MustNewConstMetric(configLoadsDesc, prometheus.CounterValue, float64(failures), "failure")
+	}
+	if c.n != nil {
+		ch <- prometheus.MustNewConstMetric(configRevisionDesc, prometheus.GaugeValue, float64(c.n.Revision()))
+	}
+	if c.svc == nil {
+		return
+	}
+
+	data, err := c.svc.GetConfig(context.Background())
+	if err != nil {
+		return
+	}
+	upstreams := 0
+	for _, svc := range data.Services {
+		if svc.Upstream != nil {
+			upstreams++
+		}
+	}
+	for _, e := range []struct {
+		kind  string
+		count int
+	}{
+		{olaf.KindService, len(data.Services)},
+		{olaf.KindRoute, len(data.Routes)},
+		{olaf.KindPlugin, len(data.Plugins)},
+		{olaf.KindUpstream, upstreams},
+	} {
+		ch <- prometheus.MustNewConstMetric(configEntitiesDesc, prometheus.GaugeValue, float64(e.count), e.kind)
+	}
+}
+
+// NewMetered returns an Admin, which records the latencies of the operations
+// of next into m.
+func NewMetered(m *Metrics, next Admin) Admin {
+	return &metered{m: m, next: next}
+}
+
+type metered struct {
+	m    *Metrics
+	next Admin
+}
+
+func (m *metered) GetConfig(ctx context.Context) (data *olaf.Data, err error) {
+	defer func(begin time.Time) { m.m.observe("GetConfig", begin, err) }(time.Now())
+	return m.next.GetConfig(ctx)
+}
+
+func (m *metered) CreateService(ctx context.Context, svc *olaf.Service) (err error) {
+	defer func(begin time.Time) { m.m.observe("CreateService", begin, err) }(time.Now())
+	return m.next.CreateService(ctx, svc)
+}
+
+func (m *metered) ListServices(ctx context.Context) (services []*olaf.Service, err error) {
+	defer func(begin time.Time) { m.m.observe("ListServices", begin, err) }(time.Now())
+	return m.next.ListServices(ctx)
+}
+
+func (m *metered) GetService(ctx context.Context, serviceName, routeName string) (service *olaf.Service, err error) {
+	defer func(begin time.Time) { m.m.observe("GetService", begin, err) }(time.Now())
+	return m.next.GetService(ctx, serviceName, routeName)
+}
+
+func (m *metered) UpdateService(ctx context.Context, serviceName, routeName string, svc *olaf.Service) (err error) {
+	defer func(begin time.Time) { m.m.observe("UpdateService", begin, err) }(time.Now())
+	return m.next.UpdateService(ctx, serviceName, routeName, svc)
+}
+
+func (m *metered) DeleteService(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	defer func(begin time.Time) { m.m.observe("DeleteService", begin, err) }(time.Now())
+	return m.next.DeleteService(ctx, serviceName, routeName, cascade)
+}
+
+func (m *metered) CreateRoute(ctx context.Context, serviceName string, route *olaf.Route) (err error) {
+	defer func(begin time.Time) { m.m.observe("CreateRoute", begin, err) }(time.Now())
+	return m.next.CreateRoute(ctx, serviceName, route)
+}
+
+func (m *metered) ListRoutes(ctx context.Context, serviceName string) (routes []*olaf.Route, err error) {
+	defer func(begin time.Time) { m.m.observe("ListRoutes", begin, err) }(time.Now())
+	return m.next.ListRoutes(ctx, serviceName)
+}
+
+func (m *metered) GetRoute(ctx context.Context, serviceName, routeName string) (route *olaf.Route, err error) {
+	defer func(begin time.Time) { m.m.observe("GetRoute", begin, err) }(time.Now())
+	return m.next.GetRoute(ctx, serviceName, routeName)
+}
+
+func (m *metered) UpdateRoute(ctx context.Context, serviceName, routeName string, route *olaf.Route) (err error) {
+	defer func(begin time.Time) { m.m.observe("UpdateRoute", begin, err) }(time.Now())
+	return m.next.UpdateRoute(ctx, serviceName, routeName, route)
+}
+
+func (m *metered) DeleteRoute(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	defer func(begin time.Time) { m.m.observe("DeleteRoute", begin, err) }(time.Now())
+	return m.next.DeleteRoute(ctx, serviceName, routeName, cascade)
+}
+
+func (m *metered) CreatePlugin(ctx context.Context, serviceName, routeName string, p *olaf.Plugin) (plugin *olaf.Plugin, err error) {
+	defer func(begin time.Time) { m.m.observe("CreatePlugin", begin, err) }(time.Now())
+	return m.next.CreatePlugin(ctx, serviceName, routeName, p)
+}
+
+func (m *metered) ListPlugins(ctx context.Context, serviceName, routeName string) (plugins []*olaf.Plugin, err error) {
+	defer func(begin time.Time) { m.m.observe("ListPlugins", begin, err) }(time.Now())
+	return m.next.ListPlugins(ctx, serviceName, routeName)
+}
+
+func (m *metered) GetPlugin(ctx context.Context, serviceName, routeName, pluginName string) (plugin *olaf.Plugin, err error) {
+	defer func(begin time.Time) { m.m.observe("GetPlugin", begin, err) }(time.Now())
+	return m.next.GetPlugin(ctx, serviceName, routeName, pluginName)
+}
+
+func (m *metered) UpdatePlugin(ctx context.Context, serviceName, routeName, pluginName string, plugin *olaf.Plugin) (err error) {
+	defer func(begin time.Time) { m.m.observe("UpdatePlugin", begin, err) }(time.Now())
+	return m.next.UpdatePlugin(ctx, serviceName, routeName, pluginName, plugin)
+}
+
+func (m *metered) DeletePlugin(ctx context.Context, serviceName, routeName, pluginName string) (err error) {
+	defer func(begin time.Time) { m.m.observe("DeletePlugin", begin, err) }(time.Now())
+	return m.next.DeletePlugin(ctx, serviceName, routeName, pluginName)
+}
+
+func (m *metered) ListUpstreams(ctx context.Context) (upstreams []*olaf.Upstream, err error) {
+	defer func(begin time.Time) { m.m.observe("ListUpstreams", begin, err) }(time.Now())
+	return m.next.ListUpstreams(ctx)
+}
+
+func (m *metered) GetUpstream(ctx context.Context, upstreamName, serviceName string) (upstream *olaf.Upstream, err error) {
+	defer func(begin time.Time) { m.m.observe("GetUpstream", begin, err) }(time.Now())
+	return m.next.GetUpstream(ctx, upstreamName, serviceName)
+}
+
+func (m *metered) UpdateUpstream(ctx context.Context, upstreamName, serviceName string, upstream *olaf.Upstream) (err error) {
+	defer func(begin time.Time) { m.m.observe("UpdateUpstream", begin, err) }(time.Now())
+	return m.next.UpdateUpstream(ctx, upstreamName, serviceName, upstream)
+}
+
+func (m *metered) RenameService(ctx context.Context, serviceName, newName string) (changes []*olaf.Change, err error) {
+	defer func(begin time.Time) { m.m.observe("RenameService", begin, err) }(time.Now())
+	return m.next.RenameService(ctx, serviceName, newName)
+}
+
+func (m *metered) RenameRoute(ctx context.Context, routeName, newName string) (changes []*olaf.Change, err error) {
+	defer func(begin time.Time) { m.m.observe("RenameRoute", begin, err) }(time.Now())
+	return m.next.RenameRoute(ctx, routeName, newName)
+}
+
+func (m *metered) Batch(ctx context.Context, ops []*olaf.Operation) (err error) {
+	defer func(begin time.Time) { m.m.observe("Batch", begin, err) }(time.Now())
+	return m.next.Batch(ctx, ops)
+}
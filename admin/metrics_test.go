@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RussellLuo/olaf/store/yaml"
+	"github.com/go-chi/chi"
+)
+
+func TestMetrics(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	store := yaml.New(configFile)
+
+	metrics := NewMetrics(store, store)
+	root := chi.NewRouter()
+	root.Use(metrics.Middleware)
+	root.Mount("/status", NewStatusHandler(store, store, ""))
+	root.Method("GET", "/metrics", metrics.Handler())
+	root.Mount("/", NewRouter(NewMetered(metrics, store), Codec{}))
+
+	server := httptest.NewServer(root)
+	defer server.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// Alternate paths of the same operation.
+	get("/routes")
+	get("/services/team-a-web/routes")
+	get("/status/ready")
+	get("/nonexistent")
+
+	got := get("/metrics")
+	for _, want := range []string{
+		`olaf_admin_requests_total{code="200",operation="ListRoutes"} 2`,
+		`olaf_admin_requests_total{code="200",operation="GetReadiness"} 1`,
+		`olaf_admin_requests_total{code="404",operation="unknown"} 1`,
+		`olaf_admin_request_duration_seconds_count{operation="ListRoutes"} 2`,
+		`olaf_store_operation_duration_seconds_count{operation="ListRoutes",result="success"} 2`,
+		`olaf_config_loads_total{result="success"} 1`,
+		`olaf_config_loads_total{result="failure"} 0`,
+		`olaf_config_revision 0`,
+		`olaf_config_entities{kind="service"} 3`,
+		`olaf_config_entities{kind="route"} 1`,
+		`olaf_config_entities{kind="plugin"} 0`,
+		`olaf_config_entities{kind="upstream"} 3`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Fatalf("Metrics: got (%s), want (...%s...)", got, want)
+		}
+	}
+}
@@ -29,6 +29,16 @@ produces:
 
 	paths = `
 paths:
+  /batch:
+    post:
+      description: "Batch applies all the operations in order, or none of them if any fails."
+      operationId: "Batch"
+      parameters:
+        - name: body
+          in: body
+          schema:
+            $ref: "#/definitions/BatchRequestBody"
+      %s
   /plugins:
     post:
       description: ""
@@ -291,6 +301,11 @@ paths:
           required: true
           type: string
           description: ""
+        - name: cascade
+          in: query
+          required: false
+          type: boolean
+          description: ""
       %s
     get:
       description: ""
@@ -331,6 +346,11 @@ paths:
           required: true
           type: string
           description: ""
+        - name: cascade
+          in: query
+          required: false
+          type: boolean
+          description: ""
       %s
     get:
       description: ""
@@ -376,6 +396,11 @@ paths:
           required: true
           type: string
           description: ""
+        - name: cascade
+          in: query
+          required: false
+          type: boolean
+          description: ""
       %s
     get:
       description: ""
@@ -411,6 +436,11 @@ paths:
           required: true
           type: string
           description: ""
+        - name: cascade
+          in: query
+          required: false
+          type: boolean
+          description: ""
       %s
     get:
       description: ""
@@ -496,11 +526,42 @@ paths:
       description: ""
       operationId: "ListUpstreams"
       %s
+  /routes/{routeName}/rename:
+    post:
+      description: "RenameRoute renames a route and rewrites all the references to it."
+      operationId: "RenameRoute"
+      parameters:
+        - name: routeName
+          in: path
+          required: true
+          type: string
+          description: ""
+        - name: body
+          in: body
+          schema:
+            $ref: "#/definitions/RenameRouteRequestBody"
+      %s
+  /services/{serviceName}/rename:
+    post:
+      description: "RenameService renames a service and rewrites all the references to it."
+      operationId: "RenameService"
+      parameters:
+        - name: serviceName
+          in: path
+          required: true
+          type: string
+          description: ""
+        - name: body
+          in: body
+          schema:
+            $ref: "#/definitions/RenameServiceRequestBody"
+      %s
 `
 )
 
 func getResponses(schema oas2.Schema) []oas2.OASResponses {
 	return []oas2.OASResponses{
+		oas2.GetOASResponses(schema, "Batch", 200, &BatchResponse{}),
 		oas2.GetOASResponses(schema, "CreatePlugin", 200, &CreatePluginResponse{}),
 		oas2.GetOASResponses(schema, "ListPlugins", 200, &ListPluginsResponse{}),
 		oas2.GetOASResponses(schema, "CreatePlugin", 200, &CreatePluginResponse{}),
@@ -540,12 +601,17 @@ func getResponses(schema oas2.Schema) []oas2.OASResponses {
 		oas2.GetOASResponses(schema, "GetUpstream", 200, &GetUpstreamResponse{}),
 		oas2.GetOASResponses(schema, "UpdateUpstream", 200, &UpdateUpstreamResponse{}),
 		oas2.GetOASResponses(schema, "ListUpstreams", 200, &ListUpstreamsResponse{}),
+		oas2.GetOASResponses(schema, "RenameRoute", 200, &RenameRouteResponse{}),
+		oas2.GetOASResponses(schema, "RenameService", 200, &RenameServiceResponse{}),
 	}
 }
 
 func getDefinitions(schema oas2.Schema) map[string]oas2.Definition {
 	defs := make(map[string]oas2.Definition)
 
+	oas2.AddDefinition(defs, "BatchRequestBody", reflect.ValueOf((&BatchRequest{}).Ops))
+	oas2.AddResponseDefinitions(defs, schema, "Batch", 200, (&BatchResponse{}).Body())
+
 	oas2.AddDefinition(defs, "CreatePluginRequestBody", reflect.ValueOf((&CreatePluginRequest{}).P))
 	oas2.AddResponseDefinitions(defs, schema, "CreatePlugin", 200, (&CreatePluginResponse{}).Body())
 
@@ -612,6 +678,12 @@ func getDefinitions(schema oas2.Schema) map[string]oas2.Definition {
 
 	oas2.AddResponseDefinitions(defs, schema, "ListUpstreams", 200, (&ListUpstreamsResponse{}).Body())
 
+	oas2.AddDefinition(defs, "RenameRouteRequestBody", reflect.ValueOf(&RenameRouteRequest{}))
+	oas2.AddResponseDefinitions(defs, schema, "RenameRoute", 200, (&RenameRouteResponse{}).Body())
+
+	oas2.AddDefinition(defs, "RenameServiceRequestBody", reflect.ValueOf(&RenameServiceRequest{}))
+	oas2.AddResponseDefinitions(defs, schema, "RenameService", 200, (&RenameServiceResponse{}).Body())
+
 	oas2.AddDefinition(defs, "UpdatePluginRequestBody", reflect.ValueOf((&UpdatePluginRequest{}).Plugin))
 	oas2.AddResponseDefinitions(defs, schema, "UpdatePlugin", 200, (&UpdatePluginResponse{}).Body())
 
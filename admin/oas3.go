@@ -0,0 +1,788 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+)
+
+// OpenAPIVersion is the version of the OpenAPI Specification, which the
+// document served by NewOpenAPIHandler conforms to.
+const OpenAPIVersion = "3.1.0"
+
+// oasParam is a query parameter of an operation. Path parameters are
+// derived from the path.
+type oasParam struct {
+	name        string
+	typ         string
+	description string
+}
+
+// oasOperation describes an operation of the Admin API, as well as the
+// extra endpoints mounted by cmd/olaf.
+type oasOperation struct {
+	method  string
+	paths   []string // The first one is the primary path, the others are the alternate ones.
+	id      string
+	summary string
+	params  []oasParam
+
+	// The request body, whose schema is derived from its type.
+	body interface{}
+
+	status int
+	// The response body, whose schema is derived from its type. If it is a
+	// string, it is the media type of a streaming response instead.
+	response interface{}
+
+	// The codes of the errors, other than the common ones (see
+	// oasCommonErrors), which the operation may return.
+	errors []string
+
+	// Whether the operation is served without authentication (and
+	// authorization), i.e. it does not return the common errors.
+	public bool
+	// Whether the operation responds with 503, and the same body as the one
+	// of the success response, if the Admin API is not ready.
+	unavailable bool
+}
+
+var (
+	// The errors that all the operations may return.
+	oasCommonErrors = []string{CodeUnauthenticated, CodeForbidden, CodeInternal}
+
+	oasCreateErrors = []string{CodeInvalid, CodeAlreadyExists, CodeBrokenReferences, CodeNotFound}
+	oasUpdateErrors = []string{CodeInvalid, CodeBrokenReferences, CodeNotFound}
+	oasDeleteErrors = []string{CodeInvalid, CodeNotFound, CodeHasDependents}
+)
+
+// oasErrors describes the error codes. The statuses must be consistent with
+// NewError, and the fields are the ones that the errors always have.
+var oasErrors = []struct {
+	code   string
+	status int
+	schema string
+	fields []string
+}{
+	{CodeInvalid, http.StatusBadRequest, "ErrorInvalid", nil},
+	{CodeAlreadyExists, http.StatusBadRequest, "ErrorAlreadyExists", []string{"kind", "name"}},
+	{CodeBrokenReferences, http.StatusBadRequest, "ErrorBrokenReferences", []string{"references"}},
+	{CodeUnauthenticated, http.StatusUnauthorized, "ErrorUnauthenticated", nil},
+	{CodeForbidden, http.StatusForbidden, "ErrorForbidden", []string{"forbidden"}},
+	{CodeNotFound, http.StatusNotFound, "ErrorNotFound", []string{"kind"}},
+	{CodeNotImplemented, http.StatusMethodNotAllowed, "ErrorNotImplemented", nil},
+	{CodeHasDependents, http.StatusConflict, "ErrorHasDependents", []string{"kind", "name", "dependents"}},
+	{CodeRevisionCompacted, http.StatusGone, "ErrorRevisionCompacted", nil},
+	{CodeInternal, http.StatusInternalServerError, "ErrorInternal", nil},
+}
+
+var oasOperations = []*oasOperation{
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/config"},
+		id:       "GetConfig",
+		summary:  "Get the whole config. In YAML, the config is in the declarative layout of the YAML store.",
+		status:   http.StatusOK,
+		response: (*olaf.Data)(nil),
+	},
+	{
+		method:   http.MethodPost,
+		paths:    []string{"/services"},
+		id:       "CreateService",
+		summary:  "Create a service.",
+		body:     (*olaf.Service)(nil),
+		status:   http.StatusOK,
+		response: struct{}{},
+		errors:   oasCreateErrors,
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/services"},
+		id:       "ListServices",
+		summary:  "List all the services, which are not paginated.",
+		status:   http.StatusOK,
+		response: []*olaf.Service(nil),
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/services/{serviceName}", "/routes/{routeName}/service"},
+		id:       "GetService",
+		summary:  "Get a service, or the service of a route.",
+		status:   http.StatusOK,
+		response: (*olaf.Service)(nil),
+		errors:   []string{CodeNotFound},
+	},
+	{
+		method:   http.MethodPut,
+		paths:    []string{"/services/{serviceName}", "/routes/{routeName}/service"},
+		id:       "UpdateService",
+		summary:  "Update a service, or the service of a route.",
+		body:     (*olaf.Service)(nil),
+		status:   http.StatusOK,
+		response: struct{}{},
+		errors:   oasUpdateErrors,
+	},
+	{
+		method:  http.MethodDelete,
+		paths:   []string{"/services/{serviceName}", "/routes/{routeName}/service"},
+		id:      "DeleteService",
+		summary: "Delete a service, or the service of a route.",
+		params: []oasParam{
+			{"cascade", "boolean", "Whether to also delete the routes and plugins of the service."},
+		},
+		status: http.StatusNoContent,
+		errors: oasDeleteErrors,
+	},
+	{
+		method:   http.MethodPost,
+		paths:    []string{"/services/{serviceName}/rename"},
+		id:       "RenameService",
+		summary:  "Rename a service and rewrite all the references to it.",
+		body:     (*RenameServiceRequest)(nil),
+		status:   http.StatusOK,
+		response: []*olaf.Change(nil),
+		errors:   []string{CodeInvalid, CodeAlreadyExists, CodeNotFound},
+	},
+	{
+		method:   http.MethodPost,
+		paths:    []string{"/routes", "/services/{serviceName}/routes"},
+		id:       "CreateRoute",
+		summary:  "Create a route, or a route of a service.",
+		body:     (*olaf.Route)(nil),
+		status:   http.StatusOK,
+		response: struct{}{},
+		errors:   oasCreateErrors,
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/routes", "/services/{serviceName}/routes"},
+		id:       "ListRoutes",
+		summary:  "List all the routes, or the routes of a service, which are not paginated.",
+		status:   http.StatusOK,
+		response: []*olaf.Route(nil),
+		errors:   []string{CodeNotFound},
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/routes/{routeName}", "/services/{serviceName}/routes/{routeName}"},
+		id:       "GetRoute",
+		summary:  "Get a route.",
+		status:   http.StatusOK,
+		response: (*olaf.Route)(nil),
+		errors:   []string{CodeNotFound},
+	},
+	{
+		method:   http.MethodPut,
+		paths:    []string{"/routes/{routeName}", "/services/{serviceName}/routes/{routeName}"},
+		id:       "UpdateRoute",
+		summary:  "Update a route.",
+		body:     (*olaf.Route)(nil),
+		status:   http.StatusOK,
+		response: struct{}{},
+		errors:   oasUpdateErrors,
+	},
+	{
+		method:  http.MethodDelete,
+		paths:   []string{"/routes/{routeName}", "/services/{serviceName}/routes/{routeName}"},
+		id:      "DeleteRoute",
+		summary: "Delete a route.",
+		params: []oasParam{
+			{"cascade", "boolean", "Whether to also delete the plugins of the route."},
+		},
+		status: http.StatusNoContent,
+		errors: oasDeleteErrors,
+	},
+	{
+		method:   http.MethodPost,
+		paths:    []string{"/routes/{routeName}/rename"},
+		id:       "RenameRoute",
+		summary:  "Rename a route and rewrite all the references to it.",
+		body:     (*RenameRouteRequest)(nil),
+		status:   http.StatusOK,
+		response: []*olaf.Change(nil),
+		errors:   []string{CodeInvalid, CodeAlreadyExists, CodeNotFound},
+	},
+	{
+		method:   http.MethodPost,
+		paths:    []string{"/plugins", "/routes/{routeName}/plugins", "/services/{serviceName}/plugins"},
+		id:       "CreatePlugin",
+		summary:  "Create a global plugin, or a plugin of a route or a service.",
+		body:     (*olaf.Plugin)(nil),
+		status:   http.StatusOK,
+		response: (*olaf.Plugin)(nil),
+		errors:   oasCreateErrors,
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/plugins", "/routes/{routeName}/plugins", "/services/{serviceName}/plugins"},
+		id:       "ListPlugins",
+		summary:  "List all the plugins, or the plugins of a route or a service, which are not paginated.",
+		status:   http.StatusOK,
+		response: []*olaf.Plugin(nil),
+		errors:   []string{CodeNotFound},
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/plugins/{pluginName}", "/routes/{routeName}/plugins/{pluginName}", "/services/{serviceName}/plugins/{pluginName}"},
+		id:       "GetPlugin",
+		summary:  "Get a plugin.",
+		status:   http.StatusOK,
+		response: (*olaf.Plugin)(nil),
+		errors:   []string{CodeNotFound},
+	},
+	{
+		method:   http.MethodPut,
+		paths:    []string{"/plugins/{pluginName}", "/routes/{routeName}/plugins/{pluginName}", "/services/{serviceName}/plugins/{pluginName}"},
+		id:       "UpdatePlugin",
+		summary:  "Update a plugin.",
+		body:     (*olaf.Plugin)(nil),
+		status:   http.StatusOK,
+		response: struct{}{},
+		errors:   oasUpdateErrors,
+	},
+	{
+		method:  http.MethodDelete,
+		paths:   []string{"/plugins/{pluginName}", "/routes/{routeName}/plugins/{pluginName}", "/services/{serviceName}/plugins/{pluginName}"},
+		id:      "DeletePlugin",
+		summary: "Delete a plugin.",
+		status:  http.StatusNoContent,
+		errors:  []string{CodeNotFound},
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/upstreams"},
+		id:       "ListUpstreams",
+		summary:  "List all the upstreams, which are not paginated.",
+		status:   http.StatusOK,
+		response: []*olaf.Upstream(nil),
+		errors:   []string{CodeNotImplemented},
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/upstreams/{upstreamName}", "/services/{serviceName}/upstream"},
+		id:       "GetUpstream",
+		summary:  "Get an upstream, or the upstream of a service.",
+		status:   http.StatusOK,
+		response: (*olaf.Upstream)(nil),
+		errors:   []string{CodeNotFound, CodeNotImplemented},
+	},
+	{
+		method:   http.MethodPut,
+		paths:    []string{"/upstreams/{upstreamName}", "/services/{serviceName}/upstream"},
+		id:       "UpdateUpstream",
+		summary:  "Update an upstream, or the upstream of a service.",
+		body:     (*olaf.Upstream)(nil),
+		status:   http.StatusOK,
+		response: struct{}{},
+		errors:   append([]string{CodeNotImplemented}, oasUpdateErrors...),
+	},
+	{
+		method:   http.MethodPost,
+		paths:    []string{"/batch"},
+		id:       "Batch",
+		summary:  "Apply all the operations in order, or none of them if any fails. The error reports the index of the failed operation.",
+		body:     []*olaf.Operation(nil),
+		status:   http.StatusOK,
+		response: struct{}{},
+		errors:   append([]string{CodeHasDependents}, oasCreateErrors...),
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/events"},
+		id:       "WatchEvents",
+		summary:  "Stream the events of config changes, as server-sent events whose data are Event objects.",
+		status:   http.StatusOK,
+		response: "text/event-stream",
+		errors:   []string{CodeInvalid, CodeRevisionCompacted},
+	},
+	{
+		method:   http.MethodPost,
+		paths:    []string{"/webhooks"},
+		id:       "CreateWebhook",
+		summary:  "Register a webhook.",
+		body:     (*Webhook)(nil),
+		status:   http.StatusCreated,
+		response: (*Webhook)(nil),
+		errors:   []string{CodeInvalid},
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/webhooks"},
+		id:       "ListWebhooks",
+		summary:  "List all the webhooks, which are not paginated.",
+		status:   http.StatusOK,
+		response: []*Webhook(nil),
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/webhooks/{id}"},
+		id:       "GetWebhook",
+		summary:  "Get a webhook.",
+		status:   http.StatusOK,
+		response: (*Webhook)(nil),
+		errors:   []string{CodeNotFound},
+	},
+	{
+		method:  http.MethodDelete,
+		paths:   []string{"/webhooks/{id}"},
+		id:      "DeleteWebhook",
+		summary: "Delete a webhook.",
+		status:  http.StatusNoContent,
+		errors:  []string{CodeNotFound},
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/webhooks/{id}/deliveries"},
+		id:       "ListWebhookDeliveries",
+		summary:  "List the recent deliveries of a webhook, oldest first.",
+		status:   http.StatusOK,
+		response: []*WebhookDelivery(nil),
+		errors:   []string{CodeNotFound},
+	},
+	{
+		method:  http.MethodGet,
+		paths:   []string{"/audit"},
+		id:      "QueryAudit",
+		summary: "Query the audit log (if enabled), oldest first.",
+		params: []oasParam{
+			{"identity", "string", "Only the entries of the identity."},
+			{"request_id", "string", "Only the entries of the request."},
+			{"kind", "string", "Only the entries of the entity kind."},
+			{"name", "string", "Only the entries of the entity name."},
+			{"since", "integer", "Only the entries at or after the Unix time."},
+			{"until", "integer", "Only the entries at or before the Unix time."},
+			{"limit", "integer", "The maximum number of entries to return, which are the most recent ones. Zero means no limit."},
+		},
+		status:   http.StatusOK,
+		response: []*AuditEntry(nil),
+		errors:   []string{CodeInvalid},
+	},
+	{
+		method:  http.MethodGet,
+		paths:   []string{"/validate"},
+		id:      "ValidateConfig",
+		summary: "Validate the current config, reporting the broken references, the problems found while building the Caddy routes, and the routes that can never match.",
+		params: []oasParam{
+			{"auto_priority", "boolean", "Whether the routes are ordered automatically by the specificity of their matchers."},
+		},
+		status:   http.StatusOK,
+		response: (*Validation)(nil),
+		errors:   []string{CodeInvalid},
+	},
+	{
+		method:  http.MethodPost,
+		paths:   []string{"/validate"},
+		id:      "ValidateGivenConfig",
+		summary: "Validate the given config, which is in the same form as the one returned by GetConfig.",
+		params: []oasParam{
+			{"auto_priority", "boolean", "Whether the routes are ordered automatically by the specificity of their matchers."},
+		},
+		body:     (*olaf.Data)(nil),
+		status:   http.StatusOK,
+		response: (*Validation)(nil),
+		errors:   []string{CodeInvalid},
+	},
+	{
+		method:  http.MethodPost,
+		paths:   []string{"/simulate"},
+		id:      "SimulateRequest",
+		summary: "Find the route handling the given request per the current config, and report the applied plugins, whether the canary matches, the target service and the rewritten path.",
+		params: []oasParam{
+			{"auto_priority", "boolean", "Whether the routes are ordered automatically by the specificity of their matchers."},
+		},
+		body:     (*builder.Request)(nil),
+		status:   http.StatusOK,
+		response: (*builder.Simulation)(nil),
+		errors:   []string{CodeInvalid},
+	},
+	{
+		method:  http.MethodGet,
+		paths:   []string{"/status"},
+		id:      "GetStatus",
+		summary: "Get the status of the Admin API, including the revision of the config, the hash of the Caddy routes built from it, the entity counts, and the build version.",
+		params: []oasParam{
+			{"auto_priority", "boolean", "Whether the routes are ordered automatically by the specificity of their matchers."},
+		},
+		status:   http.StatusOK,
+		response: (*Status)(nil),
+		errors:   []string{CodeInvalid},
+		public:   true,
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/status/live"},
+		id:       "GetLiveness",
+		summary:  "Check whether the Admin API is live.",
+		status:   http.StatusOK,
+		response: (*Status)(nil),
+		public:   true,
+	},
+	{
+		method:      http.MethodGet,
+		paths:       []string{"/status/ready"},
+		id:          "GetReadiness",
+		summary:     "Check whether the Admin API is ready, i.e. the store can serve a valid config.",
+		status:      http.StatusOK,
+		response:    (*Status)(nil),
+		public:      true,
+		unavailable: true,
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/metrics"},
+		id:       "GetMetrics",
+		summary:  "Get the metrics in the Prometheus text format.",
+		status:   http.StatusOK,
+		response: "text/plain",
+		public:   true,
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/openapi.json"},
+		id:       "GetOpenAPIDocument",
+		summary:  "Get the OpenAPI 3.1 document of the Admin API.",
+		status:   http.StatusOK,
+		response: map[string]interface{}(nil),
+	},
+	{
+		method:   http.MethodGet,
+		paths:    []string{"/api"},
+		id:       "GetSwaggerDocument",
+		summary:  "Get the Swagger 2.0 document of the Admin API, which is superseded by /openapi.json.",
+		status:   http.StatusOK,
+		response: map[string]interface{}(nil),
+	},
+}
+
+// oasEnums holds the allowed values of the fields, keyed by "Type.field".
+func oasEnums() map[string][]string {
+	return map[string][]string{
+		"Plugin.type":           PluginTypes,
+		"LoadBalancing.policy":  lbPolicies,
+		"Matcher.methods":       methods,
+		"Operation.op":          {olaf.OpCreate, olaf.OpUpdate, olaf.OpDelete, olaf.OpRename},
+		"Operation.kind":        {olaf.KindService, olaf.KindRoute, olaf.KindPlugin},
+		"Event.type":            {olaf.EventCreate, olaf.EventUpdate, olaf.EventDelete},
+		"Event.kind":            {olaf.KindService, olaf.KindRoute, olaf.KindPlugin},
+		"Webhook.kinds":         {olaf.KindService, olaf.KindRoute, olaf.KindPlugin},
+		"AuditEntry.op":         {olaf.OpCreate, olaf.OpUpdate, olaf.OpDelete, olaf.OpRename},
+		"Error.code":            oasErrorCodes(),
+		"ForbiddenError.verb":   {VerbRead, VerbCreate, VerbUpdate, VerbDelete},
+		"Rule.effect":           {EffectAllow, EffectDeny},
+		"WebhookDelivery.event": {olaf.EventCreate, olaf.EventUpdate, olaf.EventDelete},
+	}
+}
+
+func oasErrorCodes() (codes []string) {
+	for _, e := range oasErrors {
+		codes = append(codes, e.code)
+	}
+	return codes
+}
+
+// OASv3APIDoc returns the OpenAPI 3.1 document of the Admin API, including
+// the extra endpoints mounted by cmd/olaf.
+//
+// The schemas are derived from the Go types, which are encoded as JSON. Since
+// the alternate paths of an operation (e.g. /routes/{routeName}/service for
+// GetService) are separate path items, their operation IDs are numbered in
+// the same way as the ones in OASv2APIDoc.
+func OASv3APIDoc() map[string]interface{} {
+	g := &oasGenerator{
+		schemas: make(map[string]interface{}),
+		enums:   oasEnums(),
+	}
+
+	paths := make(map[string]interface{})
+	for _, op := range oasOperations {
+		for i, path := range op.paths {
+			item, ok := paths[path].(map[string]interface{})
+			if !ok {
+				item = make(map[string]interface{})
+				paths[path] = item
+			}
+			id := op.id
+			if i > 0 {
+				id += strconv.Itoa(i)
+			}
+			item[strings.ToLower(op.method)] = g.operation(op, path, id)
+		}
+	}
+
+	g.schemaOf(reflect.TypeOf(Error{}))
+	for _, e := range oasErrors {
+		g.schemas[e.schema] = g.errorSchema(e.code, e.status, e.fields)
+	}
+	mapping := make(map[string]interface{})
+	var oneOf []interface{}
+	for _, e := range oasErrors {
+		mapping[e.code] = oasRef(e.schema)
+		oneOf = append(oneOf, map[string]interface{}{"$ref": oasRef(e.schema)})
+	}
+	g.schemas["AnyError"] = map[string]interface{}{
+		"oneOf": oneOf,
+		"discriminator": map[string]interface{}{
+			"propertyName": "code",
+			"mapping":      mapping,
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "Olaf Admin API",
+			"version":     "1.0.0",
+			"description": "The Admin API of Olaf. Request and response bodies are JSON, or YAML if requested by Content-Type and Accept (application/yaml).",
+			"license": map[string]interface{}{
+				"name":       "MIT",
+				"identifier": "MIT",
+			},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+		},
+	}
+}
+
+// NewOpenAPIHandler returns a handler serving the document returned by
+// OASv3APIDoc.
+func NewOpenAPIHandler() http.Handler {
+	doc := OASv3APIDoc()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Codec{}.EncodeSuccessResponse(w, http.StatusOK, doc) // nolint:errcheck
+	})
+}
+
+var rePathParam = regexp.MustCompile(`{(\w+)}`)
+
+type oasGenerator struct {
+	schemas map[string]interface{}
+	enums   map[string][]string
+}
+
+func (g *oasGenerator) operation(op *oasOperation, path, id string) map[string]interface{} {
+	var params []interface{}
+	for _, m := range rePathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range op.params {
+		params = append(params, map[string]interface{}{
+			"name":        p.name,
+			"in":          "query",
+			"description": p.description,
+			"schema":      map[string]interface{}{"type": p.typ},
+		})
+	}
+
+	responses := map[string]interface{}{}
+	success := map[string]interface{}{"description": http.StatusText(op.status)}
+	switch resp := op.response.(type) {
+	case nil:
+	case string:
+		success["content"] = map[string]interface{}{
+			resp: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+		}
+	default:
+		success["content"] = oasContent(g.schemaOf(reflect.TypeOf(resp)))
+	}
+	responses[strconv.Itoa(op.status)] = success
+	if op.unavailable {
+		responses[strconv.Itoa(http.StatusServiceUnavailable)] = map[string]interface{}{
+			"description": http.StatusText(http.StatusServiceUnavailable),
+			"content":     success["content"],
+		}
+	}
+
+	// Group the errors by their statuses.
+	errs := make(map[int][]interface{})
+	for _, e := range oasErrors {
+		if containsString(op.errors, e.code) || (!op.public && containsString(oasCommonErrors, e.code)) {
+			errs[e.status] = append(errs[e.status], map[string]interface{}{"$ref": oasRef(e.schema)})
+		}
+	}
+	for status, schemas := range errs {
+		schema := schemas[0]
+		if len(schemas) > 1 {
+			schema = map[string]interface{}{"oneOf": schemas}
+		}
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     oasContent(schema),
+		}
+	}
+
+	operation := map[string]interface{}{
+		"operationId": id,
+		"summary":     op.summary,
+		"responses":   responses,
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	if op.body != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  oasContent(g.schemaOf(reflect.TypeOf(op.body))),
+		}
+	}
+	return operation
+}
+
+// errorSchema returns the schema of the Error with the given code.
+func (g *oasGenerator) errorSchema(code string, status int, fields []string) interface{} {
+	required := []string{"code", "status", "error"}
+	return map[string]interface{}{
+		"allOf": []interface{}{
+			map[string]interface{}{"$ref": oasRef("Error")},
+			map[string]interface{}{
+				"properties": map[string]interface{}{
+					"code":   map[string]interface{}{"const": code},
+					"status": map[string]interface{}{"const": status},
+				},
+				"required": append(required, fields...),
+			},
+		},
+	}
+}
+
+// schemaOf returns the schema of the JSON encoding of t. The named structs
+// are added to the components, and are referred to by their names.
+func (g *oasGenerator) schemaOf(t reflect.Type) interface{} {
+	if t == reflect.TypeOf(json.RawMessage(nil)) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return oasNullable(g.schemaOf(t.Elem()))
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": g.schemaOf(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": g.schemaOf(t.Elem()),
+		}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, "")
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = nil // Break the cycles.
+			g.schemas[t.Name()] = g.structSchema(t, t.Name())
+		}
+		return map[string]interface{}{"$ref": oasRef(t.Name())}
+	}
+	return map[string]interface{}{}
+}
+
+func (g *oasGenerator) structSchema(t reflect.Type, name string) interface{} {
+	properties := make(map[string]interface{})
+	g.addProperties(properties, t, name)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// addProperties adds the fields of t to properties, in the same way as
+// encoding/json, where the fields of the embedded structs are promoted.
+func (g *oasGenerator) addProperties(properties map[string]interface{}, t reflect.Type, name string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		fieldName := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && fieldName == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				// The enums of the promoted fields are keyed by the embedded type.
+				g.addProperties(properties, ft, ft.Name())
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if fieldName == "" {
+			fieldName = f.Name
+		}
+
+		schema := g.schemaOf(f.Type)
+		if enum, ok := g.enums[name+"."+fieldName]; ok {
+			schema = oasEnum(schema, enum)
+		}
+		properties[fieldName] = schema
+	}
+}
+
+// oasEnum restricts schema, or its items if it is an array, to enum.
+func oasEnum(schema interface{}, enum []string) interface{} {
+	m := schema.(map[string]interface{})
+	if items, ok := m["items"]; ok {
+		m["items"] = oasEnum(items, enum)
+		return m
+	}
+	m["enum"] = enum
+	return m
+}
+
+// oasNullable allows schema to be null.
+func oasNullable(schema interface{}) interface{} {
+	m := schema.(map[string]interface{})
+	switch typ := m["type"].(type) {
+	case string:
+		m["type"] = []string{typ, "null"}
+		return m
+	case []string:
+		return m // already nullable
+	}
+	if _, ok := m["$ref"]; ok {
+		return map[string]interface{}{
+			"oneOf": []interface{}{m, map[string]interface{}{"type": "null"}},
+		}
+	}
+	return m
+}
+
+func oasContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+		MediaTypeYAML:      map[string]interface{}{"schema": schema},
+	}
+}
+
+func oasRef(name string) string {
+	return "#/components/schemas/" + name
+}
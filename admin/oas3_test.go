@@ -0,0 +1,188 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/go-chi/chi"
+)
+
+// newTestRouter returns the router of the Admin API, with the extra endpoints
+// mounted in the same way as cmd/olaf.
+func newTestRouter() chi.Router {
+	events := olaf.NewEventLog(10)
+	router := NewHTTPRouter(nil, NewCodecs(Codec{}))
+	router.Method("GET", "/openapi.json", NewOpenAPIHandler())
+	router.Method("GET", "/events", NewEventsHandler(events, nil, nil))
+	router.Mount("/webhooks", NewWebhooks(events, nil).Handler(nil, nil))
+	router.Method("GET", "/audit", NewAuditHandler(nil, nil))
+	router.Mount("/validate", NewValidationHandler(nil))
+	router.Method("POST", "/simulate", NewSimulationHandler(nil))
+	router.Mount("/status", NewStatusHandler(nil, nil, ""))
+	router.Method("GET", "/metrics", NewMetrics(nil, nil).Handler())
+	return router
+}
+
+func TestOASv3APIDoc_Router(t *testing.T) {
+	var routes []string
+	if err := chi.Walk(newTestRouter(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routes = append(routes, method+" "+route)
+		return nil
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Strings(routes)
+
+	var documented []string
+	for path, item := range OASv3APIDoc()["paths"].(map[string]interface{}) {
+		for method := range item.(map[string]interface{}) {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(documented)
+
+	if !reflect.DeepEqual(documented, routes) {
+		t.Fatalf("Operations: got (%v), want (%v)", documented, routes)
+	}
+}
+
+func TestOASv3APIDoc(t *testing.T) {
+	server := httptest.NewServer(newTestRouter())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+	var doc map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if doc["openapi"] != OpenAPIVersion {
+		t.Fatalf("OpenAPI: got (%v), want (%v)", doc["openapi"], OpenAPIVersion)
+	}
+
+	// All the references must be resolved.
+	schemas := lookup(doc, "components", "schemas").(map[string]interface{})
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				if _, ok := schemas[strings.TrimPrefix(ref, "#/components/schemas/")]; !ok {
+					t.Fatalf("Reference: got (%s), want an existing schema", ref)
+				}
+			}
+			for _, value := range v {
+				walk(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(doc)
+
+	cases := []struct {
+		name   string
+		inKeys []string
+		want   interface{}
+	}{
+		{
+			name:   "alternate path",
+			inKeys: []string{"paths", "/routes/{routeName}/service", "get", "operationId"},
+			want:   "GetService1",
+		},
+		{
+			name:   "path parameter",
+			inKeys: []string{"paths", "/services/{serviceName}/routes/{routeName}", "put", "parameters"},
+			want: []interface{}{
+				map[string]interface{}{"name": "serviceName", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+				map[string]interface{}{"name": "routeName", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+			},
+		},
+		{
+			name:   "oneOf errors",
+			inKeys: []string{"paths", "/services", "post", "responses", "400", "content", "application/json", "schema"},
+			want: map[string]interface{}{"oneOf": []interface{}{
+				map[string]interface{}{"$ref": "#/components/schemas/ErrorInvalid"},
+				map[string]interface{}{"$ref": "#/components/schemas/ErrorAlreadyExists"},
+				map[string]interface{}{"$ref": "#/components/schemas/ErrorBrokenReferences"},
+			}},
+		},
+		{
+			name:   "single error",
+			inKeys: []string{"paths", "/routes/{routeName}", "get", "responses", "404", "content", "application/json", "schema"},
+			want:   map[string]interface{}{"$ref": "#/components/schemas/ErrorNotFound"},
+		},
+		{
+			name:   "list",
+			inKeys: []string{"paths", "/routes", "get", "responses", "200", "content", "application/json", "schema", "items"},
+			want: map[string]interface{}{"oneOf": []interface{}{
+				map[string]interface{}{"$ref": "#/components/schemas/Route"},
+				map[string]interface{}{"type": "null"},
+			}},
+		},
+		{
+			name:   "pagination",
+			inKeys: []string{"paths", "/audit", "get", "parameters", "6", "name"},
+			want:   "limit",
+		},
+		{
+			name:   "promoted field",
+			inKeys: []string{"components", "schemas", "Route", "properties", "strip_prefix", "type"},
+			want:   "string",
+		},
+		{
+			name:   "enum",
+			inKeys: []string{"components", "schemas", "Plugin", "properties", "type", "enum"},
+			want:   []interface{}{"canary", "traffic_split", "mirror", "fault", "request_body_var", "rate_limit"},
+		},
+		{
+			name:   "error variant",
+			inKeys: []string{"components", "schemas", "ErrorHasDependents", "allOf", "1", "required"},
+			want:   []interface{}{"code", "status", "error", "kind", "name", "dependents"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := lookup(doc, c.inKeys...)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Value: got (%#v), want (%#v)", got, c.want)
+			}
+		})
+	}
+}
+
+// lookup returns the value in v at the given keys (or indexes).
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			v = x[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(x) {
+				return nil
+			}
+			v = x[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
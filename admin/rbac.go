@@ -0,0 +1,815 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/RussellLuo/olaf"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Verbs of the admin operations.
+const (
+	VerbRead   = "read"
+	VerbCreate = "create"
+	VerbUpdate = "update"
+	VerbDelete = "delete"
+)
+
+// KindConfig is the kind of the whole configuration (see Admin.GetConfig).
+const KindConfig = "config"
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Rule allows (or denies) some verbs on some kinds of entities, within the
+// scope of some services.
+type Rule struct {
+	// "allow" (the default) or "deny".
+	Effect string `json:"effect,omitempty" yaml:"effect"`
+	// The entity kinds, "*" for all kinds.
+	Kinds []string `json:"kinds" yaml:"kinds"`
+	// The verbs, "*" for all verbs.
+	Verbs []string `json:"verbs" yaml:"verbs"`
+
+	// The scope of the rule. An entity is within the scope if the name of
+	// its service matches any of the patterns (see path.Match), or the
+	// service has any of the tags. If both are empty, the scope includes
+	// all the entities, as well as the ones not belonging to any service
+	// (e.g. global plugins).
+	Services []string `json:"services,omitempty" yaml:"services"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags"`
+}
+
+func (r *Rule) matches(verb, kind string, svc *olaf.Service) bool {
+	if !containsString(r.Kinds, "*") && !containsString(r.Kinds, kind) {
+		return false
+	}
+	if !containsString(r.Verbs, "*") && !containsString(r.Verbs, verb) {
+		return false
+	}
+
+	if len(r.Services) == 0 && len(r.Tags) == 0 {
+		return true
+	}
+	if svc == nil {
+		return false
+	}
+	for _, pattern := range r.Services {
+		if ok, _ := path.Match(pattern, svc.Name); ok {
+			return true
+		}
+	}
+	for _, tag := range r.Tags {
+		if containsString(svc.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the rule in a human-readable form.
+func (r *Rule) String() string {
+	effect := r.Effect
+	if effect == "" {
+		effect = EffectAllow
+	}
+	s := fmt.Sprintf("%s %s on %s", effect, strings.Join(r.Verbs, ","), strings.Join(r.Kinds, ","))
+	if len(r.Services) > 0 {
+		s += fmt.Sprintf(" of services %s", strings.Join(r.Services, ","))
+	}
+	if len(r.Tags) > 0 {
+		s += fmt.Sprintf(" tagged %s", strings.Join(r.Tags, ","))
+	}
+	return s
+}
+
+type Role struct {
+	Name  string  `yaml:"name"`
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Binding grants a role to some identities (see Authenticator), "*" for all
+// authenticated identities.
+type Binding struct {
+	Role       string   `yaml:"role"`
+	Identities []string `yaml:"identities"`
+}
+
+// Policy holds the roles and their bindings.
+type Policy struct {
+	Roles    []*Role    `yaml:"roles"`
+	Bindings []*Binding `yaml:"bindings"`
+}
+
+// LoadPolicy loads a policy from a YAML file.
+func LoadPolicy(filename string) (*Policy, error) {
+	c, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(Policy)
+	if err := yaml.Unmarshal(c, p); err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]bool)
+	for _, role := range p.Roles {
+		roles[role.Name] = true
+		for i, rule := range role.Rules {
+			if rule.Effect != "" && rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+				return nil, fmt.Errorf("rule %d of role %q: unknown effect %q", i, role.Name, rule.Effect)
+			}
+		}
+	}
+	for _, b := range p.Bindings {
+		if !roles[b.Role] {
+			return nil, fmt.Errorf("binding: role %q not found", b.Role)
+		}
+	}
+
+	return p, nil
+}
+
+// Authorize checks whether identity is allowed to perform verb on the entity
+// of the given kind and name, which belongs to svc (nil if none). Deny rules
+// take precedence over allow rules, and nothing is allowed by default.
+func (p *Policy) Authorize(identity, verb, kind, name string, svc *olaf.Service) error {
+	var roles []*Role
+	for _, b := range p.Bindings {
+		if containsString(b.Identities, identity) || containsString(b.Identities, "*") {
+			for _, role := range p.Roles {
+				if role.Name == b.Role {
+					roles = append(roles, role)
+				}
+			}
+		}
+	}
+
+	allowed := false
+	for _, role := range roles {
+		for i, rule := range role.Rules {
+			if !rule.matches(verb, kind, svc) {
+				continue
+			}
+			if rule.Effect == EffectDeny {
+				return &ForbiddenError{
+					Identity:  identity,
+					Verb:      verb,
+					Kind:      kind,
+					Name:      name,
+					Role:      role.Name,
+					RuleIndex: i,
+					Rule:      rule,
+				}
+			}
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		return &ForbiddenError{
+			Identity:  identity,
+			Verb:      verb,
+			Kind:      kind,
+			Name:      name,
+			RuleIndex: -1,
+		}
+	}
+	return nil
+}
+
+// ForbiddenError reports a request denied by the policy.
+type ForbiddenError struct {
+	Identity string `json:"identity"`
+	Verb     string `json:"verb"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name,omitempty"`
+
+	// The rule that denied the request. If no rule allowed the request,
+	// Role is empty and RuleIndex is -1.
+	Role      string `json:"role,omitempty"`
+	RuleIndex int    `json:"rule_index"`
+	Rule      *Rule  `json:"rule,omitempty"`
+}
+
+func (e *ForbiddenError) Error() string {
+	target := e.Kind
+	if e.Name != "" {
+		target = fmt.Sprintf("%s %q", e.Kind, e.Name)
+	}
+	msg := fmt.Sprintf("%q is not allowed to %s %s", e.Identity, e.Verb, target)
+	if e.Rule == nil {
+		return msg + ": no rule allows it"
+	}
+	return fmt.Sprintf("%s: denied by rule %d of role %q (%s)", msg, e.RuleIndex, e.Role, e.Rule)
+}
+
+func (e *ForbiddenError) Unwrap() error { return ErrForbidden }
+
+// NewRBAC returns an Admin, which authorizes every call against policy before
+// passing it to next. The identity of the caller is got from the context (see
+// AuthMiddleware). List operations only return the entities that the caller
+// is allowed to read.
+func NewRBAC(policy *Policy, next Admin) Admin {
+	return &rbac{policy: policy, next: next}
+}
+
+type rbac struct {
+	policy *Policy
+	next   Admin
+}
+
+func (a *rbac) authorize(ctx context.Context, verb, kind, name string, svc *olaf.Service) error {
+	identity, ok := IdentityFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	return a.policy.Authorize(identity, verb, kind, name, svc)
+}
+
+// service returns the service with the given name (or ID). If not found, nil
+// is returned, which is only within the scope of the unscoped rules.
+func (a *rbac) service(ctx context.Context, serviceName string) *olaf.Service {
+	if serviceName == "" {
+		return nil
+	}
+	svc, err := a.next.GetService(ctx, serviceName, "")
+	if err != nil {
+		return nil
+	}
+	return svc
+}
+
+func (a *rbac) routeService(ctx context.Context, routeName string) *olaf.Service {
+	if routeName == "" {
+		return nil
+	}
+	svc, err := a.next.GetService(ctx, "", routeName)
+	if err != nil {
+		return nil
+	}
+	return svc
+}
+
+func (a *rbac) pluginService(ctx context.Context, p *olaf.Plugin) *olaf.Service {
+	if p.ServiceName != "" {
+		return a.service(ctx, p.ServiceName)
+	}
+	return a.routeService(ctx, p.RouteName)
+}
+
+// placeholder returns a service named serviceName, which stands for the
+// service of a requested entity that can not be found (see notFound).
+func placeholder(serviceName string) *olaf.Service {
+	if serviceName == "" {
+		return nil
+	}
+	return &olaf.Service{Name: serviceName}
+}
+
+// notFound authorizes the caller to perform verb on the requested entity,
+// which can not be got (e.g. does not exist), as if it belonged to svc. Thus,
+// err is returned only to the callers within the scope, and the others can
+// not tell whether the entity exists.
+func (a *rbac) notFound(ctx context.Context, verb, kind, name string, svc *olaf.Service, err error) error {
+	if err := a.authorize(ctx, verb, kind, name, svc); err != nil {
+		return err
+	}
+	return err
+}
+
+// authorizeDependents authorizes the caller to delete all the dependents of
+// the entity identified by kind and name, which are deleted along with it.
+func (a *rbac) authorizeDependents(ctx context.Context, kind, name string) error {
+	data, err := a.next.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ref := range olaf.FindDependents(data, kind, name) {
+		if err := a.authorize(ctx, VerbDelete, ref.Kind, ref.Name, dependentService(data, ref)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependentService returns the service, to which the dependent entity belongs.
+func dependentService(data *olaf.Data, ref olaf.EntityRef) *olaf.Service {
+	routeName := ""
+	switch ref.Kind {
+	case olaf.KindRoute:
+		routeName = ref.Name
+	case olaf.KindPlugin:
+		p, ok := data.Plugins[ref.Name]
+		if !ok {
+			return nil
+		}
+		if p.ServiceName != "" {
+			return data.Services[p.ServiceName]
+		}
+		routeName = p.RouteName
+	}
+	if r, ok := data.Routes[routeName]; ok {
+		return data.Services[r.ServiceName]
+	}
+	return nil
+}
+
+func (a *rbac) upstreamService(ctx context.Context, upstreamName, serviceName string) (*olaf.Service, error) {
+	if upstreamName == "" {
+		return a.next.GetService(ctx, serviceName, "")
+	}
+	services, err := a.next.ListServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		if svc.Upstream != nil && svc.Upstream.ID == upstreamName {
+			return svc, nil
+		}
+	}
+	return nil, olaf.NotFoundError(olaf.KindUpstream, upstreamName)
+}
+
+func (a *rbac) GetConfig(ctx context.Context) (data *olaf.Data, err error) {
+	if err := a.authorize(ctx, VerbRead, KindConfig, "", nil); err != nil {
+		return nil, err
+	}
+	return a.next.GetConfig(ctx)
+}
+
+func (a *rbac) CreateService(ctx context.Context, svc *olaf.Service) (err error) {
+	if err := a.authorize(ctx, VerbCreate, olaf.KindService, svc.Name, svc); err != nil {
+		return err
+	}
+	return a.next.CreateService(ctx, svc)
+}
+
+func (a *rbac) ListServices(ctx context.Context) (services []*olaf.Service, err error) {
+	all, err := a.next.ListServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range all {
+		if a.authorize(ctx, VerbRead, olaf.KindService, svc.Name, svc) == nil {
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+func (a *rbac) GetService(ctx context.Context, serviceName, routeName string) (service *olaf.Service, err error) {
+	svc, err := a.next.GetService(ctx, serviceName, routeName)
+	if err != nil {
+		return nil, a.notFound(ctx, VerbRead, olaf.KindService, serviceName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbRead, olaf.KindService, svc.Name, svc); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+func (a *rbac) UpdateService(ctx context.Context, serviceName, routeName string, svc *olaf.Service) (err error) {
+	old, err := a.next.GetService(ctx, serviceName, routeName)
+	if err != nil {
+		return a.notFound(ctx, VerbUpdate, olaf.KindService, serviceName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindService, old.Name, old); err != nil {
+		return err
+	}
+	// The new tags must not move the service out of the scope.
+	newSvc := *svc
+	newSvc.Name = old.Name
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindService, old.Name, &newSvc); err != nil {
+		return err
+	}
+	return a.next.UpdateService(ctx, serviceName, routeName, svc)
+}
+
+func (a *rbac) DeleteService(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	old, err := a.next.GetService(ctx, serviceName, routeName)
+	if err != nil {
+		return a.notFound(ctx, VerbDelete, olaf.KindService, serviceName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbDelete, olaf.KindService, old.Name, old); err != nil {
+		return err
+	}
+	if cascade {
+		if err := a.authorizeDependents(ctx, olaf.KindService, old.Name); err != nil {
+			return err
+		}
+	}
+	return a.next.DeleteService(ctx, serviceName, routeName, cascade)
+}
+
+func (a *rbac) CreateRoute(ctx context.Context, serviceName string, route *olaf.Route) (err error) {
+	if serviceName == "" {
+		serviceName = route.ServiceName
+	}
+	if err := a.authorize(ctx, VerbCreate, olaf.KindRoute, route.Name, a.service(ctx, serviceName)); err != nil {
+		return err
+	}
+	return a.next.CreateRoute(ctx, serviceName, route)
+}
+
+func (a *rbac) ListRoutes(ctx context.Context, serviceName string) (routes []*olaf.Route, err error) {
+	all, err := a.next.ListRoutes(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range all {
+		if a.authorize(ctx, VerbRead, olaf.KindRoute, r.Name, a.service(ctx, r.ServiceName)) == nil {
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
+
+func (a *rbac) GetRoute(ctx context.Context, serviceName, routeName string) (route *olaf.Route, err error) {
+	r, err := a.next.GetRoute(ctx, serviceName, routeName)
+	if err != nil {
+		return nil, a.notFound(ctx, VerbRead, olaf.KindRoute, routeName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbRead, olaf.KindRoute, r.Name, a.service(ctx, r.ServiceName)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (a *rbac) UpdateRoute(ctx context.Context, serviceName, routeName string, route *olaf.Route) (err error) {
+	old, err := a.next.GetRoute(ctx, serviceName, routeName)
+	if err != nil {
+		return a.notFound(ctx, VerbUpdate, olaf.KindRoute, routeName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindRoute, old.Name, a.service(ctx, old.ServiceName)); err != nil {
+		return err
+	}
+	// The route must not be moved to a service out of the scope.
+	if route.ServiceName != "" && route.ServiceName != old.ServiceName {
+		if err := a.authorize(ctx, VerbUpdate, olaf.KindRoute, old.Name, a.service(ctx, route.ServiceName)); err != nil {
+			return err
+		}
+	}
+	return a.next.UpdateRoute(ctx, serviceName, routeName, route)
+}
+
+func (a *rbac) DeleteRoute(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	old, err := a.next.GetRoute(ctx, serviceName, routeName)
+	if err != nil {
+		return a.notFound(ctx, VerbDelete, olaf.KindRoute, routeName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbDelete, olaf.KindRoute, old.Name, a.service(ctx, old.ServiceName)); err != nil {
+		return err
+	}
+	if cascade {
+		if err := a.authorizeDependents(ctx, olaf.KindRoute, old.Name); err != nil {
+			return err
+		}
+	}
+	return a.next.DeleteRoute(ctx, serviceName, routeName, cascade)
+}
+
+func (a *rbac) CreatePlugin(ctx context.Context, serviceName, routeName string, p *olaf.Plugin) (plugin *olaf.Plugin, err error) {
+	newP := *p
+	if serviceName != "" {
+		newP.ServiceName = serviceName
+	}
+	if routeName != "" {
+		newP.RouteName = routeName
+	}
+	if err := a.authorize(ctx, VerbCreate, olaf.KindPlugin, p.Name, a.pluginService(ctx, &newP)); err != nil {
+		return nil, err
+	}
+	return a.next.CreatePlugin(ctx, serviceName, routeName, p)
+}
+
+func (a *rbac) ListPlugins(ctx context.Context, serviceName, routeName string) (plugins []*olaf.Plugin, err error) {
+	all, err := a.next.ListPlugins(ctx, serviceName, routeName)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range all {
+		if a.authorize(ctx, VerbRead, olaf.KindPlugin, p.Name, a.pluginService(ctx, p)) == nil {
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins, nil
+}
+
+func (a *rbac) GetPlugin(ctx context.Context, serviceName, routeName, pluginName string) (plugin *olaf.Plugin, err error) {
+	p, err := a.next.GetPlugin(ctx, serviceName, routeName, pluginName)
+	if err != nil {
+		return nil, a.notFound(ctx, VerbRead, olaf.KindPlugin, pluginName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbRead, olaf.KindPlugin, p.Name, a.pluginService(ctx, p)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (a *rbac) UpdatePlugin(ctx context.Context, serviceName, routeName, pluginName string, plugin *olaf.Plugin) (err error) {
+	old, err := a.next.GetPlugin(ctx, serviceName, routeName, pluginName)
+	if err != nil {
+		return a.notFound(ctx, VerbUpdate, olaf.KindPlugin, pluginName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindPlugin, old.Name, a.pluginService(ctx, old)); err != nil {
+		return err
+	}
+	// The plugin must not be moved out of the scope.
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindPlugin, old.Name, a.pluginService(ctx, plugin)); err != nil {
+		return err
+	}
+	return a.next.UpdatePlugin(ctx, serviceName, routeName, pluginName, plugin)
+}
+
+func (a *rbac) DeletePlugin(ctx context.Context, serviceName, routeName, pluginName string) (err error) {
+	old, err := a.next.GetPlugin(ctx, serviceName, routeName, pluginName)
+	if err != nil {
+		return a.notFound(ctx, VerbDelete, olaf.KindPlugin, pluginName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbDelete, olaf.KindPlugin, old.Name, a.pluginService(ctx, old)); err != nil {
+		return err
+	}
+	return a.next.DeletePlugin(ctx, serviceName, routeName, pluginName)
+}
+
+func (a *rbac) ListUpstreams(ctx context.Context) (upstreams []*olaf.Upstream, err error) {
+	services, err := a.next.ListServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		if svc.Upstream == nil {
+			continue
+		}
+		if a.authorize(ctx, VerbRead, olaf.KindUpstream, svc.Upstream.ID, svc) == nil {
+			upstreams = append(upstreams, svc.Upstream)
+		}
+	}
+	return upstreams, nil
+}
+
+func (a *rbac) GetUpstream(ctx context.Context, upstreamName, serviceName string) (upstream *olaf.Upstream, err error) {
+	svc, err := a.upstreamService(ctx, upstreamName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.authorize(ctx, VerbRead, olaf.KindUpstream, upstreamName, svc); err != nil {
+		return nil, err
+	}
+	return a.next.GetUpstream(ctx, upstreamName, serviceName)
+}
+
+func (a *rbac) UpdateUpstream(ctx context.Context, upstreamName, serviceName string, upstream *olaf.Upstream) (err error) {
+	svc, err := a.upstreamService(ctx, upstreamName, serviceName)
+	if err != nil {
+		return err
+	}
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindUpstream, upstreamName, svc); err != nil {
+		return err
+	}
+	return a.next.UpdateUpstream(ctx, upstreamName, serviceName, upstream)
+}
+
+func (a *rbac) RenameService(ctx context.Context, serviceName, newName string) (changes []*olaf.Change, err error) {
+	svc, err := a.next.GetService(ctx, serviceName, "")
+	if err != nil {
+		return nil, a.notFound(ctx, VerbUpdate, olaf.KindService, serviceName, placeholder(serviceName), err)
+	}
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindService, svc.Name, svc); err != nil {
+		return nil, err
+	}
+	// The service must not be renamed out of the scope.
+	newSvc := *svc
+	newSvc.Name = newName
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindService, svc.Name, &newSvc); err != nil {
+		return nil, err
+	}
+	return a.next.RenameService(ctx, serviceName, newName)
+}
+
+func (a *rbac) RenameRoute(ctx context.Context, routeName, newName string) (changes []*olaf.Change, err error) {
+	r, err := a.next.GetRoute(ctx, "", routeName)
+	if err != nil {
+		return nil, a.notFound(ctx, VerbUpdate, olaf.KindRoute, routeName, nil, err)
+	}
+	if err := a.authorize(ctx, VerbUpdate, olaf.KindRoute, r.Name, a.service(ctx, r.ServiceName)); err != nil {
+		return nil, err
+	}
+	return a.next.RenameRoute(ctx, routeName, newName)
+}
+
+func (a *rbac) Batch(ctx context.Context, ops []*olaf.Operation) (err error) {
+	b := &batchState{
+		a:        a,
+		ctx:      ctx,
+		services: make(map[string]*olaf.Service),
+		routes:   make(map[string]string),
+		plugins:  make(map[string]*olaf.Plugin),
+	}
+	for i, op := range ops {
+		if err := a.authorizeOp(ctx, op, b); err != nil {
+			return &olaf.OperationError{Index: i, Op: op, Err: err}
+		}
+		b.apply(op)
+	}
+	return a.next.Batch(ctx, ops)
+}
+
+// batchState keeps track of the entities created or changed by the preceding
+// operations of a batch, which have not been applied yet, so that the
+// following operations are authorized against them rather than against the
+// state before the batch.
+type batchState struct {
+	a   *rbac
+	ctx context.Context
+
+	services map[string]*olaf.Service
+	routes   map[string]string // route name -> service name
+	plugins  map[string]*olaf.Plugin
+}
+
+// service returns the service with the given name.
+func (b *batchState) service(name string) *olaf.Service {
+	if svc, ok := b.services[name]; ok {
+		return svc
+	}
+	return b.a.service(b.ctx, name)
+}
+
+// routeService returns the service of the route with the given name.
+func (b *batchState) routeService(routeName string) *olaf.Service {
+	if serviceName, ok := b.routes[routeName]; ok {
+		return b.service(serviceName)
+	}
+	if r, err := b.a.next.GetRoute(b.ctx, "", routeName); err == nil {
+		return b.service(r.ServiceName)
+	}
+	return nil
+}
+
+// pluginService returns the service of the plugin with the given name, and
+// whether the plugin exists.
+func (b *batchState) pluginService(pluginName string) (*olaf.Service, bool) {
+	p, ok := b.plugins[pluginName]
+	if !ok {
+		var err error
+		if p, err = b.a.next.GetPlugin(b.ctx, "", "", pluginName); err != nil {
+			return nil, false
+		}
+	}
+	return b.scopeService(p), true
+}
+
+// scopeService returns the service of the scope of p.
+func (b *batchState) scopeService(p *olaf.Plugin) *olaf.Service {
+	if p.ServiceName != "" {
+		return b.service(p.ServiceName)
+	}
+	if p.RouteName != "" {
+		return b.routeService(p.RouteName)
+	}
+	return nil
+}
+
+// apply records the changes made by op.
+func (b *batchState) apply(op *olaf.Operation) {
+	switch op.Kind {
+	case olaf.KindService:
+		switch {
+		case op.Op == olaf.OpCreate && op.Service != nil:
+			b.services[op.Service.Name] = op.Service
+		case op.Op == olaf.OpUpdate && op.Service != nil:
+			newSvc := *op.Service
+			newSvc.Name = op.Name
+			b.services[op.Name] = &newSvc
+		case op.Op == olaf.OpRename:
+			if old := b.service(op.Name); old != nil {
+				newSvc := *old
+				newSvc.Name = op.NewName
+				b.services[op.NewName] = &newSvc
+			}
+			for routeName, serviceName := range b.routes {
+				if serviceName == op.Name {
+					b.routes[routeName] = op.NewName
+				}
+			}
+		}
+	case olaf.KindRoute:
+		switch {
+		case op.Op == olaf.OpCreate && op.Route != nil:
+			b.routes[op.Route.Name] = op.Route.ServiceName
+		case op.Op == olaf.OpUpdate && op.Route != nil && op.Route.ServiceName != "":
+			b.routes[op.Name] = op.Route.ServiceName
+		case op.Op == olaf.OpRename:
+			if svc := b.routeService(op.Name); svc != nil {
+				b.routes[op.NewName] = svc.Name
+			}
+		}
+	case olaf.KindPlugin:
+		switch {
+		case op.Op == olaf.OpCreate && op.Plugin != nil && op.Plugin.Name != "":
+			b.plugins[op.Plugin.Name] = op.Plugin
+		case op.Op == olaf.OpUpdate && op.Plugin != nil && (op.Plugin.ServiceName != "" || op.Plugin.RouteName != ""):
+			b.plugins[op.Name] = op.Plugin
+		}
+	}
+}
+
+func (a *rbac) authorizeOp(ctx context.Context, op *olaf.Operation, b *batchState) error {
+	verb := map[string]string{
+		olaf.OpCreate: VerbCreate,
+		olaf.OpUpdate: VerbUpdate,
+		olaf.OpDelete: VerbDelete,
+		olaf.OpRename: VerbUpdate,
+	}[op.Op]
+	if verb == "" {
+		// Leave it to next to report the unknown op.
+		return nil
+	}
+
+	// Find the services to which the entity belongs, before and after the
+	// operation.
+	var svcs []*olaf.Service
+	name := op.Name
+	switch op.Kind {
+	case olaf.KindService:
+		if op.Op != olaf.OpCreate {
+			svcs = append(svcs, b.service(op.Name))
+		}
+		if op.Service != nil {
+			newSvc := *op.Service
+			if op.Op != olaf.OpCreate {
+				newSvc.Name = op.Name
+			}
+			name = newSvc.Name
+			svcs = append(svcs, &newSvc)
+		}
+		if op.Op == olaf.OpRename {
+			newSvc := olaf.Service{Name: op.NewName}
+			if old := b.service(op.Name); old != nil {
+				newSvc = *old
+				newSvc.Name = op.NewName
+			}
+			svcs = append(svcs, &newSvc)
+		}
+	case olaf.KindRoute:
+		if op.Op != olaf.OpCreate {
+			if svc := b.routeService(op.Name); svc != nil {
+				svcs = append(svcs, svc)
+			}
+		}
+		if op.Route != nil && op.Route.ServiceName != "" {
+			svcs = append(svcs, b.service(op.Route.ServiceName))
+		}
+		if op.Op == olaf.OpCreate && op.Route != nil {
+			name = op.Route.Name
+		}
+	case olaf.KindPlugin:
+		if op.Op != olaf.OpCreate {
+			if svc, ok := b.pluginService(op.Name); ok {
+				svcs = append(svcs, svc)
+			}
+		}
+		// The scope of the plugin is left as is by an update without one.
+		if op.Plugin != nil && (op.Op == olaf.OpCreate || op.Plugin.ServiceName != "" || op.Plugin.RouteName != "") {
+			svcs = append(svcs, b.scopeService(op.Plugin))
+		}
+		if op.Op == olaf.OpCreate && op.Plugin != nil {
+			name = op.Plugin.Name
+		}
+	}
+	if len(svcs) == 0 {
+		svcs = append(svcs, nil)
+	}
+
+	for _, svc := range svcs {
+		if err := a.authorize(ctx, verb, op.Kind, name, svc); err != nil {
+			return err
+		}
+	}
+
+	if op.Op == olaf.OpDelete && op.Cascade {
+		switch op.Kind {
+		case olaf.KindService:
+			if svc := a.service(ctx, op.Name); svc != nil {
+				return a.authorizeDependents(ctx, olaf.KindService, svc.Name)
+			}
+		case olaf.KindRoute:
+			if r, err := a.next.GetRoute(ctx, "", op.Name); err == nil {
+				return a.authorizeDependents(ctx, olaf.KindRoute, r.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+const testRBACConfig = `
+services:
+- name: team-a-web
+  upstream:
+    backends: ["localhost:2222"]
+  routes:
+  - name: web
+    paths:
+    - /web
+- name: billing
+  tags: ["team-a"]
+  upstream:
+    backends: ["localhost:3333"]
+- name: team-b-web
+  upstream:
+    backends: ["localhost:4444"]
+`
+
+const testPolicy = `
+roles:
+- name: team-a
+  rules:
+  - kinds: ["*"]
+    verbs: ["read"]
+  - kinds: ["service", "route", "plugin", "upstream"]
+    verbs: ["*"]
+    services: ["team-a-*"]
+    tags: ["team-a"]
+  - effect: deny
+    kinds: ["service"]
+    verbs: ["delete"]
+    tags: ["team-a"]
+bindings:
+- role: team-a
+  identities: ["alice"]
+`
+
+func newTestRBAC(t *testing.T) Admin {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	policyFile := filepath.Join(dir, "policy.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	writeFile(t, policyFile, testPolicy)
+
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return NewRBAC(policy, yaml.New(configFile))
+}
+
+func writeFile(t *testing.T, filename, content string) {
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	a := newTestRBAC(t)
+	alice := NewContextWithIdentity(context.Background(), "alice")
+	bob := NewContextWithIdentity(context.Background(), "bob")
+
+	cases := []struct {
+		name          string
+		call          func() error
+		wantErr       error
+		wantRuleIndex int
+	}{
+		{
+			name: "read a service of another team",
+			call: func() error {
+				_, err := a.GetService(alice, "team-b-web", "")
+				return err
+			},
+		},
+		{
+			name: "update a route within the name scope",
+			call: func() error {
+				return a.UpdateRoute(alice, "", "web", &olaf.Route{Matcher: olaf.Matcher{Paths: []string{"/web2"}}})
+			},
+		},
+		{
+			name: "create a service out of the scope",
+			call: func() error {
+				return a.CreateService(alice, &olaf.Service{Name: "team-b-api"})
+			},
+			wantErr:       ErrForbidden,
+			wantRuleIndex: -1,
+		},
+		{
+			name: "delete a service denied explicitly",
+			call: func() error {
+				return a.DeleteService(alice, "billing", "", false)
+			},
+			wantErr:       ErrForbidden,
+			wantRuleIndex: 2,
+		},
+		{
+			name: "delete a missing service out of the scope",
+			call: func() error {
+				return a.DeleteService(alice, "team-b-api", "", false)
+			},
+			wantErr:       ErrForbidden,
+			wantRuleIndex: -1,
+		},
+		{
+			name: "delete a missing service within the scope",
+			call: func() error {
+				return a.DeleteService(alice, "team-a-api", "", false)
+			},
+			wantErr: olaf.ErrServiceNotFound,
+		},
+		{
+			name: "batch with an operation out of the scope",
+			call: func() error {
+				return a.Batch(alice, []*olaf.Operation{
+					{Op: olaf.OpDelete, Kind: olaf.KindRoute, Name: "web"},
+					{Op: olaf.OpDelete, Kind: olaf.KindService, Name: "team-b-web"},
+				})
+			},
+			wantErr:       ErrForbidden,
+			wantRuleIndex: -1,
+		},
+		{
+			name: "batch with a plugin of a route created earlier",
+			call: func() error {
+				return a.Batch(alice, []*olaf.Operation{
+					{Op: olaf.OpCreate, Kind: olaf.KindRoute, Route: &olaf.Route{Name: "api", ServiceName: "team-a-web", Matcher: olaf.Matcher{Paths: []string{"/api"}}}},
+					{Op: olaf.OpCreate, Kind: olaf.KindPlugin, Plugin: &olaf.Plugin{Name: "api_limit", Type: "rate_limit", RouteName: "api"}},
+					{Op: olaf.OpUpdate, Kind: olaf.KindPlugin, Name: "api_limit", Plugin: &olaf.Plugin{Type: "rate_limit", Config: map[string]interface{}{"rate": "10r/s"}}},
+				})
+			},
+		},
+		{
+			name: "no role",
+			call: func() error {
+				_, err := a.GetConfig(bob)
+				return err
+			},
+			wantErr:       ErrForbidden,
+			wantRuleIndex: -1,
+		},
+		{
+			name: "no identity",
+			call: func() error {
+				_, err := a.GetConfig(context.Background())
+				return err
+			},
+			wantErr: ErrUnauthenticated,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.call()
+			if !errors.Is(err, c.wantErr) && !(err == nil && c.wantErr == nil) {
+				t.Fatalf("Err: got (%v), want (%v)", err, c.wantErr)
+			}
+
+			var forbiddenErr *ForbiddenError
+			if errors.As(err, &forbiddenErr) && forbiddenErr.RuleIndex != c.wantRuleIndex {
+				t.Fatalf("RuleIndex: got (%d), want (%d)", forbiddenErr.RuleIndex, c.wantRuleIndex)
+			}
+		})
+	}
+
+	services, err := a.ListServices(bob)
+	if err != nil || len(services) != 0 {
+		t.Fatalf("ListServices: got (%v, %v), want ([], <nil>)", services, err)
+	}
+}
+
+func TestRBAC_Cascade(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	policyFile := filepath.Join(dir, "policy.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	writeFile(t, policyFile, testPolicy)
+
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	store := yaml.New(configFile)
+	a := NewRBAC(policy, store)
+	alice := NewContextWithIdentity(context.Background(), "alice")
+
+	// A plugin of team B, which routes requests to a service of team A.
+	_, err = store.CreatePlugin(context.Background(), "team-b-web", "", &olaf.Plugin{
+		Type:   olaf.PluginTypeCanary,
+		Config: map[string]interface{}{"upstream": "team-a-web"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{
+			name: "delete without cascade",
+			call: func() error {
+				return a.DeleteService(alice, "team-a-web", "", false)
+			},
+			wantErr: new(olaf.DependentsError),
+		},
+		{
+			name: "delete with cascade",
+			call: func() error {
+				return a.DeleteService(alice, "team-a-web", "", true)
+			},
+			wantErr: ErrForbidden,
+		},
+		{
+			name: "batch delete with cascade",
+			call: func() error {
+				return a.Batch(alice, []*olaf.Operation{
+					{Op: olaf.OpDelete, Kind: olaf.KindService, Name: "team-a-web", Cascade: true},
+				})
+			},
+			wantErr: ErrForbidden,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.call()
+			if depErr, ok := c.wantErr.(*olaf.DependentsError); ok {
+				if !errors.As(err, &depErr) {
+					t.Fatalf("Err: got (%v), want (%T)", err, depErr)
+				}
+				return
+			}
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Err: got (%v), want (%v)", err, c.wantErr)
+			}
+		})
+	}
+
+	if _, err := store.GetService(context.Background(), "team-a-web", ""); err != nil {
+		t.Fatalf("GetService: got (%v), want (<nil>)", err)
+	}
+}
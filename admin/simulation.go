@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+)
+
+// KindSimulation is the kind of the simulation requests.
+const KindSimulation = "simulation"
+
+// NewSimulationHandler returns a handler, which routes the synthetic request
+// in the request body (see builder.Request) per the current config of svc,
+// and responds with the result (see builder.Simulate). The query parameter
+// `auto_priority` tells whether the routes are ordered automatically.
+func NewSimulationHandler(svc Admin) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts, err := builderOptions(r, KindSimulation)
+		if err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+
+		req := new(builder.Request)
+		if err := (Codec{}).DecodeRequestBody(r, req); err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+		if req.Path != "" && req.Path[0] != '/' {
+			Codec{}.EncodeFailureResponse(w, olaf.InvalidField(KindSimulation, "path", "must start with \"/\"")) // nolint:errcheck
+			return
+		}
+
+		data, err := svc.GetConfig(r.Context())
+		if err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+
+		sim, err := builder.Simulate(data, req, opts)
+		if err != nil {
+			// The config has problems, which can be found by validating it.
+			Codec{}.EncodeFailureResponse(w, fmt.Errorf("%w: %v", olaf.ErrInvalidOperation, err)) // nolint:errcheck
+			return
+		}
+		Codec{}.EncodeSuccessResponse(w, http.StatusOK, sim) // nolint:errcheck
+	})
+}
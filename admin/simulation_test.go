@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+func TestSimulationHandler(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	h := NewSimulationHandler(yaml.New(configFile))
+
+	cases := []struct {
+		name       string
+		inBody     string
+		wantStatus int
+		wantBody   *builder.Simulation
+	}{
+		{
+			name:       "matched",
+			inBody:     `{"method": "GET", "host": "example.com", "path": "/web"}`,
+			wantStatus: http.StatusOK,
+			wantBody: &builder.Simulation{
+				Matched: true,
+				Route:   "web",
+				Service: "team-a-web",
+				Path:    "/web",
+			},
+		},
+		{
+			name:       "not matched",
+			inBody:     `{"method": "GET", "path": "/web/foo"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   &builder.Simulation{},
+		},
+		{
+			name:       "invalid path",
+			inBody:     `{"method": "GET", "path": "web"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.inBody))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", w.Code, c.wantStatus)
+			}
+			if c.wantBody == nil {
+				return
+			}
+			got := new(builder.Simulation)
+			if err := json.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.wantBody) {
+				t.Fatalf("Body: got (%+v), want (%+v)", got, c.wantBody)
+			}
+		})
+	}
+}
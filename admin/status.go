@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/go-chi/chi"
+)
+
+// Checker is implemented by the stores that can check whether they are able
+// to serve the config (e.g. the backing storage is reachable, and the config
+// has been successfully loaded).
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// KindStatus is the kind of the status requests.
+const KindStatus = "status"
+
+// Status is the status of the Admin API.
+type Status struct {
+	// Whether the process is up and serving requests.
+	Live bool `json:"live"`
+	// Whether the store can serve a valid config.
+	Ready bool `json:"ready"`
+	// The reason why the Admin API is not ready.
+	Error string `json:"error,omitempty"`
+
+	// The revision of the config (see olaf.Notifier), and the hash of the
+	// Caddy routes built from it (see builder.Hash), which is empty if the
+	// routes can not be built.
+	Revision int64  `json:"revision"`
+	Hash     string `json:"hash,omitempty"`
+
+	Counts *StatusCounts `json:"counts,omitempty"`
+
+	// The build information.
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+}
+
+// StatusCounts holds the numbers of the entities in the config.
+type StatusCounts struct {
+	Services  int `json:"services"`
+	Routes    int `json:"routes"`
+	Plugins   int `json:"plugins"`
+	Upstreams int `json:"upstreams"`
+}
+
+// NewStatusHandler returns a handler serving the status of the Admin API,
+// which is backed by svc:
+//
+//	GET /       the whole status (see Status)
+//	GET /live   the liveness, which is always 200 as long as the process is up
+//	GET /ready  the readiness, which is 503 if svc can not serve a valid config
+//
+// svc is ready if it passes the check (if it implements Checker), and has a
+// config without broken references. n (if not nil) reports the revision of
+// the config, and version is the build version.
+//
+// The query parameter `auto_priority` of `GET /` tells whether the routes are
+// ordered automatically (see builder.Options) while computing the hash.
+func NewStatusHandler(svc Admin, n olaf.Notifier, version string) http.Handler {
+	status := func(r *http.Request, opts builder.Options) *Status {
+		s := &Status{
+			Live:      true,
+			Version:   version,
+			GoVersion: runtime.Version(),
+		}
+		if n != nil {
+			s.Revision = n.Revision()
+		}
+		if err := checkConfig(r.Context(), svc, s, opts); err != nil {
+			s.Error = err.Error()
+			return s
+		}
+		s.Ready = true
+		return s
+	}
+
+	r := chi.NewRouter()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		opts, err := builderOptions(r, KindStatus)
+		if err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+		Codec{}.EncodeSuccessResponse(w, http.StatusOK, status(r, opts)) // nolint:errcheck
+	})
+	r.Get("/live", func(w http.ResponseWriter, r *http.Request) {
+		Codec{}.EncodeSuccessResponse(w, http.StatusOK, &Status{Live: true}) // nolint:errcheck
+	})
+	r.Get("/ready", func(w http.ResponseWriter, r *http.Request) {
+		s := status(r, builder.Options{})
+		code := http.StatusOK
+		if !s.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		Codec{}.EncodeSuccessResponse(w, code, &Status{Live: s.Live, Ready: s.Ready, Error: s.Error}) // nolint:errcheck
+	})
+	return r
+}
+
+// checkConfig checks whether svc can serve a valid config, and fills in the
+// hash (of the routes built with opts) and counts of the config.
+func checkConfig(ctx context.Context, svc Admin, s *Status, opts builder.Options) error {
+	if c, ok := svc.(Checker); ok {
+		if err := c.Check(ctx); err != nil {
+			return err
+		}
+	}
+
+	data, err := svc.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := olaf.CheckIntegrity(data); err != nil {
+		return err
+	}
+
+	// The problems found while building the routes are reported by Validate,
+	// rather than making the config unready.
+	if routes, err := builder.BuildWithOptions(data, opts); err == nil {
+		s.Hash, _ = builder.Hash(routes)
+	}
+
+	s.Counts = &StatusCounts{
+		Services: len(data.Services),
+		Routes:   len(data.Routes),
+		Plugins:  len(data.Plugins),
+	}
+	for _, svc := range data.Services {
+		if svc.Upstream != nil {
+			s.Counts.Upstreams++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+func TestStatusHandler(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	writeFile(t, configFile, testRBACConfig)
+
+	loaded := yaml.New(configFile)
+	missing := yaml.New(filepath.Join(dir, "missing.yaml"))
+
+	data, err := loaded.GetConfig(context.Background())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	routes, err := builder.Build(data)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	hash, err := builder.Hash(routes)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		inStore    *yaml.Store
+		inPath     string
+		wantStatus int
+		wantBody   *Status
+		wantHash   string
+		wantError  bool
+	}{
+		{
+			name:       "status",
+			inStore:    loaded,
+			inPath:     "/",
+			wantStatus: http.StatusOK,
+			wantBody: &Status{
+				Live:      true,
+				Ready:     true,
+				Counts:    &StatusCounts{Services: 3, Routes: 1, Upstreams: 3},
+				Version:   "v1.0.0",
+				GoVersion: runtime.Version(),
+			},
+			wantHash: hash,
+		},
+		{
+			name:       "status with invalid auto_priority",
+			inStore:    loaded,
+			inPath:     "/?auto_priority=maybe",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   &Status{},
+			wantError:  true,
+		},
+		{
+			name:       "status without config",
+			inStore:    missing,
+			inPath:     "/",
+			wantStatus: http.StatusOK,
+			wantBody: &Status{
+				Live:      true,
+				Version:   "v1.0.0",
+				GoVersion: runtime.Version(),
+			},
+			wantError: true,
+		},
+		{
+			name:       "live",
+			inStore:    missing,
+			inPath:     "/live",
+			wantStatus: http.StatusOK,
+			wantBody:   &Status{Live: true},
+		},
+		{
+			name:       "ready",
+			inStore:    loaded,
+			inPath:     "/ready",
+			wantStatus: http.StatusOK,
+			wantBody:   &Status{Live: true, Ready: true},
+		},
+		{
+			name:       "not ready",
+			inStore:    missing,
+			inPath:     "/ready",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   &Status{Live: true},
+			wantError:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := NewStatusHandler(c.inStore, c.inStore, "v1.0.0")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.inPath, nil))
+
+			if w.Code != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", w.Code, c.wantStatus)
+			}
+			got := new(Status)
+			if err := json.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			if got.Hash != c.wantHash {
+				t.Fatalf("Hash: got (%q), want (%q)", got.Hash, c.wantHash)
+			}
+			if (got.Error != "") != c.wantError {
+				t.Fatalf("Error: got (%q), want present (%v)", got.Error, c.wantError)
+			}
+			got.Hash, got.Error = "", ""
+			if !reflect.DeepEqual(got, c.wantBody) {
+				t.Fatalf("Body: got (%+v), want (%+v)", got, c.wantBody)
+			}
+		})
+	}
+}
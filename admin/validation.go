@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/go-chi/chi"
+)
+
+// KindValidation is the kind of the validation requests.
+const KindValidation = "validation"
+
+// Validation is the result of validating a config.
+type Validation struct {
+	// Whether the config has neither errors nor conflicts.
+	Valid bool `json:"valid"`
+	// The problems making the config unusable, i.e. the broken references,
+	// and the ones found while building the Caddy routes.
+	Errors []string `json:"errors,omitempty"`
+	// The routes that can never match.
+	Conflicts []*builder.Conflict `json:"conflicts,omitempty"`
+}
+
+// Validate validates data, whose routes are ordered per opts. A config with
+// null entities (see checkEntities) is reported as is, without further
+// validation.
+func Validate(data *olaf.Data, opts builder.Options) *Validation {
+	v := new(Validation)
+
+	if err := checkEntities(data); err != nil {
+		v.Errors = append(v.Errors, err.Error())
+		return v
+	}
+
+	if err := olaf.CheckIntegrity(data); err != nil {
+		for _, re := range err.(olaf.IntegrityError) {
+			v.Errors = append(v.Errors, re.Error())
+		}
+	} else if _, err := builder.BuildWithOptions(data, opts); err != nil {
+		// Only build the routes if there are no broken references, which
+		// would otherwise be reported twice.
+		for _, e := range err.(builder.Errors) {
+			v.Errors = append(v.Errors, e.Error())
+		}
+	}
+
+	v.Conflicts = builder.FindConflicts(data, opts)
+	v.Valid = len(v.Errors) == 0 && len(v.Conflicts) == 0
+	return v
+}
+
+// NewValidationHandler returns a handler validating configs (see Validate):
+//
+//	GET /   validate the current config of svc
+//	POST /  validate the config in the request body, which is in the same
+//	        form as the one returned by GetConfig
+//
+// The query parameter `auto_priority` tells whether the routes are ordered
+// automatically (see builder.Options). An invalid config is still reported
+// with 200, since the validation itself succeeds.
+func NewValidationHandler(svc Admin) http.Handler {
+	validate := func(w http.ResponseWriter, r *http.Request, data *olaf.Data) {
+		opts, err := builderOptions(r, KindValidation)
+		if err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+		Codec{}.EncodeSuccessResponse(w, http.StatusOK, Validate(data, opts)) // nolint:errcheck
+	}
+
+	r := chi.NewRouter()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		data, err := svc.GetConfig(r.Context())
+		if err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+		validate(w, r, data)
+	})
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		data := new(olaf.Data)
+		if err := (Codec{}).DecodeRequestBody(r, data); err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+		if err := checkEntities(data); err != nil {
+			Codec{}.EncodeFailureResponse(w, err) // nolint:errcheck
+			return
+		}
+		validate(w, r, data)
+	})
+	return r
+}
+
+// checkEntities checks that data has no null entities (e.g. the route "a"
+// in `{"routes": {"a": null}}`) or backends, which can be decoded from the
+// request body but can not be validated.
+func checkEntities(data *olaf.Data) error {
+	const msg = "must not be null"
+	for _, name := range olaf.SortedNames(data.Services) {
+		s := data.Services[name]
+		if s == nil {
+			return olaf.InvalidField(KindValidation, "services."+name, msg)
+		}
+		if s.Upstream == nil {
+			continue
+		}
+		for i, b := range s.Upstream.Backends {
+			if b == nil {
+				return olaf.InvalidField(KindValidation, fmt.Sprintf("services.%s.upstream.backends[%d]", name, i), msg)
+			}
+		}
+	}
+	for _, name := range olaf.SortedNames(data.Routes) {
+		if data.Routes[name] == nil {
+			return olaf.InvalidField(KindValidation, "routes."+name, msg)
+		}
+	}
+	for _, name := range olaf.SortedNames(data.Plugins) {
+		if data.Plugins[name] == nil {
+			return olaf.InvalidField(KindValidation, "plugins."+name, msg)
+		}
+	}
+	return nil
+}
+
+// builderOptions returns the builder options specified by the query
+// parameters of r, whose errors are reported as the ones of kind.
+func builderOptions(r *http.Request, kind string) (opts builder.Options, err error) {
+	if s := r.URL.Query().Get("auto_priority"); s != "" {
+		auto, err := strconv.ParseBool(s)
+		if err != nil {
+			return opts, olaf.InvalidField(kind, "auto_priority", "must be a boolean")
+		}
+		opts.AutoPriority = auto
+	}
+	return opts, nil
+}
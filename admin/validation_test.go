@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+func TestValidationHandler(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	h := NewValidationHandler(yaml.New(configFile))
+
+	web := &olaf.Service{
+		Name: "web",
+		Upstream: &olaf.Upstream{
+			Backends: []*olaf.Backend{{Dial: "localhost:8080"}},
+		},
+	}
+	data := &olaf.Data{
+		Services: map[string]*olaf.Service{"web": web},
+		Routes: map[string]*olaf.Route{
+			"api":   {Name: "api", ServiceName: "web", Matcher: olaf.Matcher{Paths: []string{"/api/*"}}},
+			"users": {Name: "users", ServiceName: "web", Matcher: olaf.Matcher{Hosts: []string{"example.com"}, Paths: []string{"/api/"}}},
+			"bad":   {Name: "bad", ServiceName: "nonexistent", Matcher: olaf.Matcher{Paths: []string{"/bad"}}},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		inMethod   string
+		inTarget   string
+		inData     *olaf.Data
+		inBody     string
+		wantStatus int
+		wantBody   *Validation
+	}{
+		{
+			name:       "current config",
+			inMethod:   http.MethodGet,
+			inTarget:   "/",
+			wantStatus: http.StatusOK,
+			wantBody:   &Validation{Valid: true},
+		},
+		{
+			name:       "given config",
+			inMethod:   http.MethodPost,
+			inTarget:   "/",
+			inData:     data,
+			wantStatus: http.StatusOK,
+			wantBody: &Validation{
+				Errors: []string{`service "nonexistent" of route "bad" not found`},
+				Conflicts: []*builder.Conflict{
+					{Route: "users", ShadowedBy: "api"},
+				},
+			},
+		},
+		{
+			name:       "given config with auto priority",
+			inMethod:   http.MethodPost,
+			inTarget:   "/?auto_priority=true",
+			inData:     data,
+			wantStatus: http.StatusOK,
+			wantBody: &Validation{
+				Errors: []string{`service "nonexistent" of route "bad" not found`},
+			},
+		},
+		{
+			name:       "null route",
+			inMethod:   http.MethodPost,
+			inTarget:   "/",
+			inBody:     `{"routes": {"a": null}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "null backend",
+			inMethod:   http.MethodPost,
+			inTarget:   "/",
+			inBody:     `{"services": {"web": {"name": "web", "upstream": {"backends": [null]}}}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid auto priority",
+			inMethod:   http.MethodGet,
+			inTarget:   "/?auto_priority=yes",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			body := bytes.NewBufferString(c.inBody)
+			if c.inData != nil {
+				if err := json.NewEncoder(body).Encode(c.inData); err != nil {
+					t.Fatalf("err: %v", err)
+				}
+			}
+			r := httptest.NewRequest(c.inMethod, c.inTarget, body)
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", w.Code, c.wantStatus)
+			}
+			if c.wantBody == nil {
+				return
+			}
+			got := new(Validation)
+			if err := json.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.wantBody) {
+				t.Fatalf("Body: got (%+v), want (%+v)", got, c.wantBody)
+			}
+		})
+	}
+}
+
+func TestValidate_NullEntities(t *testing.T) {
+	data := &olaf.Data{
+		Services: map[string]*olaf.Service{"web": nil},
+		Routes:   map[string]*olaf.Route{"a": nil},
+	}
+	got := Validate(data, builder.Options{})
+	want := &Validation{Errors: []string{"invalid validation: services.web must not be null"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Validation: got (%+v), want (%+v)", got, want)
+	}
+}
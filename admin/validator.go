@@ -0,0 +1,441 @@
+package admin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RussellLuo/kun/pkg/httpoption"
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/RussellLuo/validating/v2"
+	"github.com/go-chi/chi"
+	"github.com/mitchellh/mapstructure"
+)
+
+// PluginTypes holds all the plugin types accepted by the Admin API. Append to
+// it to allow more plugins (usually third-party Caddy extensions).
+var PluginTypes = []string{
+	olaf.PluginTypeCanary,
+	olaf.PluginTypeTrafficSplit,
+	olaf.PluginTypeMirror,
+	olaf.PluginTypeFault,
+	"request_body_var",
+	"rate_limit",
+}
+
+var (
+	reName = regexp.MustCompile(`^[A-Za-z0-9._~-]{1,128}$`)
+	rePath = regexp.MustCompile(`^/`)
+
+	lbPolicies = []string{
+		"random", "random_choose", "least_conn", "round_robin",
+		"first", "ip_hash", "uri_hash", "header", "cookie",
+	}
+
+	methods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace,
+	}
+)
+
+// NewRouter creates the router of the Admin API, like NewHTTPRouter, but with
+// the requests validated by default (see NewValidators), and encoded by codec.
+// The validators can be overridden by opts, e.g. httpoption.RequestValidators(nil)
+// disables them.
+func NewRouter(svc Admin, codec Codec, opts ...httpoption.Option) chi.Router {
+	opts = append([]httpoption.Option{NewValidators()}, opts...)
+	return NewHTTPRouter(svc, NewCodecs(codec), opts...)
+}
+
+// NewValidators returns the option, which validates the requests of all the
+// operations that accept entities or names.
+func NewValidators() httpoption.Option {
+	return httpoption.RequestValidators(map[string]httpoption.Validator{
+		"Batch": validator(func(s *schema, value interface{}) {
+			req := value.(*BatchRequest)
+			s.kind = "batch"
+			s.required("ops", len(req.Ops))
+			for i, op := range req.Ops {
+				s.operation(fmt.Sprintf("ops[%d].", i), op)
+			}
+		}),
+		"CreateService": validator(func(s *schema, value interface{}) {
+			s.service("", value.(*CreateServiceRequest).Svc, true)
+		}),
+		"UpdateService": validator(func(s *schema, value interface{}) {
+			s.service("", value.(*UpdateServiceRequest).Svc, false)
+		}),
+		"RenameService": validator(func(s *schema, value interface{}) {
+			s.kind = olaf.KindService
+			s.name("new_name", value.(*RenameServiceRequest).NewName, true)
+		}),
+		"CreateRoute": validator(func(s *schema, value interface{}) {
+			req := value.(*CreateRouteRequest)
+			s.route("", req.Route, true)
+			if req.Route != nil && req.ServiceName == "" {
+				s.required("service_name", req.Route.ServiceName)
+			}
+		}),
+		"UpdateRoute": validator(func(s *schema, value interface{}) {
+			s.route("", value.(*UpdateRouteRequest).Route, false)
+		}),
+		"RenameRoute": validator(func(s *schema, value interface{}) {
+			s.kind = olaf.KindRoute
+			s.name("new_name", value.(*RenameRouteRequest).NewName, true)
+		}),
+		"CreatePlugin": validator(func(s *schema, value interface{}) {
+			s.plugin("", value.(*CreatePluginRequest).P)
+		}),
+		"UpdatePlugin": validator(func(s *schema, value interface{}) {
+			s.plugin("", value.(*UpdatePluginRequest).Plugin)
+		}),
+		"UpdateUpstream": validator(func(s *schema, value interface{}) {
+			s.kind = olaf.KindUpstream
+			s.upstream("", value.(*UpdateUpstreamRequest).Upstream)
+		}),
+	})
+}
+
+// validator creates a validator, which validates the request against the
+// schema built by f.
+func validator(f func(s *schema, value interface{})) httpoption.Validator {
+	return httpoption.FuncValidator(func(value interface{}) error {
+		s := newSchema()
+		f(s, value)
+		return s.validate()
+	})
+}
+
+// schema is a validating schema, whose fields are named after their JSON paths
+// in the request body. The rules are checked as soon as they are added, since
+// the rules of the nested fields depend on whether their parents are valid.
+type schema struct {
+	kind string
+	// The messages of the invalid fields.
+	msgs map[string]string
+}
+
+func newSchema() *schema {
+	return &schema{msgs: make(map[string]string)}
+}
+
+// check validates the value of the field by v, and reports whether the value
+// is valid. Otherwise, msg will be reported, unless the field has already
+// failed another rule.
+func (s *schema) check(field string, value interface{}, v validating.Validator, msg string) bool {
+	if errs := v.Validate(validating.F(field, value)); len(errs) == 0 {
+		return true
+	}
+	if _, exists := s.msgs[field]; !exists {
+		s.msgs[field] = msg
+	}
+	return false
+}
+
+// validate converts the errors, if any, into an olaf.ValidationError.
+func (s *schema) validate() error {
+	if len(s.msgs) == 0 {
+		return nil
+	}
+
+	e := &olaf.ValidationError{Kind: s.kind}
+	for field, msg := range s.msgs {
+		e.Fields = append(e.Fields, &olaf.FieldError{Field: field, Message: msg})
+	}
+	sort.Slice(e.Fields, func(i, j int) bool {
+		return e.Fields[i].Field < e.Fields[j].Field
+	})
+	return e
+}
+
+func (s *schema) required(field string, value interface{}) bool {
+	return s.check(field, value, validating.Nonzero(), "is required")
+}
+
+// entity checks whether the entity, at the path denoted by prefix, exists.
+func (s *schema) entity(prefix, kind string, value interface{}) bool {
+	field := strings.TrimSuffix(prefix, ".")
+	if field == "" {
+		field = kind
+	}
+	return s.required(field, value)
+}
+
+func (s *schema) name(field, value string, required bool) {
+	if value == "" {
+		if required {
+			s.required(field, value)
+		}
+		return
+	}
+	s.check(field, value, validating.Match(reName), "must be 1 to 128 letters, digits, '.', '_', '~' or '-'")
+}
+
+func (s *schema) duration(field, value string) {
+	if value == "" {
+		return
+	}
+	s.check(field, value, validating.Is(isDuration), "must be a duration like \"300ms\" or \"2s\"")
+}
+
+func (s *schema) oneOf(field, value string, values []string) {
+	if value == "" {
+		return
+	}
+	in := make([]interface{}, len(values))
+	for i, v := range values {
+		in[i] = v
+	}
+	s.check(field, value, validating.In(in...), fmt.Sprintf("must be one of %s", strings.Join(values, ", ")))
+}
+
+func (s *schema) percentage(field string, value int) {
+	s.check(field, value, validating.Range(0, 100), "must be between 0 and 100")
+}
+
+func (s *schema) statusCode(field string, value int) {
+	s.check(field, value, validating.Any(validating.Zero(), validating.Range(100, 599)), "must be a valid HTTP status code")
+}
+
+func (s *schema) operation(prefix string, op *olaf.Operation) {
+	if !s.required(prefix[:len(prefix)-1], op) {
+		return
+	}
+
+	s.oneOf(prefix+"op", op.Op, []string{olaf.OpCreate, olaf.OpUpdate, olaf.OpDelete, olaf.OpRename})
+	s.required(prefix+"op", op.Op)
+	s.oneOf(prefix+"kind", op.Kind, []string{olaf.KindService, olaf.KindRoute, olaf.KindPlugin})
+	s.required(prefix+"kind", op.Kind)
+
+	switch op.Op {
+	case olaf.OpUpdate, olaf.OpDelete:
+		s.required(prefix+"name", op.Name)
+	case olaf.OpRename:
+		s.required(prefix+"name", op.Name)
+		s.name(prefix+"new_name", op.NewName, true)
+	}
+
+	if op.Op != olaf.OpCreate && op.Op != olaf.OpUpdate {
+		return
+	}
+	create := op.Op == olaf.OpCreate
+	switch op.Kind {
+	case olaf.KindService:
+		s.service(prefix+"service.", op.Service, create)
+	case olaf.KindRoute:
+		s.route(prefix+"route.", op.Route, create)
+		if create && op.Route != nil {
+			s.required(prefix+"route.service_name", op.Route.ServiceName)
+		}
+	case olaf.KindPlugin:
+		s.plugin(prefix+"plugin.", op.Plugin)
+	}
+}
+
+func (s *schema) service(prefix string, svc *olaf.Service, create bool) {
+	if s.kind == "" {
+		s.kind = olaf.KindService
+	}
+	if !s.entity(prefix, olaf.KindService, svc) {
+		return
+	}
+
+	s.name(prefix+"name", svc.Name, create)
+	if s.required(prefix+"upstream", svc.Upstream) {
+		s.upstream(prefix+"upstream.", svc.Upstream)
+	}
+}
+
+func (s *schema) upstream(prefix string, u *olaf.Upstream) {
+	if !s.entity(prefix, olaf.KindUpstream, u) {
+		return
+	}
+
+	s.required(prefix+"backends", len(u.Backends))
+	for i, b := range u.Backends {
+		p := fmt.Sprintf("%sbackends[%d]", prefix, i)
+		if !s.required(p, b) {
+			continue
+		}
+		s.check(p+".dial", b.Dial, validating.Is(isHostPort), "must be in the form of \"host:port\"")
+		s.check(p+".max_requests", b.MaxRequests, validating.Gte(0), "must not be negative")
+	}
+
+	if u.HTTP != nil {
+		s.duration(prefix+"http.dial_timeout", u.HTTP.DialTimeout)
+	}
+
+	if lb := u.LoadBalancing; lb != nil {
+		s.oneOf(prefix+"lb.policy", lb.Policy, lbPolicies)
+		s.duration(prefix+"lb.try_duration", lb.TryDuration)
+		s.duration(prefix+"lb.interval", lb.Interval)
+	}
+
+	if hc := u.ActiveHealthChecks; hc != nil {
+		s.check(prefix+"active_hc.uri", hc.URI, validating.Match(rePath), "must start with \"/\"")
+		s.check(prefix+"active_hc.port", hc.Port, validating.Range(0, 65535), "must be a valid port")
+		s.duration(prefix+"active_hc.interval", hc.Interval)
+		s.duration(prefix+"active_hc.timeout", hc.Timeout)
+		s.statusCode(prefix+"active_hc.status_code", hc.StatusCode)
+	}
+}
+
+func (s *schema) route(prefix string, r *olaf.Route, create bool) {
+	if s.kind == "" {
+		s.kind = olaf.KindRoute
+	}
+	if !s.entity(prefix, olaf.KindRoute, r) {
+		return
+	}
+
+	s.name(prefix+"name", r.Name, create)
+	s.name(prefix+"service_name", r.ServiceName, false)
+
+	s.oneOf(prefix+"protocol", r.Protocol, []string{"http", "https"})
+	for i, m := range r.Methods {
+		s.oneOf(fmt.Sprintf("%smethods[%d]", prefix, i), m, methods)
+		s.required(fmt.Sprintf("%smethods[%d]", prefix, i), m)
+	}
+	for i, h := range r.Hosts {
+		s.required(fmt.Sprintf("%shosts[%d]", prefix, i), h)
+	}
+	for i, p := range r.Paths {
+		s.check(fmt.Sprintf("%spaths[%d]", prefix, i), p, validating.Is(isPath), `must start with "/", or be a valid regexp path like "~name: pattern"`)
+	}
+
+	if r.Response != nil {
+		s.statusCode(prefix+"response.status_code", r.Response.StatusCode)
+	}
+}
+
+func (s *schema) plugin(prefix string, p *olaf.Plugin) {
+	if s.kind == "" {
+		s.kind = olaf.KindPlugin
+	}
+	if !s.entity(prefix, olaf.KindPlugin, p) {
+		return
+	}
+
+	s.name(prefix+"name", p.Name, false)
+	s.name(prefix+"service_name", p.ServiceName, false)
+	s.name(prefix+"route_name", p.RouteName, false)
+	if s.required(prefix+"type", p.Type) {
+		s.oneOf(prefix+"type", p.Type, PluginTypes)
+	}
+
+	switch p.Type {
+	case olaf.PluginTypeCanary:
+		config := new(olaf.PluginCanaryConfig)
+		if !s.check(prefix+"config", p.Config, validating.Is(decodesTo(config)), "must be a valid canary config") {
+			return
+		}
+		s.required(prefix+"config.upstream", config.UpstreamServiceName)
+		if len(config.Matcher) > 0 {
+			s.check(prefix+"config.matcher", config, validating.Is(func(value interface{}) bool {
+				c := value.(*olaf.PluginCanaryConfig)
+				return c.KeyName == "" && c.KeyType == "" && c.Whitelist == "" && c.Percentage == nil
+			}), "is mutually exclusive with key, type, whitelist and percentage")
+		} else if config.Percentage != nil {
+			s.percentage(prefix+"config.percentage", *config.Percentage)
+			s.check(prefix+"config.percentage", config, validating.Is(func(value interface{}) bool {
+				c := value.(*olaf.PluginCanaryConfig)
+				return c.KeyType == "" && c.Whitelist == ""
+			}), "is mutually exclusive with type and whitelist")
+			s.required(prefix+"config.key", config.KeyName)
+		}
+
+	case olaf.PluginTypeTrafficSplit:
+		config := new(olaf.PluginTrafficSplitConfig)
+		if !s.check(prefix+"config", p.Config, validating.Is(decodesTo(config)), "must be a valid traffic_split config") {
+			return
+		}
+		if !s.required(prefix+"config.splits", len(config.Splits)) {
+			return
+		}
+		total := 0
+		for i, split := range config.Splits {
+			field := fmt.Sprintf("%sconfig.splits[%d].", prefix, i)
+			if split == nil {
+				split = new(olaf.TrafficSplit)
+			}
+			if !s.required(field+"service", split.ServiceName) {
+				continue
+			}
+			s.percentage(field+"weight", split.Weight)
+			total += split.Weight
+		}
+		s.check(prefix+"config.splits", total, validating.In(100), "weights must add up to 100")
+
+	case olaf.PluginTypeMirror:
+		config := new(olaf.PluginMirrorConfig)
+		if !s.check(prefix+"config", p.Config, validating.Is(decodesTo(config)), "must be a valid mirror config") {
+			return
+		}
+		s.required(prefix+"config.upstream", config.UpstreamServiceName)
+		s.percentage(prefix+"config.percentage", config.Percentage)
+		s.check(prefix+"config.max_body_size", config.MaxBodySize, validating.Gte(int64(0)), "must not be negative")
+		s.duration(prefix+"config.timeout", config.Timeout)
+
+	case olaf.PluginTypeFault:
+		config := new(olaf.PluginFaultConfig)
+		if !s.check(prefix+"config", p.Config, validating.Is(decodesTo(config)), "must be a valid fault config") {
+			return
+		}
+		s.check(prefix+"config", config, validating.Is(func(value interface{}) bool {
+			c := value.(*olaf.PluginFaultConfig)
+			return c.Delay != "" || c.AbortStatus != 0
+		}), "must have delay or abort_status")
+		s.duration(prefix+"config.delay", config.Delay)
+		s.duration(prefix+"config.max_delay", config.MaxDelay)
+		s.percentage(prefix+"config.delay_percentage", config.DelayPercentage)
+		s.statusCode(prefix+"config.abort_status", config.AbortStatus)
+		s.percentage(prefix+"config.abort_percentage", config.AbortPercentage)
+		if len(config.Consumers) > 0 {
+			s.required(prefix+"config.consumer_key", config.ConsumerKey)
+		}
+	}
+}
+
+func isDuration(value interface{}) bool {
+	_, err := time.ParseDuration(value.(string))
+	return err == nil
+}
+
+// isPath reports whether value is a normal path, or a regexp path whose
+// pattern compiles (see builder.ParseRegexpPath).
+func isPath(value interface{}) bool {
+	p := value.(string)
+	if _, pattern, ok := builder.ParseRegexpPath(p); ok {
+		_, err := regexp.Compile(pattern)
+		return err == nil
+	}
+	return strings.HasPrefix(p, "/")
+}
+
+func isHostPort(value interface{}) bool {
+	_, _, err := net.SplitHostPort(value.(string))
+	return err == nil
+}
+
+// decodesTo returns a function, which reports whether a plugin config can be
+// decoded into out.
+func decodesTo(out interface{}) func(interface{}) bool {
+	return func(value interface{}) bool {
+		return mapstructure.Decode(value, out) == nil
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RussellLuo/kun/pkg/httpoption"
+	"github.com/RussellLuo/olaf"
+)
+
+func TestNewValidators(t *testing.T) {
+	options := httpoption.NewOptions(NewValidators())
+
+	cases := []struct {
+		name       string
+		op         string
+		in         interface{}
+		wantFields []*olaf.FieldError
+	}{
+		{
+			name: "valid service",
+			op:   "CreateService",
+			in: &CreateServiceRequest{Svc: &olaf.Service{
+				Name: "production",
+				Upstream: &olaf.Upstream{
+					Backends: []*olaf.Backend{{Dial: "localhost:2222"}},
+					HTTP:     &olaf.TransportHTTP{DialTimeout: "2s"},
+				},
+			}},
+		},
+		{
+			name: "invalid service",
+			op:   "CreateService",
+			in: &CreateServiceRequest{Svc: &olaf.Service{
+				Name: "pro duction",
+				Upstream: &olaf.Upstream{
+					HTTP:          &olaf.TransportHTTP{DialTimeout: "2"},
+					LoadBalancing: &olaf.LoadBalancing{Policy: "unknown"},
+				},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "name", Message: "must be 1 to 128 letters, digits, '.', '_', '~' or '-'"},
+				{Field: "upstream.backends", Message: "is required"},
+				{Field: "upstream.http.dial_timeout", Message: `must be a duration like "300ms" or "2s"`},
+				{Field: "upstream.lb.policy", Message: "must be one of random, random_choose, least_conn, round_robin, first, ip_hash, uri_hash, header, cookie"},
+			},
+		},
+		{
+			name: "invalid route",
+			op:   "CreateRoute",
+			in: &CreateRouteRequest{Route: &olaf.Route{
+				Name:    "foo",
+				Matcher: olaf.Matcher{Paths: []string{"foo"}},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "paths[0]", Message: `must start with "/", or be a valid regexp path like "~name: pattern"`},
+				{Field: "service_name", Message: "is required"},
+			},
+		},
+		{
+			name: "regexp paths",
+			op:   "CreateRoute",
+			in: &CreateRouteRequest{Route: &olaf.Route{
+				Name:        "foo",
+				ServiceName: "bar",
+				Matcher:     olaf.Matcher{Paths: []string{"/foo", `~:^/bar/\w+`, `~baz: ^/baz/(\d+)$`, "~qux: ^/qux/(["}},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "paths[3]", Message: `must start with "/", or be a valid regexp path like "~name: pattern"`},
+			},
+		},
+		{
+			name: "invalid plugin",
+			op:   "CreatePlugin",
+			in: &CreatePluginRequest{P: &olaf.Plugin{
+				Type: "unknown",
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "type", Message: "must be one of canary, traffic_split, mirror, fault, request_body_var, rate_limit"},
+			},
+		},
+		{
+			name: "canary with zero percentage",
+			op:   "CreatePlugin",
+			in: &CreatePluginRequest{P: &olaf.Plugin{
+				Type: olaf.PluginTypeCanary,
+				Config: map[string]interface{}{
+					"upstream":   "staging",
+					"percentage": 0,
+				},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "config.key", Message: "is required"},
+			},
+		},
+		{
+			name: "invalid canary percentage",
+			op:   "CreatePlugin",
+			in: &CreatePluginRequest{P: &olaf.Plugin{
+				Type: olaf.PluginTypeCanary,
+				Config: map[string]interface{}{
+					"upstream":   "staging",
+					"whitelist":  "$ == 1",
+					"percentage": 120,
+				},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "config.key", Message: "is required"},
+				{Field: "config.percentage", Message: "must be between 0 and 100"},
+			},
+		},
+		{
+			name: "invalid mirror",
+			op:   "CreatePlugin",
+			in: &CreatePluginRequest{P: &olaf.Plugin{
+				Type: olaf.PluginTypeMirror,
+				Config: map[string]interface{}{
+					"percentage":    200,
+					"max_body_size": -1,
+					"timeout":       "1",
+				},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "config.max_body_size", Message: "must not be negative"},
+				{Field: "config.percentage", Message: "must be between 0 and 100"},
+				{Field: "config.timeout", Message: `must be a duration like "300ms" or "2s"`},
+				{Field: "config.upstream", Message: "is required"},
+			},
+		},
+		{
+			name: "invalid fault",
+			op:   "CreatePlugin",
+			in: &CreatePluginRequest{P: &olaf.Plugin{
+				Type: olaf.PluginTypeFault,
+				Config: map[string]interface{}{
+					"max_delay":        "1",
+					"delay_percentage": 101,
+					"consumers":        []string{"alice"},
+				},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "config", Message: "must have delay or abort_status"},
+				{Field: "config.consumer_key", Message: "is required"},
+				{Field: "config.delay_percentage", Message: "must be between 0 and 100"},
+				{Field: "config.max_delay", Message: `must be a duration like "300ms" or "2s"`},
+			},
+		},
+		{
+			name: "fault with max_delay only",
+			op:   "CreatePlugin",
+			in: &CreatePluginRequest{P: &olaf.Plugin{
+				Type:   olaf.PluginTypeFault,
+				Config: map[string]interface{}{"max_delay": "1s"},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "config", Message: "must have delay or abort_status"},
+			},
+		},
+		{
+			name: "invalid traffic split",
+			op:   "CreatePlugin",
+			in: &CreatePluginRequest{P: &olaf.Plugin{
+				Type: olaf.PluginTypeTrafficSplit,
+				Config: map[string]interface{}{
+					"splits": []interface{}{
+						map[string]interface{}{"service": "v1", "weight": 90},
+						map[string]interface{}{"weight": 20},
+					},
+				},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "config.splits", Message: "weights must add up to 100"},
+				{Field: "config.splits[1].service", Message: "is required"},
+			},
+		},
+		{
+			name: "invalid batch",
+			op:   "Batch",
+			in: &BatchRequest{Ops: []*olaf.Operation{
+				{Op: olaf.OpCreate, Kind: olaf.KindPlugin, Plugin: &olaf.Plugin{Type: olaf.PluginTypeCanary}},
+				{Op: olaf.OpRename, Kind: olaf.KindRoute, Name: "foo"},
+			}},
+			wantFields: []*olaf.FieldError{
+				{Field: "ops[0].plugin.config.upstream", Message: "is required"},
+				{Field: "ops[1].new_name", Message: "is required"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := options.RequestValidator(c.op).Validate(c.in)
+			if c.wantFields == nil {
+				if err != nil {
+					t.Fatalf("Err: got (%v), want (<nil>)", err)
+				}
+				return
+			}
+
+			var validationErr *olaf.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Err: got (%v), want (%T)", err, validationErr)
+			}
+			if !reflect.DeepEqual(validationErr.Fields, c.wantFields) {
+				t.Fatalf("Fields: got (%v), want (%v)", validationErr.Fields, c.wantFields)
+			}
+		})
+	}
+}
+
+func TestNewRouter(t *testing.T) {
+	// The requests are rejected before reaching the (nil) service.
+	router := NewRouter(nil, Codec{})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/services", strings.NewReader(`{"name": "pro duction"}`))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Status: got (%d), want (%d)", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,522 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/validating/v2"
+	"github.com/go-chi/chi"
+)
+
+// KindWebhook is the kind of the webhooks.
+const KindWebhook = "webhook"
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// The headers of the webhook requests.
+const (
+	WebhookEventHeader     = "X-Olaf-Event"
+	WebhookDeliveryHeader  = "X-Olaf-Delivery"
+	WebhookSignatureHeader = "X-Olaf-Signature"
+)
+
+// Webhook is an HTTP endpoint to be notified of the config changes.
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// The secret for signing the payloads, which is only returned on
+	// creation. If not specified, a random one will be generated.
+	Secret string `json:"secret,omitempty"`
+
+	// The filters. An event is sent if its kind is any of Kinds, and the
+	// entity has any of Tags. Empty filters match all the events.
+	Kinds []string `json:"kinds"`
+	Tags  []string `json:"tags"`
+
+	// The identity who registered the webhook (see Webhooks.Handler), whose
+	// permissions the events are authorized against if RBAC is enabled.
+	Identity string `json:"identity,omitempty"`
+
+	CreatedAt int64 `json:"created_at"`
+}
+
+func (wh *Webhook) matches(e *olaf.Event) bool {
+	if len(wh.Kinds) > 0 && !containsString(wh.Kinds, e.Kind) {
+		return false
+	}
+	if len(wh.Tags) == 0 {
+		return true
+	}
+
+	var tags []string
+	switch entity := e.Entity.(type) {
+	case *olaf.Service:
+		tags = entity.Tags
+	case *olaf.Route:
+		tags = entity.Tags
+	case *olaf.Plugin:
+		tags = entity.Tags
+	}
+	for _, tag := range wh.Tags {
+		if containsString(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records the delivery of an event to a webhook.
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	Revision  int64  `json:"revision"`
+	Event     string `json:"event"`
+
+	Success  bool `json:"success"`
+	Attempts int  `json:"attempts"`
+	// The status code and the error of the last attempt.
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// The Unix time of the last attempt.
+	Time int64 `json:"time"`
+}
+
+// Sign returns the signature of payload, which is sent in the X-Olaf-Signature
+// header in the form of "sha256=<hex-encoded HMAC-SHA256>".
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload) // nolint:errcheck
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookQueueSize is the number of the pending events per webhook. Once the
+// queue is full, the new events are dropped (and recorded as failed).
+const webhookQueueSize = 100
+
+// Webhooks sends the events of a notifier to the registered webhooks. The
+// webhooks are kept in memory.
+//
+// Each webhook receives the events in order. A delivery is considered failed
+// if the webhook does not respond with a 2xx status code, in which case it
+// will be retried with exponential backoff.
+type Webhooks struct {
+	n      olaf.Notifier
+	client *http.Client
+
+	// The maximum number of attempts per delivery.
+	MaxAttempts int
+	// The delay before the first retry, which doubles for each following
+	// retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// The number of the most recent deliveries kept per webhook.
+	DeliveryLogSize int
+
+	mu    sync.RWMutex
+	hooks map[string]*webhook
+}
+
+// NewWebhooks creates Webhooks, which sends the events of n by using client.
+// If client is nil, http.DefaultClient is used.
+func NewWebhooks(n olaf.Notifier, client *http.Client) *Webhooks {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Webhooks{
+		n:               n,
+		client:          client,
+		MaxAttempts:     5,
+		Backoff:         time.Second,
+		MaxBackoff:      time.Minute,
+		DeliveryLogSize: 100,
+		hooks:           make(map[string]*webhook),
+	}
+}
+
+type webhook struct {
+	*Webhook
+	// If not nil, only the events readable by the identity are sent.
+	authz *rbac
+
+	queue chan *olaf.Event
+	done  chan struct{}
+
+	mu         sync.Mutex
+	deliveries []*WebhookDelivery
+}
+
+// Run watches the events and dispatches them to the webhooks, until ctx is
+// done.
+func (w *Webhooks) Run(ctx context.Context) error {
+	since := int64(-1)
+	for {
+		events, err := w.n.Watch(ctx, since)
+		if errors.Is(err, olaf.ErrRevisionCompacted) {
+			log.Printf("webhooks: events after revision %d are lost", since)
+			since = -1
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for e := range events {
+			since = e.Revision
+			w.dispatch(e)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Too slow to keep up with the events, watch again.
+	}
+}
+
+func (w *Webhooks) dispatch(e *olaf.Event) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for _, h := range w.hooks {
+		if !h.matches(e) || !h.authorized(e) {
+			continue
+		}
+		select {
+		case h.queue <- e:
+		default:
+			w.record(h, &WebhookDelivery{
+				ID:        olaf.NewID(),
+				WebhookID: h.ID,
+				Revision:  e.Revision,
+				Event:     e.Type,
+				Error:     "queue is full",
+				Time:      time.Now().Unix(),
+			})
+		}
+	}
+}
+
+// authorized reports whether the identity of h is allowed to read the entity
+// of e, the same way as NewEventsHandler does.
+func (h *webhook) authorized(e *olaf.Event) bool {
+	if h.authz == nil {
+		return true
+	}
+	ctx := NewContextWithIdentity(context.Background(), h.Identity)
+	return h.authz.authorizeEvent(ctx, e) == nil
+}
+
+func (w *Webhooks) work(h *webhook) {
+	for {
+		select {
+		case e := <-h.queue:
+			w.deliver(h, e)
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (w *Webhooks) deliver(h *webhook, e *olaf.Event) {
+	d := &WebhookDelivery{
+		ID:        olaf.NewID(),
+		WebhookID: h.ID,
+		Revision:  e.Revision,
+		Event:     e.Type,
+	}
+	defer w.record(h, d)
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+
+	backoff := w.Backoff
+	for d.Attempts < w.MaxAttempts {
+		if d.Attempts > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-h.done:
+				return
+			}
+			if backoff *= 2; backoff > w.MaxBackoff {
+				backoff = w.MaxBackoff
+			}
+		}
+
+		d.Attempts++
+		d.Time = time.Now().Unix()
+		d.StatusCode, err = w.post(h, d.ID, e.Type, payload)
+		if err == nil {
+			d.Success, d.Error = true, ""
+			return
+		}
+		d.Error = err.Error()
+	}
+}
+
+func (w *Webhooks) post(h *webhook, deliveryID, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookSignatureHeader, Sign(h.Secret, payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close() // nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *Webhooks) record(h *webhook, d *WebhookDelivery) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.deliveries = append(h.deliveries, d)
+	if len(h.deliveries) > w.DeliveryLogSize {
+		h.deliveries = h.deliveries[len(h.deliveries)-w.DeliveryLogSize:]
+	}
+}
+
+// Create registers a webhook, and returns it with the ID and the secret. The
+// webhook receives all the events matching its filters.
+func (w *Webhooks) Create(wh *Webhook) (*Webhook, error) {
+	return w.create(wh, nil)
+}
+
+// create registers a webhook, whose events are authorized by authz if it's
+// not nil.
+func (w *Webhooks) create(wh *Webhook, authz *rbac) (*Webhook, error) {
+	if err := validateWebhook(wh); err != nil {
+		return nil, err
+	}
+
+	newWH := *wh
+	newWH.ID = olaf.NewID()
+	newWH.CreatedAt = time.Now().Unix()
+	if newWH.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		newWH.Secret = hex.EncodeToString(secret)
+	}
+
+	h := &webhook{
+		Webhook: &newWH,
+		authz:   authz,
+		queue:   make(chan *olaf.Event, webhookQueueSize),
+		done:    make(chan struct{}),
+	}
+	w.mu.Lock()
+	w.hooks[newWH.ID] = h
+	w.mu.Unlock()
+
+	go w.work(h)
+
+	created := newWH
+	return &created, nil
+}
+
+func validateWebhook(wh *Webhook) error {
+	s := newSchema()
+	s.kind = KindWebhook
+
+	if s.required("url", wh.URL) {
+		s.check("url", wh.URL, validating.Is(isHTTPURL), "must be an absolute HTTP(S) URL")
+	}
+	for i, kind := range wh.Kinds {
+		s.oneOf(fmt.Sprintf("kinds[%d]", i), kind, []string{olaf.KindService, olaf.KindRoute, olaf.KindPlugin})
+	}
+	return s.validate()
+}
+
+func isHTTPURL(value interface{}) bool {
+	u, err := url.Parse(value.(string))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// List returns all the webhooks (without secrets) in the order of creation.
+func (w *Webhooks) List() []*Webhook {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	hooks := make([]*Webhook, 0, len(w.hooks))
+	for _, h := range w.hooks {
+		hooks = append(hooks, h.public())
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].CreatedAt != hooks[j].CreatedAt {
+			return hooks[i].CreatedAt < hooks[j].CreatedAt
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks
+}
+
+// Get returns the webhook (without the secret) with the given ID.
+func (w *Webhooks) Get(id string) (*Webhook, error) {
+	h, err := w.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return h.public(), nil
+}
+
+// Delete unregisters the webhook with the given ID. The pending deliveries
+// are discarded.
+func (w *Webhooks) Delete(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	h, ok := w.hooks[id]
+	if !ok {
+		return &olaf.EntityError{Kind: KindWebhook, Name: id, Err: ErrWebhookNotFound}
+	}
+	delete(w.hooks, id)
+	close(h.done)
+	return nil
+}
+
+// Deliveries returns the most recent deliveries of the webhook with the given
+// ID, in chronological order.
+func (w *Webhooks) Deliveries(id string) ([]*WebhookDelivery, error) {
+	h, err := w.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*WebhookDelivery{}, h.deliveries...), nil
+}
+
+func (w *Webhooks) get(id string) (*webhook, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	h, ok := w.hooks[id]
+	if !ok {
+		return nil, &olaf.EntityError{Kind: KindWebhook, Name: id, Err: ErrWebhookNotFound}
+	}
+	return h, nil
+}
+
+func (h *webhook) public() *Webhook {
+	wh := *h.Webhook
+	wh.Secret = ""
+	return &wh
+}
+
+// Handler returns the handler of the webhook API, which is to be mounted at
+// /webhooks:
+//
+//	POST   /webhooks                 registers a webhook
+//	GET    /webhooks                 lists the webhooks
+//	GET    /webhooks/{id}            gets a webhook
+//	DELETE /webhooks/{id}            unregisters a webhook
+//	GET    /webhooks/{id}/deliveries lists the recent deliveries of a webhook
+//
+// If policy is not nil, the caller must be allowed to perform the
+// corresponding verb on the kind "webhook", and a webhook only receives the
+// events of the entities that its creator can read (looked up in svc).
+func (w *Webhooks) Handler(policy *Policy, svc Admin) http.Handler {
+	var authz *rbac
+	if policy != nil {
+		authz = &rbac{policy: policy, next: svc}
+	}
+
+	authorize := func(r *http.Request, verb, id string) error {
+		if policy == nil {
+			return nil
+		}
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok {
+			return ErrUnauthenticated
+		}
+		return policy.Authorize(identity, verb, KindWebhook, id, nil)
+	}
+	respond := func(rw http.ResponseWriter, status int, body interface{}, err error) {
+		if err != nil {
+			Codec{}.EncodeFailureResponse(rw, err) // nolint:errcheck
+			return
+		}
+		if body == nil {
+			rw.WriteHeader(status)
+			return
+		}
+		Codec{}.EncodeSuccessResponse(rw, status, body) // nolint:errcheck
+	}
+
+	r := chi.NewRouter()
+	r.Post("/", func(rw http.ResponseWriter, r *http.Request) {
+		if err := authorize(r, VerbCreate, ""); err != nil {
+			respond(rw, 0, nil, err)
+			return
+		}
+		wh := new(Webhook)
+		if err := (Codec{}).DecodeRequestBody(r, wh); err != nil {
+			respond(rw, 0, nil, fmt.Errorf("%w: %v", olaf.ErrInvalidOperation, err))
+			return
+		}
+		wh.Identity, _ = IdentityFromContext(r.Context())
+		created, err := w.create(wh, authz)
+		respond(rw, http.StatusCreated, created, err)
+	})
+	r.Get("/", func(rw http.ResponseWriter, r *http.Request) {
+		if err := authorize(r, VerbRead, ""); err != nil {
+			respond(rw, 0, nil, err)
+			return
+		}
+		respond(rw, http.StatusOK, w.List(), nil)
+	})
+	r.Get("/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := authorize(r, VerbRead, id); err != nil {
+			respond(rw, 0, nil, err)
+			return
+		}
+		wh, err := w.Get(id)
+		respond(rw, http.StatusOK, wh, err)
+	})
+	r.Delete("/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := authorize(r, VerbDelete, id); err != nil {
+			respond(rw, 0, nil, err)
+			return
+		}
+		respond(rw, http.StatusNoContent, nil, w.Delete(id))
+	})
+	r.Get("/{id}/deliveries", func(rw http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := authorize(r, VerbRead, id); err != nil {
+			respond(rw, 0, nil, err)
+			return
+		}
+		deliveries, err := w.Deliveries(id)
+		respond(rw, http.StatusOK, deliveries, err)
+	})
+	return r
+}
@@ -0,0 +1,266 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+// watchedNotifier closes watching once Watch is called.
+type watchedNotifier struct {
+	olaf.Notifier
+	watching chan struct{}
+}
+
+func (n *watchedNotifier) Watch(ctx context.Context, since int64) (<-chan *olaf.Event, error) {
+	ch, err := n.Notifier.Watch(ctx, since)
+	close(n.watching)
+	return ch, err
+}
+
+func TestWebhooks(t *testing.T) {
+	type received struct {
+		event     string
+		signature string
+		payload   []byte
+	}
+	ch := make(chan received, 10)
+	failures := 1
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first request to trigger a retry.
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		payload, _ := io.ReadAll(r.Body)
+		ch <- received{
+			event:     r.Header.Get(WebhookEventHeader),
+			signature: r.Header.Get(WebhookSignatureHeader),
+			payload:   payload,
+		}
+	}))
+	defer receiver.Close()
+
+	events := olaf.NewEventLog(10)
+	n := &watchedNotifier{Notifier: events, watching: make(chan struct{})}
+	webhooks := NewWebhooks(n, receiver.Client())
+	webhooks.Backoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go webhooks.Run(ctx) // nolint:errcheck
+
+	wh, err := webhooks.Create(&Webhook{
+		URL:   receiver.URL,
+		Kinds: []string{olaf.KindRoute},
+		Tags:  []string{"team-a"},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	<-n.watching
+	events.Publish(
+		&olaf.Event{Type: olaf.EventCreate, Kind: olaf.KindService, Name: "c", Entity: &olaf.Service{Name: "c", Tags: []string{"team-a"}}},
+		&olaf.Event{Type: olaf.EventUpdate, Kind: olaf.KindRoute, Name: "b", Entity: &olaf.Route{Name: "b"}},
+		&olaf.Event{Type: olaf.EventUpdate, Kind: olaf.KindRoute, Name: "a", Entity: &olaf.Route{Name: "a", Tags: []string{"team-a"}}},
+	)
+
+	var got received
+	select {
+	case got = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out")
+	}
+
+	if got.event != olaf.EventUpdate {
+		t.Fatalf("Event: got (%s), want (%s)", got.event, olaf.EventUpdate)
+	}
+	if want := Sign(wh.Secret, got.payload); got.signature != want {
+		t.Fatalf("Signature: got (%s), want (%s)", got.signature, want)
+	}
+	var e olaf.Event
+	if err := json.Unmarshal(got.payload, &e); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if e.Kind != olaf.KindRoute || e.Name != "a" {
+		t.Fatalf("Event: got (%s %s), want (route a)", e.Kind, e.Name)
+	}
+
+	// The delivery is recorded after the receiver responds.
+	var deliveries []*WebhookDelivery
+	for i := 0; i < 100 && len(deliveries) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if deliveries, err = webhooks.Deliveries(wh.ID); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if len(deliveries) != 1 || !deliveries[0].Success || deliveries[0].Attempts != 2 {
+		t.Fatalf("Deliveries: got (%+v), want one successful delivery after 2 attempts", deliveries)
+	}
+}
+
+func TestWebhooks_Handler(t *testing.T) {
+	webhooks := NewWebhooks(olaf.NewEventLog(10), nil)
+	server := httptest.NewServer(webhooks.Handler(nil, nil))
+	defer server.Close()
+
+	do := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/", `{"url": "http://example.com/hook", "kinds": ["route"]}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("StatusCode: got (%d), want (%d)", resp.StatusCode, http.StatusCreated)
+	}
+	created := new(Webhook)
+	if err := json.NewDecoder(resp.Body).Decode(created); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if created.ID == "" || created.Secret == "" {
+		t.Fatalf("Webhook: got (%+v), want one with ID and secret", created)
+	}
+
+	cases := []struct {
+		name       string
+		inMethod   string
+		inPath     string
+		inBody     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "get without secret",
+			inMethod:   http.MethodGet,
+			inPath:     "/" + created.ID,
+			wantStatus: http.StatusOK,
+			wantBody:   `"url":"http://example.com/hook","kinds":["route"]`,
+		},
+		{
+			name:       "invalid URL",
+			inMethod:   http.MethodPost,
+			inPath:     "/",
+			inBody:     `{"url": "example.com", "kinds": ["upstream"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"fields":[{"field":"kinds[0]","message":"must be one of service, route, plugin"},{"field":"url","message":"must be an absolute HTTP(S) URL"}]`,
+		},
+		{
+			name:       "delete",
+			inMethod:   http.MethodDelete,
+			inPath:     "/" + created.ID,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "not found",
+			inMethod:   http.MethodGet,
+			inPath:     "/" + created.ID + "/deliveries",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"code":"not_found"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := do(c.inMethod, c.inPath, c.inBody)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != c.wantStatus {
+				t.Fatalf("StatusCode: got (%d), want (%d)", resp.StatusCode, c.wantStatus)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if strings.Contains(string(body), "secret") {
+				t.Fatalf("Body: got (%s), want no secret", body)
+			}
+			if !strings.Contains(string(body), c.wantBody) {
+				t.Fatalf("Body: got (%s), want (...%s...)", body, c.wantBody)
+			}
+		})
+	}
+}
+
+func TestWebhooks_RBAC(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	policyFile := filepath.Join(dir, "policy.yaml")
+	writeFile(t, configFile, testRBACConfig)
+	writeFile(t, policyFile, `
+roles:
+- name: team-b
+  rules:
+  - kinds: ["webhook"]
+    verbs: ["*"]
+  - kinds: ["service", "route", "plugin"]
+    verbs: ["read"]
+    services: ["team-b-*"]
+bindings:
+- role: team-b
+  identities: ["carol"]
+`)
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	names := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e olaf.Event
+		json.NewDecoder(r.Body).Decode(&e) // nolint:errcheck
+		names <- e.Name
+	}))
+	defer receiver.Close()
+
+	events := olaf.NewEventLog(10)
+	n := &watchedNotifier{Notifier: events, watching: make(chan struct{})}
+	webhooks := NewWebhooks(n, receiver.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go webhooks.Run(ctx) // nolint:errcheck
+
+	// Register the webhook as carol.
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "`+receiver.URL+`"}`))
+	r = r.WithContext(NewContextWithIdentity(r.Context(), "carol"))
+	w := httptest.NewRecorder()
+	webhooks.Handler(policy, yaml.New(configFile)).ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("StatusCode: got (%d), want (%d)", w.Code, http.StatusCreated)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"identity":"carol"`) {
+		t.Fatalf("Body: got (%s), want (...\"identity\":\"carol\"...)", body)
+	}
+
+	<-n.watching
+	events.Publish(
+		&olaf.Event{Type: olaf.EventUpdate, Kind: olaf.KindService, Name: "team-a-web", Entity: &olaf.Service{Name: "team-a-web"}},
+		&olaf.Event{Type: olaf.EventUpdate, Kind: olaf.KindRoute, Name: "web", Entity: &olaf.Route{Name: "web", ServiceName: "team-a-web"}},
+		&olaf.Event{Type: olaf.EventUpdate, Kind: olaf.KindService, Name: "team-b-web", Entity: &olaf.Service{Name: "team-b-web"}},
+	)
+
+	// The events are delivered in order, so the first one received is the
+	// first one that carol can read.
+	select {
+	case got := <-names:
+		if got != "team-b-web" {
+			t.Fatalf("Event: got (%s), want (team-b-web)", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out")
+	}
+}
@@ -0,0 +1,20 @@
+package olaf
+
+import (
+	"hash/fnv"
+)
+
+// Bucket returns the bucket, from 0 to 99, which the client identified by key
+// falls into. The result is deterministic, so a client always stays in the
+// same bucket, and the clients in the first N buckets stay there while N grows.
+func Bucket(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key)) // nolint:errcheck
+	return int(h.Sum32() % 100)
+}
+
+// InBucket reports whether the client identified by key falls into the first
+// percentage buckets. An empty key never does.
+func InBucket(key string, percentage int) bool {
+	return key != "" && Bucket(key) < percentage
+}
@@ -75,12 +75,20 @@ NextRoute:
 					return err
 				}
 
+				subRoutes, err := builder.BuildWithOptions(data, builder.Options{
+					AutoPriority: mod.AutoPriority,
+				})
+				if err != nil {
+					return err
+				}
+
 				// Replace the `olaf` handler with a `subroute` handler.
 				delete(h, "type")
 				delete(h, "path")
 				delete(h, "timeout")
+				delete(h, "auto_priority")
 				h["handler"] = "subroute"
-				h["routes"] = builder.Build(data)
+				h["routes"] = subRoutes
 
 				// We assume that there is only one `olaf` handler in the list.
 				continue NextRoute
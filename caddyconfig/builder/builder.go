@@ -1,9 +1,13 @@
 package builder
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,28 +32,126 @@ const (
 	networkUnix = "unix"
 )
 
-func Build(data *olaf.Data) (routes []map[string]interface{}) {
+// Error reports a problem with a field of an entity, which is found while
+// building the Caddy routes.
+type Error struct {
+	Kind  string
+	Name  string
+	Field string // Empty if the problem is about the entity as a whole.
+	Err   error
+}
+
+func (e *Error) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s %q: %v", e.Kind, e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s %q %s: %v", e.Kind, e.Name, e.Field, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Errors holds all the problems found in a configuration.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+func (e *Errors) add(kind, name, field string, err error) {
+	*e = append(*e, &Error{Kind: kind, Name: name, Field: field, Err: err})
+}
+
+// merge appends the problems held by err, which must be nil or of type Errors.
+func (e *Errors) merge(err error) {
+	if errs, ok := err.(Errors); ok {
+		*e = append(*e, errs...)
+	}
+}
+
+// err returns e as an error, or nil if there is no problem.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Options are the options for building the Caddy routes.
+type Options struct {
+	// Whether to order the routes of the same priority by the specificity of
+	// their matchers (see sortRoutes), the way Kong's router does. If false,
+	// only path specificity is taken into account.
+	AutoPriority bool
+}
+
+// Build builds the Caddy routes from data with the default options. All
+// the problems found in data, if any, are returned together as an Errors.
+func Build(data *olaf.Data) (routes []map[string]interface{}, err error) {
+	return BuildWithOptions(data, Options{})
+}
+
+// BuildWithOptions is like Build, but with the given options.
+func BuildWithOptions(data *olaf.Data, opts Options) (routes []map[string]interface{}, err error) {
 	services := data.Services
 	plugins := data.Plugins
 
+	var errs Errors
+
 	// Build the routes from highest priority to lowest.
 	// The route that has a higher priority will be matched earlier.
-	for _, r := range sortRoutes(data.Routes) {
+	for _, r := range sortRoutes(data.Routes, opts.AutoPriority) {
 		if services[r.ServiceName] == nil {
-			panic(fmt.Errorf("service %q of route %q not found", r.ServiceName, r.Name))
+			errs.add(olaf.KindRoute, r.Name, "service_name", fmt.Errorf("service %q not found", r.ServiceName))
+			continue
 		}
 
+		subRoutes, err := buildSubRoutes(r, services, plugins)
+		errs.merge(err)
+
 		routes = append(routes, map[string]interface{}{
 			"match": buildRouteMatches(r.Matcher),
 			"handle": []map[string]interface{}{
 				{
 					"handler": "subroute",
-					"routes":  buildSubRoutes(r, services, plugins),
+					"routes":  subRoutes,
 				},
 			},
 		})
 	}
 
+	if err := errs.unique().err(); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// unique returns the problems in e, sorted by their entity paths, with
+// the duplicates removed. A duplicate occurs if a service (or plugin) is
+// used by more than one route.
+func (e Errors) unique() (errs Errors) {
+	seen := make(map[string]bool)
+	for _, err := range e {
+		msg := err.Error()
+		if !seen[msg] {
+			seen[msg] = true
+			errs = append(errs, err)
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i], errs[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Field < b.Field
+	})
 	return
 }
 
@@ -70,28 +172,127 @@ func buildStaticResponse(resp *olaf.StaticResponse) []map[string]interface{} {
 	return []map[string]interface{}{m}
 }
 
-// sortRoutes sorts the given routes from highest priority to lowest.
-func sortRoutes(r map[string]*olaf.Route) (routes []*olaf.Route) {
+// Hash returns the SHA-256 hash, in hex, of the JSON encoding of the given
+// routes, which are usually the ones generated by Build. Since Build is
+// deterministic, the same configuration always has the same hash.
+func Hash(routes []map[string]interface{}) (string, error) {
+	// The keys of maps are sorted by encoding/json.
+	b, err := json.Marshal(routes)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sortRoutes sorts the given routes from highest priority to lowest. Routes
+// of the same priority are sorted by path specificity (see pathSpecificity),
+// and then by name, to make the order deterministic.
+//
+// If auto is true, routes of the same priority are sorted by the specificity
+// of their matchers instead (see matcherSpecificity).
+func sortRoutes(r map[string]*olaf.Route, auto bool) (routes []*olaf.Route) {
 	for _, route := range r {
 		routes = append(routes, route)
 	}
 
-	sort.SliceStable(routes, func(i, j int) bool {
-		return routes[i].Priority > routes[j].Priority
+	specificity := func(r *olaf.Route) []int {
+		if auto {
+			return matcherSpecificity(r.Matcher)
+		}
+		return []int{pathSpecificity(r.Paths)}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		sa, sb := specificity(a), specificity(b)
+		for k := range sa {
+			if sa[k] != sb[k] {
+				return sa[k] > sb[k]
+			}
+		}
+		return a.Name < b.Name
 	})
 
 	return
 }
 
+// matcherSpecificity returns the specificity of matcher, as a list of ranks
+// to be compared in order, following Kong's router:
+//
+//     1. Hosts: exact hosts over wildcard hosts, over no hosts.
+//     2. Paths: longer literal paths over shorter ones (see pathSpecificity).
+//     3. Regexp paths over no regexp paths.
+//     4. More headers over fewer headers.
+//     5. Methods over no methods.
+//
+func matcherSpecificity(matcher olaf.Matcher) []int {
+	host := 0
+	for _, h := range matcher.Hosts {
+		if !strings.Contains(h, "*") {
+			host = 2
+			break
+		}
+		host = 1
+	}
+
+	regexp := 0
+	for _, p := range matcher.Paths {
+		if reRegexpPath.MatchString(p) {
+			regexp = 1
+			break
+		}
+	}
+
+	method := 0
+	if len(matcher.Methods) > 0 {
+		method = 1
+	}
+
+	return []int{host, pathSpecificity(matcher.Paths), regexp, len(matcher.Headers), method}
+}
+
+// ParseRegexpPath reports whether p is a regexp path (in the form of
+// `~name: pattern`, where name is optional), and returns its name and pattern
+// if so.
+func ParseRegexpPath(p string) (name, pattern string, ok bool) {
+	result := reRegexpPath.FindStringSubmatch(p)
+	if len(result) == 0 {
+		return "", "", false
+	}
+	return result[1], result[2], true
+}
+
+// pathSpecificity returns the length of the longest literal path, with
+// any wildcard removed, in paths. Regexp paths are not counted.
+func pathSpecificity(paths []string) (n int) {
+	for _, p := range paths {
+		if reRegexpPath.MatchString(p) {
+			continue
+		}
+		if l := len(strings.Replace(p, "*", "", -1)); l > n {
+			n = l
+		}
+	}
+	return
+}
+
 func buildRouteMatches(matcher olaf.Matcher) (matches []map[string]interface{}) {
 	// Differentiate regexp paths from normal paths.
 	var normalPaths []string
-	regexPaths := make(map[string]string)
+	var regexPaths []string // Keep the order in which they are given.
+	regexNames := make(map[string]string)
 	for _, p := range matcher.Paths {
 		result := reRegexpPath.FindStringSubmatch(p)
 		if len(result) > 0 {
 			name, path := result[1], result[2]
-			regexPaths[path] = name // We assume that name is globally unique if non-empty
+			if _, ok := regexNames[path]; !ok {
+				regexPaths = append(regexPaths, path)
+			}
+			regexNames[path] = name // We assume that name is globally unique if non-empty
 		} else {
 			normalPaths = append(normalPaths, p)
 		}
@@ -121,10 +322,10 @@ func buildRouteMatches(matcher olaf.Matcher) (matches []map[string]interface{})
 	}
 
 	// Build a match for regexp paths.
-	for p, n := range regexPaths {
+	for _, p := range regexPaths {
 		matches = append(matches, buildMatch(map[string]interface{}{
 			"path_regexp": map[string]string{
-				"name":    n,
+				"name":    regexNames[p],
 				"pattern": p,
 			},
 		}))
@@ -133,7 +334,7 @@ func buildRouteMatches(matcher olaf.Matcher) (matches []map[string]interface{})
 	return
 }
 
-func buildSubRoutes(r *olaf.Route, services map[string]*olaf.Service, plugins map[string]*olaf.Plugin) (routes []map[string]interface{}) {
+func buildSubRoutes(r *olaf.Route, services map[string]*olaf.Service, plugins map[string]*olaf.Plugin) (routes []map[string]interface{}, err error) {
 	if r.Response != nil {
 		// This is a STATIC route, any other PROXY-related attributes will be ignored.
 		routes = append(routes, map[string]interface{}{
@@ -144,19 +345,38 @@ func buildSubRoutes(r *olaf.Route, services map[string]*olaf.Service, plugins ma
 
 	// This is a PROXY route.
 
+	var errs Errors
+
 	routes = append(routes, manipulateURI(r.URI, nil)...)
 
 	appliedPlugins, err := findAppliedPlugins(plugins, r)
 	if err != nil {
-		panic(err)
+		errs.add(olaf.KindRoute, r.Name, "plugins", err)
 	}
 
 	// Build routes from plugins.
 	for _, p := range appliedPlugins {
 		switch p.Type {
 		case olaf.PluginTypeCanary: // For the built-in canary plugin.
-			canaryRoutes := canaryReverseProxy(p, services)
+			canaryRoutes, err := canaryReverseProxy(p, services)
+			errs.merge(err)
 			routes = append(routes, canaryRoutes...)
+		case olaf.PluginTypeTrafficSplit: // For the built-in traffic_split plugin.
+			splitRoutes, err := trafficSplitReverseProxy(p, services)
+			errs.merge(err)
+			routes = append(routes, splitRoutes...)
+		case olaf.PluginTypeMirror: // For the built-in mirror plugin.
+			mirrorRoute, err := mirror(p, services)
+			errs.merge(err)
+			if mirrorRoute != nil {
+				routes = append(routes, mirrorRoute)
+			}
+		case olaf.PluginTypeFault: // For the built-in fault plugin.
+			faultRoute, err := fault(p)
+			errs.merge(err)
+			if faultRoute != nil {
+				routes = append(routes, faultRoute)
+			}
 		default: // For other plugins (usually third-party Caddy extensions).
 			routes = append(routes, buildPluginRoute(p))
 		}
@@ -164,8 +384,11 @@ func buildSubRoutes(r *olaf.Route, services map[string]*olaf.Service, plugins ma
 
 	// Normal reverse-proxy routes must come after canary reverse-proxy routes.
 	service := services[r.ServiceName]
-	routes = append(routes, reverseProxy(service, nil))
-	return
+	route, err := reverseProxy(service, nil)
+	errs.merge(err)
+	routes = append(routes, route)
+
+	return routes, errs.err()
 }
 
 func manipulateURI(uri olaf.URI, matcher map[string]interface{}) (routes []map[string]interface{}) {
@@ -220,6 +443,12 @@ func addRouteMatcher(route, matcher map[string]interface{}) map[string]interface
 	return route
 }
 
+// stackablePluginTypes holds the plugin types, several plugins of which can
+// be applied to the same route (from the same scope).
+var stackablePluginTypes = map[string]bool{
+	olaf.PluginTypeCanary: true,
+}
+
 // findAppliedPlugins finds the plugins that have been applied to the given route.
 func findAppliedPlugins(plugins map[string]*olaf.Plugin, r *olaf.Route) ([]*olaf.Plugin, error) {
 	routeServicePlugins := make(map[string][]*olaf.Plugin)
@@ -227,7 +456,9 @@ func findAppliedPlugins(plugins map[string]*olaf.Plugin, r *olaf.Route) ([]*olaf
 	servicePlugins := make(map[string][]*olaf.Plugin)
 	var globalPlugins []*olaf.Plugin
 
-	for _, p := range plugins {
+	// Iterate in the order of names, to make the result deterministic.
+	for _, name := range sortedPluginNames(plugins) {
+		p := plugins[name]
 		if p.Disabled {
 			continue
 		}
@@ -244,85 +475,132 @@ func findAppliedPlugins(plugins map[string]*olaf.Plugin, r *olaf.Route) ([]*olaf
 		}
 	}
 
-	// A plugin (of the same type) will always be run once and only once per request.
+	// The plugins of the same type from the most specific scope, which may be
+	// more than one if stackable (e.g. several canaries of a route), override
+	// the others.
 	// The plugin precedence follows https://docs.konghq.com/2.0.x/admin-api/#precedence
-	typedPlugins := make(map[string]*olaf.Plugin)
-	addPlugin := func(p *olaf.Plugin) {
-		if _, ok := typedPlugins[p.Type]; !ok {
-			typedPlugins[p.Type] = p
+	typedPlugins := make(map[string][]*olaf.Plugin)
+	var err error
+	addPlugins := func(plugins []*olaf.Plugin) {
+		var types []string
+		scoped := make(map[string][]*olaf.Plugin)
+		for _, p := range plugins {
+			if _, ok := scoped[p.Type]; !ok {
+				types = append(types, p.Type)
+			}
+			scoped[p.Type] = append(scoped[p.Type], p)
+		}
+		for _, t := range types {
+			ps := scoped[t]
+			if _, ok := typedPlugins[t]; ok {
+				continue
+			}
+			if len(ps) > 1 && !stackablePluginTypes[t] && err == nil {
+				err = fmt.Errorf("plugins %q and %q are both of type %q, which can not be applied more than once", ps[0].Name, ps[1].Name, t)
+			}
+			typedPlugins[t] = ps
 		}
 	}
-	for _, p := range routeServicePlugins[r.Name] {
-		addPlugin(p)
-	}
-	for _, p := range routePlugins[r.Name] {
-		addPlugin(p)
-	}
-	for _, p := range servicePlugins[r.ServiceName] {
-		addPlugin(p)
+	addPlugins(routeServicePlugins[r.Name])
+	addPlugins(routePlugins[r.Name])
+	addPlugins(servicePlugins[r.ServiceName])
+	addPlugins(globalPlugins)
+	if err != nil {
+		return nil, err
 	}
-	for _, p := range globalPlugins {
-		addPlugin(p)
+
+	// The plugins of the same type run in the order of Order, and then Name.
+	for _, ps := range typedPlugins {
+		sort.SliceStable(ps, func(i, j int) bool {
+			return ps[i].Order < ps[j].Order
+		})
 	}
 
 	return sortPluginsByOrderAfter(typedPlugins)
 }
 
 // sortPluginsByOrderAfter sorts the plugins according to the `OrderAfter` field.
-func sortPluginsByOrderAfter(typedPlugins map[string]*olaf.Plugin) (plugins []*olaf.Plugin, err error) {
-	// If there is only one plugin, return it immediately.
+// The plugins of the same type, which are already sorted, are kept together.
+func sortPluginsByOrderAfter(typedPlugins map[string][]*olaf.Plugin) (plugins []*olaf.Plugin, err error) {
+	// If there is only one plugin type, return its plugins immediately.
 	if len(typedPlugins) == 1 {
-		for _, p := range typedPlugins {
-			plugins = append(plugins, p)
+		for _, ps := range typedPlugins {
+			plugins = append(plugins, ps...)
 		}
 		return
 	}
 
+	// Iterate in the order of types, to make the result deterministic.
+	var types []string
+	for t := range typedPlugins {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	// Find the order of each type, which is shared by all of its plugins.
+	orderAfter := make(map[string]string)
+	orderedBy := make(map[string]*olaf.Plugin)
+	for _, t := range types {
+		orderedBy[t] = typedPlugins[t][0]
+		for _, p := range typedPlugins[t] {
+			// Being ordered after its own type means being ordered after the
+			// previous plugin of the same type, which is always the case.
+			if p.OrderAfter == "" || p.OrderAfter == t {
+				continue
+			}
+			if after, ok := orderAfter[t]; ok && after != p.OrderAfter {
+				return nil, fmt.Errorf("plugin %q (of type %q) is ordered differently from plugin %q", p.Name, t, orderedBy[t].Name)
+			}
+			orderAfter[t] = p.OrderAfter
+			orderedBy[t] = p
+		}
+	}
+
 	processed := make(map[string]bool)
-	emptyOrderAfterPlugins := make(map[string]*olaf.Plugin)
+	var emptyOrderAfterTypes []string
 
-	for _, p := range typedPlugins {
-		if p.OrderAfter == "" {
-			emptyOrderAfterPlugins[p.Type] = p
+	for _, t := range types {
+		if orderAfter[t] == "" {
+			emptyOrderAfterTypes = append(emptyOrderAfterTypes, t)
 			continue
 		}
 
 		// Build the stack per the order dependency.
 		pendingTypes := make(map[string]bool)
-		var stack []*olaf.Plugin
+		var stack []string
 
-		for {
-			if _, ok := processed[p.Type]; ok {
-				if _, ok := pendingTypes[p.Type]; ok {
+		for cur := t; ; {
+			if _, ok := processed[cur]; ok {
+				if _, ok := pendingTypes[cur]; ok {
+					p := orderedBy[cur]
 					return nil, fmt.Errorf("circular order dependency is detected for plugin %q (of type %q)", p.Name, p.Type)
 				}
 				break
 			}
-			processed[p.Type] = true
-			pendingTypes[p.Type] = true
+			processed[cur] = true
+			pendingTypes[cur] = true
 
-			stack = append(stack, p)
+			stack = append(stack, cur)
 
-			if p.OrderAfter == "" {
+			after := orderAfter[cur]
+			if after == "" {
 				break
 			}
-
-			afterP, ok := typedPlugins[p.OrderAfter]
-			if !ok || afterP == nil {
-				return nil, fmt.Errorf("plugin type %q (depended by plugin %q) not found", p.OrderAfter, p.Name)
+			if _, ok := typedPlugins[after]; !ok {
+				return nil, fmt.Errorf("plugin type %q (depended by plugin %q) not found", after, orderedBy[cur].Name)
 			}
-			p = afterP
+			cur = after
 		}
 
 		// Append the plugins in the reverse order.
 		for i := len(stack); i > 0; i-- {
-			p := stack[i-1]
-			plugins = append(plugins, p)
+			plugins = append(plugins, typedPlugins[stack[i-1]]...)
 		}
 	}
 
-	for t, p := range emptyOrderAfterPlugins {
+	for _, t := range emptyOrderAfterTypes {
 		if _, ok := processed[t]; !ok {
+			p := orderedBy[t]
 			return nil, fmt.Errorf("plugin %q (of type %q) is unordered", p.Name, p.Type)
 		}
 	}
@@ -330,6 +608,14 @@ func sortPluginsByOrderAfter(typedPlugins map[string]*olaf.Plugin) (plugins []*o
 	return
 }
 
+func sortedPluginNames(plugins map[string]*olaf.Plugin) (names []string) {
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
 func buildPluginRoute(p *olaf.Plugin) map[string]interface{} {
 	handle := map[string]interface{}{
 		"handler": p.Type,
@@ -342,56 +628,319 @@ func buildPluginRoute(p *olaf.Plugin) map[string]interface{} {
 	}
 }
 
-func canaryReverseProxy(p *olaf.Plugin, services map[string]*olaf.Service) (routes []map[string]interface{}) {
+func canaryReverseProxy(p *olaf.Plugin, services map[string]*olaf.Service) (routes []map[string]interface{}, err error) {
 	if p == nil || p.Type != olaf.PluginTypeCanary {
 		return
 	}
 
+	var errs Errors
+	addErr := func(field string, err error) {
+		errs.add(olaf.KindPlugin, p.Name, field, err)
+	}
+
 	config := new(olaf.PluginCanaryConfig)
 	if err := mapstructure.Decode(p.Config, config); err != nil {
-		panic(fmt.Errorf("config of plugin %q cannot be decoded into olaf.PluginCanaryConfig", p.Name))
+		addErr("config", fmt.Errorf("cannot be decoded: %v", err))
+		return nil, errs.err()
 	}
 
 	s := services[config.UpstreamServiceName]
 	if s == nil {
-		panic(fmt.Errorf("upstream service %q of plugin %q not found", config.UpstreamServiceName, p.Name))
+		addErr("config.upstream", fmt.Errorf("service %q not found", config.UpstreamServiceName))
 	}
 
-	// If the advanced matcher is provided, use it instead.
+	var matcher map[string]interface{}
 	if len(config.Matcher) > 0 {
-		if config.KeyName != "" || config.KeyType != "" || config.Whitelist != "" {
-			panic(fmt.Errorf("invalid config of plugin %q: `matcher` and (`key`, `type`, `whitelist`) are mutually exclusive", p.Name))
+		// If the advanced matcher is provided, use it instead.
+		if config.KeyName != "" || config.KeyType != "" || config.Whitelist != "" || config.Percentage != nil {
+			addErr("config.matcher", fmt.Errorf("`matcher` and (`key`, `type`, `whitelist`, `percentage`) are mutually exclusive"))
+		}
+		matcher = config.Matcher
+	} else if config.Percentage != nil {
+		// Use the matcher `olaf_bucket`, which routes a stable percentage of
+		// clients by the hash of the key.
+		if config.KeyType != "" || config.Whitelist != "" {
+			addErr("config.percentage", fmt.Errorf("`percentage` and (`type`, `whitelist`) are mutually exclusive"))
+		}
+		if *config.Percentage < 0 || *config.Percentage > 100 {
+			addErr("config.percentage", fmt.Errorf("must be between 0 and 100"))
+		}
+		keyVar, err := parseVar(config.KeyName)
+		if err != nil {
+			addErr("config.key", err)
+		}
+
+		matcher = map[string]interface{}{
+			"olaf_bucket": map[string]interface{}{
+				"key":        keyVar,
+				"percentage": *config.Percentage,
+			},
+		}
+	} else {
+		// Use the simple matcher `expression`.
+		keyVar, err := parseVar(config.KeyName)
+		if err != nil {
+			addErr("config.key", err)
 		}
 
-		routes = append(routes, manipulateURI(config.URI, config.Matcher)...)
-		routes = append(routes, reverseProxy(s, config.Matcher))
+		// Do the type conversion if specified.
+		if config.KeyType != "" {
+			keyVar = fmt.Sprintf("%s(%s)", config.KeyType, keyVar)
+		}
+
+		if config.Whitelist == "" {
+			addErr("config.whitelist", fmt.Errorf("empty whitelist"))
+		}
+		expr := strings.ReplaceAll(config.Whitelist, "$", keyVar)
+
+		matcher = map[string]interface{}{
+			"expression": expr,
+		}
+	}
+
+	if s == nil {
+		return nil, errs.err()
+	}
+
+	routes = append(routes, manipulateURI(config.URI, matcher)...)
+	route, err := reverseProxy(s, matcher)
+	errs.merge(err)
+	routes = append(routes, route)
+
+	return routes, errs.err()
+}
+
+func trafficSplitReverseProxy(p *olaf.Plugin, services map[string]*olaf.Service) (routes []map[string]interface{}, err error) {
+	if p == nil || p.Type != olaf.PluginTypeTrafficSplit {
 		return
 	}
 
-	// Use the simple matcher `expression`.
+	var errs Errors
+	addErr := func(field string, err error) {
+		errs.add(olaf.KindPlugin, p.Name, field, err)
+	}
 
-	keyVar, err := parseVar(config.KeyName, p)
-	if err != nil {
-		panic(err)
+	config := new(olaf.PluginTrafficSplitConfig)
+	if err := mapstructure.Decode(p.Config, config); err != nil {
+		addErr("config", fmt.Errorf("cannot be decoded: %v", err))
+		return nil, errs.err()
 	}
 
-	// Do the type conversion if specified.
-	if config.KeyType != "" {
-		keyVar = fmt.Sprintf("%s(%s)", config.KeyType, keyVar)
+	if len(config.Splits) == 0 {
+		addErr("config.splits", fmt.Errorf("no splits"))
+		return nil, errs.err()
 	}
 
-	if config.Whitelist == "" {
-		panic(fmt.Errorf("whitelist of canary plugin %q is empty", p.Name))
+	total := 0
+	for i, split := range config.Splits {
+		field := fmt.Sprintf("config.splits[%d]", i)
+		if split == nil {
+			addErr(field, fmt.Errorf("empty split"))
+			continue
+		}
+		if split.Weight < 0 || split.Weight > 100 {
+			addErr(field+".weight", fmt.Errorf("must be between 0 and 100"))
+			continue
+		}
+		total += split.Weight
+
+		if services[split.ServiceName] == nil {
+			addErr(field+".service", fmt.Errorf("service %q not found", split.ServiceName))
+			continue
+		}
+		if split.Weight == 0 {
+			// Never routed to.
+			continue
+		}
+
+		// The split taking the remaining requests matches all of them.
+		var matcher map[string]interface{}
+		if total < 100 {
+			matcher = splitMatcher(total)
+		}
+		route, err := reverseProxy(services[split.ServiceName], matcher)
+		errs.merge(err)
+		routes = append(routes, route)
 	}
-	expr := strings.ReplaceAll(config.Whitelist, "$", keyVar)
 
-	matcher := map[string]interface{}{
-		"expression": expr,
+	if total != 100 {
+		addErr("config.splits", fmt.Errorf("weights add up to %d, not 100", total))
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
 	}
-	routes = append(routes, manipulateURI(config.URI, matcher)...)
-	routes = append(routes, reverseProxy(s, matcher))
 
-	return
+	return routes, nil
+}
+
+// mirror builds the route of the handler `olaf_mirror` (see caddymodule.Mirror),
+// which sends copies of requests to the upstream service of p.
+func mirror(p *olaf.Plugin, services map[string]*olaf.Service) (route map[string]interface{}, err error) {
+	if p == nil || p.Type != olaf.PluginTypeMirror {
+		return
+	}
+
+	var errs Errors
+	addErr := func(field string, err error) {
+		errs.add(olaf.KindPlugin, p.Name, field, err)
+	}
+
+	config := new(olaf.PluginMirrorConfig)
+	if err := mapstructure.Decode(p.Config, config); err != nil {
+		addErr("config", fmt.Errorf("cannot be decoded: %v", err))
+		return nil, errs.err()
+	}
+
+	handle := map[string]interface{}{
+		"handler": "olaf_mirror",
+	}
+
+	s := services[config.UpstreamServiceName]
+	switch {
+	case s == nil:
+		addErr("config.upstream", fmt.Errorf("service %q not found", config.UpstreamServiceName))
+	case s.Upstream == nil || len(s.Upstream.Backends) == 0:
+		addErr("config.upstream", fmt.Errorf("service %q has no backends", config.UpstreamServiceName))
+	default:
+		var upstreams []string
+		for i, b := range s.Upstream.Backends {
+			upstream, err := buildUpstream(b.Dial, 0)
+			if err != nil {
+				errs.add(olaf.KindService, s.Name, fmt.Sprintf("upstream.backends[%d].dial", i), err)
+				continue
+			}
+			upstreams = append(upstreams, upstream["dial"].(string))
+		}
+		handle["upstreams"] = upstreams
+	}
+
+	if config.Percentage < 0 || config.Percentage > 100 {
+		addErr("config.percentage", fmt.Errorf("must be between 0 and 100"))
+	} else if config.Percentage > 0 {
+		handle["percentage"] = config.Percentage
+	}
+
+	if config.MaxBodySize < 0 {
+		addErr("config.max_body_size", fmt.Errorf("must not be negative"))
+	} else if config.MaxBodySize > 0 {
+		handle["max_body_size"] = config.MaxBodySize
+	}
+
+	if config.Timeout != "" {
+		d, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			addErr("config.timeout", err)
+		}
+		handle["timeout"] = d
+	}
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"handle": []map[string]interface{}{handle},
+	}, nil
+}
+
+// fault builds the route of the handler `olaf_fault` (see caddymodule.Fault),
+// which delays or aborts the matched requests.
+func fault(p *olaf.Plugin) (route map[string]interface{}, err error) {
+	if p == nil || p.Type != olaf.PluginTypeFault {
+		return
+	}
+
+	var errs Errors
+	addErr := func(field string, err error) {
+		errs.add(olaf.KindPlugin, p.Name, field, err)
+	}
+
+	config := new(olaf.PluginFaultConfig)
+	if err := mapstructure.Decode(p.Config, config); err != nil {
+		addErr("config", fmt.Errorf("cannot be decoded: %v", err))
+		return nil, errs.err()
+	}
+
+	handle := map[string]interface{}{
+		"handler": "olaf_fault",
+	}
+	parsePercentage := func(field string, percentage int) {
+		if percentage < 0 || percentage > 100 {
+			addErr("config."+field, fmt.Errorf("must be between 0 and 100"))
+		} else if percentage > 0 {
+			handle[field] = percentage
+		}
+	}
+
+	if config.Delay == "" && config.AbortStatus == 0 {
+		addErr("config", fmt.Errorf("neither delay nor abort_status is specified"))
+	}
+
+	var delay time.Duration
+	if config.Delay != "" {
+		var err error
+		if delay, err = time.ParseDuration(config.Delay); err != nil {
+			addErr("config.delay", err)
+		}
+		handle["delay"] = delay
+	}
+	if config.MaxDelay != "" {
+		maxDelay, err := time.ParseDuration(config.MaxDelay)
+		switch {
+		case err != nil:
+			addErr("config.max_delay", err)
+		case maxDelay < delay:
+			addErr("config.max_delay", fmt.Errorf("must not be less than delay"))
+		}
+		handle["max_delay"] = maxDelay
+	}
+	parsePercentage("delay_percentage", config.DelayPercentage)
+
+	if config.AbortStatus != 0 {
+		if config.AbortStatus < 100 || config.AbortStatus > 599 {
+			addErr("config.abort_status", fmt.Errorf("invalid status code %d", config.AbortStatus))
+		}
+		handle["abort_status"] = config.AbortStatus
+	}
+	parsePercentage("abort_percentage", config.AbortPercentage)
+
+	matcher := make(map[string]interface{})
+	if len(config.Headers) > 0 {
+		matcher["header"] = config.Headers
+	}
+	if len(config.Consumers) > 0 {
+		keyVar, err := parseVar(config.ConsumerKey)
+		if err != nil {
+			addErr("config.consumer_key", err)
+		}
+		var consumers []string
+		for _, c := range config.Consumers {
+			consumers = append(consumers, strconv.Quote(c))
+		}
+		matcher["expression"] = fmt.Sprintf("%s in [%s]", keyVar, strings.Join(consumers, ", "))
+	}
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	return addRouteMatcher(
+		map[string]interface{}{
+			"handle": []map[string]interface{}{handle},
+		},
+		matcher,
+	), nil
+}
+
+// splitMatcher returns the matcher `olaf_random` for the first percent of
+// requests. Since the random bucket is drawn once per request, the matchers
+// of successive splits, with the cumulative percents, are mutually exclusive.
+func splitMatcher(percent int) map[string]interface{} {
+	return map[string]interface{}{
+		"olaf_random": map[string]interface{}{
+			"percentage": percent,
+		},
+	}
 }
 
 // parseVar transforms shorthand variables into Caddy-style placeholders.
@@ -404,10 +953,10 @@ func canaryReverseProxy(p *olaf.Plugin, services map[string]*olaf.Service) (rout
 //     {cookie.<var>}
 //     {body.<var>}
 //
-func parseVar(s string, p *olaf.Plugin) (v string, err error) {
+func parseVar(s string) (v string, err error) {
 	result := reCanaryKeyVar.FindStringSubmatch(s)
 	if len(result) != 3 {
-		return "", fmt.Errorf("invalid key %q for canary plugin %q", s, p.Name)
+		return "", fmt.Errorf("invalid key %q", s)
 	}
 	location, name := result[1], result[2]
 
@@ -423,16 +972,22 @@ func parseVar(s string, p *olaf.Plugin) (v string, err error) {
 	case "body":
 		v = fmt.Sprintf("{http.request.body.%s}", name)
 	default:
-		err = fmt.Errorf("unrecognized key %q for canary plugin %q", s, p.Name)
+		err = fmt.Errorf("unrecognized key %q", s)
 	}
 
 	return
 }
 
-func reverseProxy(s *olaf.Service, matcher map[string]interface{}) map[string]interface{} {
+func reverseProxy(s *olaf.Service, matcher map[string]interface{}) (map[string]interface{}, error) {
+	var errs Errors
+	addErr := func(field string, err error) {
+		errs.add(olaf.KindService, s.Name, field, err)
+	}
+
 	u := s.Upstream
 	if u == nil {
-		panic(fmt.Errorf("service %q has no upstream", s.Name))
+		addErr("upstream", fmt.Errorf("no upstream"))
+		return nil, errs.err()
 	}
 
 	handle := map[string]interface{}{
@@ -440,12 +995,16 @@ func reverseProxy(s *olaf.Service, matcher map[string]interface{}) map[string]in
 	}
 
 	if len(u.Backends) == 0 {
-		panic(fmt.Errorf("service %q has no upstream.backends", s.Name))
+		addErr("upstream.backends", fmt.Errorf("no backends"))
 	}
 
 	var upstreams []map[string]interface{}
-	for _, b := range u.Backends {
-		upstreams = append(upstreams, buildUpstream(b.Dial, b.MaxRequests))
+	for i, b := range u.Backends {
+		upstream, err := buildUpstream(b.Dial, b.MaxRequests)
+		if err != nil {
+			addErr(fmt.Sprintf("upstream.backends[%d].dial", i), err)
+		}
+		upstreams = append(upstreams, upstream)
 	}
 	handle["upstreams"] = upstreams
 
@@ -455,7 +1014,7 @@ func reverseProxy(s *olaf.Service, matcher map[string]interface{}) map[string]in
 			var err error
 			timeout, err = time.ParseDuration(u.HTTP.DialTimeout)
 			if err != nil {
-				panic(fmt.Errorf("failed to parse upstream.dial_timeout of service %q: %v", s.Name, err))
+				addErr("upstream.dial_timeout", err)
 			}
 		}
 		handle["transport"] = map[string]interface{}{
@@ -476,14 +1035,14 @@ func reverseProxy(s *olaf.Service, matcher map[string]interface{}) map[string]in
 		if len(u.LoadBalancing.TryDuration) > 0 {
 			d, err := time.ParseDuration(u.LoadBalancing.TryDuration)
 			if err != nil {
-				panic(fmt.Errorf("failed to parse upstream.lb_try_duration of service %q: %v", s.Name, err))
+				addErr("upstream.lb_try_duration", err)
 			}
 			lb["try_duration"] = d
 		}
 		if len(u.LoadBalancing.Interval) > 0 {
 			d, err := time.ParseDuration(u.LoadBalancing.Interval)
 			if err != nil {
-				panic(fmt.Errorf("failed to parse upstream.lb_try_interval of service %q: %v", s.Name, err))
+				addErr("upstream.lb_try_interval", err)
 			}
 			lb["interval"] = d
 		}
@@ -502,14 +1061,14 @@ func reverseProxy(s *olaf.Service, matcher map[string]interface{}) map[string]in
 		if len(u.ActiveHealthChecks.Interval) > 0 {
 			d, err := time.ParseDuration(u.ActiveHealthChecks.Interval)
 			if err != nil {
-				panic(fmt.Errorf("failed to parse upstream.health_interval of service %q: %v", s.Name, err))
+				addErr("upstream.health_interval", err)
 			}
 			activeHC["interval"] = d
 		}
 		if len(u.ActiveHealthChecks.Timeout) > 0 {
 			d, err := time.ParseDuration(u.ActiveHealthChecks.Timeout)
 			if err != nil {
-				panic(fmt.Errorf("failed to parse upstream.health_timeout of service %q: %v", s.Name, err))
+				addErr("upstream.health_timeout", err)
 			}
 			activeHC["timeout"] = d
 		}
@@ -543,7 +1102,7 @@ func reverseProxy(s *olaf.Service, matcher map[string]interface{}) map[string]in
 		route["match"] = []map[string]interface{}{matcher}
 	}
 
-	return route
+	return route, errs.err()
 }
 
 func manipulateHeader(h *olaf.HeaderOps) map[string]interface{} {
@@ -560,11 +1119,11 @@ func manipulateHeader(h *olaf.HeaderOps) map[string]interface{} {
 	return m
 }
 
-func buildUpstream(url string, maxRequests int) map[string]interface{} {
+func buildUpstream(url string, maxRequests int) (map[string]interface{}, error) {
 	// Validate the conventional format of url.
 	na, err := newNetAddr(url)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	// Special validation logic for TCP addresses to dial.
 	// See https://caddyserver.com/docs/json/apps/http/servers/routes/handle/reverse_proxy/upstreams/dial#docs
@@ -575,7 +1134,7 @@ func buildUpstream(url string, maxRequests int) map[string]interface{} {
 
 		// TCP address to dial can not use port ranges
 		if strings.Contains(port, "-") {
-			panic(fmt.Errorf("invalid TCP address: %q", url))
+			return nil, fmt.Errorf("invalid TCP address: %q", url)
 		}
 	}
 
@@ -587,7 +1146,7 @@ func buildUpstream(url string, maxRequests int) map[string]interface{} {
 		m["max_requests"] = maxRequests
 	}
 
-	return m
+	return m, nil
 }
 
 // TODO: Use caddy.NetworkAddress directly.
@@ -1,6 +1,7 @@
 package builder
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -15,6 +16,7 @@ func TestFindAppliedPlugins(t *testing.T) {
 		inPlugins   map[string]*olaf.Plugin
 		inRoute     *olaf.Route
 		wantPlugins []*olaf.Plugin
+		wantErr     string
 	}{
 		{
 			name: "service's goes before global's",
@@ -146,11 +148,87 @@ func TestFindAppliedPlugins(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "multiple plugins of the same type",
+			inPlugins: map[string]*olaf.Plugin{
+				"global_plugin_1": {
+					Name: "global_plugin_1",
+					Type: "rate_limit",
+				},
+				"service_1_plugin_1": {
+					Name:        "service_1_plugin_1",
+					Type:        "canary",
+					OrderAfter:  "rate_limit",
+					ServiceName: "service_1",
+				},
+				"route_1_plugin_1": {
+					Name:       "route_1_plugin_1",
+					Type:       "canary",
+					OrderAfter: "rate_limit",
+					Order:      2,
+					RouteName:  "route_1",
+				},
+				"route_1_plugin_2": {
+					Name:       "route_1_plugin_2",
+					Type:       "canary",
+					OrderAfter: "canary",
+					Order:      1,
+					RouteName:  "route_1",
+				},
+			},
+			inRoute: &olaf.Route{
+				Name:        "route_1",
+				ServiceName: "service_1",
+			},
+			wantPlugins: []*olaf.Plugin{
+				{
+					Name: "global_plugin_1",
+					Type: "rate_limit",
+				},
+				{
+					Name:       "route_1_plugin_2",
+					Type:       "canary",
+					OrderAfter: "canary",
+					Order:      1,
+					RouteName:  "route_1",
+				},
+				{
+					Name:       "route_1_plugin_1",
+					Type:       "canary",
+					OrderAfter: "rate_limit",
+					Order:      2,
+					RouteName:  "route_1",
+				},
+			},
+		},
+		{
+			name: "multiple plugins of a type that can not be stacked",
+			inPlugins: map[string]*olaf.Plugin{
+				"route_1_plugin_1": {
+					Name:      "route_1_plugin_1",
+					Type:      "traffic_split",
+					RouteName: "route_1",
+				},
+				"route_1_plugin_2": {
+					Name:      "route_1_plugin_2",
+					Type:      "traffic_split",
+					RouteName: "route_1",
+				},
+			},
+			inRoute: &olaf.Route{
+				Name:        "route_1",
+				ServiceName: "service_1",
+			},
+			wantErr: `plugins "route_1_plugin_1" and "route_1_plugin_2" are both of type "traffic_split", which can not be applied more than once`,
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			plugins, _ := findAppliedPlugins(c.inPlugins, c.inRoute)
+			plugins, err := findAppliedPlugins(c.inPlugins, c.inRoute)
+			if (err != nil || c.wantErr != "") && fmt.Sprint(err) != c.wantErr {
+				t.Fatalf("Err: got (%v), want (%s)", err, c.wantErr)
+			}
 			if !reflect.DeepEqual(plugins, c.wantPlugins) {
 				t.Fatalf("Plugins: got (%+v), want (%+v)", plugins, c.wantPlugins)
 			}
@@ -161,17 +239,17 @@ func TestFindAppliedPlugins(t *testing.T) {
 func TestSortPluginsByOrderAfter(t *testing.T) {
 	cases := []struct {
 		name           string
-		inTypedPlugins map[string]*olaf.Plugin
+		inTypedPlugins map[string][]*olaf.Plugin
 		wantPlugins    []*olaf.Plugin
 		wantErrStr     string
 	}{
 		{
 			name: "one plugin",
-			inTypedPlugins: map[string]*olaf.Plugin{
-				"request_body_var": {
+			inTypedPlugins: map[string][]*olaf.Plugin{
+				"request_body_var": {{
 					Name: "plugin_1",
 					Type: "request_body_var",
-				},
+				}},
 			},
 			wantPlugins: []*olaf.Plugin{
 				{
@@ -182,21 +260,21 @@ func TestSortPluginsByOrderAfter(t *testing.T) {
 		},
 		{
 			name: "multiple plugins",
-			inTypedPlugins: map[string]*olaf.Plugin{
-				"request_body_var": {
+			inTypedPlugins: map[string][]*olaf.Plugin{
+				"request_body_var": {{
 					Name: "plugin_1",
 					Type: "request_body_var",
-				},
-				"rate_limit": {
+				}},
+				"rate_limit": {{
 					Name:       "plugin_2",
 					Type:       "rate_limit",
 					OrderAfter: "request_body_var",
-				},
-				"canary": {
+				}},
+				"canary": {{
 					Name:       "plugin_3",
 					Type:       "canary",
 					OrderAfter: "rate_limit",
-				},
+				}},
 			},
 			wantPlugins: []*olaf.Plugin{
 				{
@@ -217,57 +295,82 @@ func TestSortPluginsByOrderAfter(t *testing.T) {
 		},
 		{
 			name: "circular order dependency",
-			inTypedPlugins: map[string]*olaf.Plugin{
-				"request_body_var": {
+			inTypedPlugins: map[string][]*olaf.Plugin{
+				"request_body_var": {{
 					Name:       "plugin_1",
 					Type:       "request_body_var",
 					OrderAfter: "rate_limit",
-				},
-				"rate_limit": {
+				}},
+				"rate_limit": {{
 					Name:       "plugin_2",
 					Type:       "rate_limit",
 					OrderAfter: "request_body_var",
-				},
+				}},
 			},
-			// The error string here is just a prefix, since this test is
-			// not stable due to the randomness of Go map.
-			wantErrStr: "circular order dependency is detected for plugin",
+			wantErrStr: `circular order dependency is detected for plugin "plugin_2" (of type "rate_limit")`,
 		},
 		{
 			name: "plugin type not found",
-			inTypedPlugins: map[string]*olaf.Plugin{
-				"rate_limit": {
+			inTypedPlugins: map[string][]*olaf.Plugin{
+				"rate_limit": {{
 					Name:       "plugin_1",
 					Type:       "rate_limit",
 					OrderAfter: "request_body_var",
-				},
-				"canary": {
+				}},
+				"canary": {{
 					Name:       "plugin_2",
 					Type:       "canary",
 					OrderAfter: "rate_limit",
-				},
+				}},
 			},
 			wantErrStr: `plugin type "request_body_var" (depended by plugin "plugin_1") not found`,
 		},
 		{
 			name: "plugin unordered",
-			inTypedPlugins: map[string]*olaf.Plugin{
-				"request_body_var": {
+			inTypedPlugins: map[string][]*olaf.Plugin{
+				"request_body_var": {{
 					Name: "plugin_1",
 					Type: "request_body_var",
-				},
-				"rate_limit": {
+				}},
+				"rate_limit": {{
 					Name: "plugin_2",
 					Type: "rate_limit",
-				},
-				"canary": {
+				}},
+				"canary": {{
 					Name:       "plugin_3",
 					Type:       "canary",
 					OrderAfter: "rate_limit",
-				},
+				}},
 			},
 			wantErrStr: `plugin "plugin_1" (of type "request_body_var") is unordered`,
 		},
+		{
+			name: "plugins of the same type ordered differently",
+			inTypedPlugins: map[string][]*olaf.Plugin{
+				"request_body_var": {{
+					Name: "plugin_1",
+					Type: "request_body_var",
+				}},
+				"rate_limit": {{
+					Name:       "plugin_2",
+					Type:       "rate_limit",
+					OrderAfter: "request_body_var",
+				}},
+				"canary": {
+					{
+						Name:       "plugin_3",
+						Type:       "canary",
+						OrderAfter: "rate_limit",
+					},
+					{
+						Name:       "plugin_4",
+						Type:       "canary",
+						OrderAfter: "request_body_var",
+					},
+				},
+			},
+			wantErrStr: `plugin "plugin_4" (of type "canary") is ordered differently from plugin "plugin_3"`,
+		},
 	}
 
 	for _, c := range cases {
@@ -370,6 +473,64 @@ func TestPluginCanaryExpression(t *testing.T) {
 				"expression": "int({http.request.body.tid}) > 0 && int({http.request.body.tid}) <= 10",
 			},
 		},
+		{
+			name: "canary per percentage",
+			inPlugin: &olaf.Plugin{
+				Type: olaf.PluginTypeCanary,
+				Config: map[string]interface{}{
+					"upstream":   "staging",
+					"key":        "{cookie.uid}",
+					"percentage": 10,
+				},
+			},
+			inServices: map[string]*olaf.Service{
+				"staging": {
+					Name: "staging",
+					Upstream: &olaf.Upstream{
+						Backends: []*olaf.Backend{
+							{
+								Dial: "localhost:8080",
+							},
+						},
+					},
+				},
+			},
+			wantMatch: map[string]interface{}{
+				"olaf_bucket": map[string]interface{}{
+					"key":        "{http.request.cookie.uid}",
+					"percentage": 10,
+				},
+			},
+		},
+		{
+			name: "canary per zero percentage",
+			inPlugin: &olaf.Plugin{
+				Type: olaf.PluginTypeCanary,
+				Config: map[string]interface{}{
+					"upstream":   "staging",
+					"key":        "{cookie.uid}",
+					"percentage": 0,
+				},
+			},
+			inServices: map[string]*olaf.Service{
+				"staging": {
+					Name: "staging",
+					Upstream: &olaf.Upstream{
+						Backends: []*olaf.Backend{
+							{
+								Dial: "localhost:8080",
+							},
+						},
+					},
+				},
+			},
+			wantMatch: map[string]interface{}{
+				"olaf_bucket": map[string]interface{}{
+					"key":        "{http.request.cookie.uid}",
+					"percentage": 0,
+				},
+			},
+		},
 		{
 			name: "advanced matcher",
 			inPlugin: &olaf.Plugin{
@@ -401,7 +562,10 @@ func TestPluginCanaryExpression(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			routes := canaryReverseProxy(c.inPlugin, c.inServices)
+			routes, err := canaryReverseProxy(c.inPlugin, c.inServices)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
 			matchList := routes[0]["match"].([]map[string]interface{})
 			gotMatch := matchList[0] // Get the first match.
 
@@ -412,6 +576,274 @@ func TestPluginCanaryExpression(t *testing.T) {
 	}
 }
 
+func TestPluginTrafficSplit(t *testing.T) {
+	services := map[string]*olaf.Service{
+		"v1": {Name: "v1", Upstream: &olaf.Upstream{Backends: []*olaf.Backend{{Dial: "localhost:8081"}}}},
+		"v2": {Name: "v2", Upstream: &olaf.Upstream{Backends: []*olaf.Backend{{Dial: "localhost:8082"}}}},
+		"v3": {Name: "v3", Upstream: &olaf.Upstream{Backends: []*olaf.Backend{{Dial: "localhost:8083"}}}},
+	}
+
+	cases := []struct {
+		name        string
+		inSplits    []interface{}
+		wantMatches []interface{}
+		wantErrStr  string
+	}{
+		{
+			name: "three splits",
+			inSplits: []interface{}{
+				map[string]interface{}{"service": "v1", "weight": 50},
+				map[string]interface{}{"service": "v2", "weight": 30},
+				map[string]interface{}{"service": "v3", "weight": 20},
+			},
+			wantMatches: []interface{}{
+				[]map[string]interface{}{{"olaf_random": map[string]interface{}{"percentage": 50}}},
+				[]map[string]interface{}{{"olaf_random": map[string]interface{}{"percentage": 80}}},
+				nil,
+			},
+		},
+		{
+			name: "zero weight",
+			inSplits: []interface{}{
+				map[string]interface{}{"service": "v1", "weight": 100},
+				map[string]interface{}{"service": "v2", "weight": 0},
+			},
+			wantMatches: []interface{}{nil},
+		},
+		{
+			name: "invalid splits",
+			inSplits: []interface{}{
+				map[string]interface{}{"service": "v1", "weight": 50},
+				map[string]interface{}{"service": "v4", "weight": 30},
+				map[string]interface{}{"service": "v2", "weight": -10},
+			},
+			wantErrStr: `invalid config: plugin "split" config.splits[1].service: service "v4" not found; ` +
+				`plugin "split" config.splits[2].weight: must be between 0 and 100; ` +
+				`plugin "split" config.splits: weights add up to 80, not 100`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := &olaf.Plugin{
+				Name:   "split",
+				Type:   olaf.PluginTypeTrafficSplit,
+				Config: map[string]interface{}{"splits": c.inSplits},
+			}
+			routes, err := trafficSplitReverseProxy(p, services)
+			if c.wantErrStr != "" {
+				if err == nil || err.Error() != c.wantErrStr {
+					t.Fatalf("Err: got (%v), want (%s)", err, c.wantErrStr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			var gotMatches []interface{}
+			for _, r := range routes {
+				var match interface{}
+				if m, ok := r["match"]; ok {
+					match = m
+				}
+				gotMatches = append(gotMatches, match)
+			}
+			if !reflect.DeepEqual(gotMatches, c.wantMatches) {
+				t.Fatalf("Matches: got (%#v), want (%#v)", gotMatches, c.wantMatches)
+			}
+		})
+	}
+}
+
+func TestPluginMirror(t *testing.T) {
+	services := map[string]*olaf.Service{
+		"shadow": {
+			Name: "shadow",
+			Upstream: &olaf.Upstream{
+				Backends: []*olaf.Backend{
+					{Dial: "localhost:8081"},
+					{Dial: "unix//run/shadow.sock"},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		inConfig   map[string]interface{}
+		wantRoute  map[string]interface{}
+		wantErrStr string
+	}{
+		{
+			name: "default",
+			inConfig: map[string]interface{}{
+				"upstream": "shadow",
+			},
+			wantRoute: map[string]interface{}{
+				"handle": []map[string]interface{}{
+					{
+						"handler":   "olaf_mirror",
+						"upstreams": []string{"localhost:8081", "unix//run/shadow.sock"},
+					},
+				},
+			},
+		},
+		{
+			name: "sampled",
+			inConfig: map[string]interface{}{
+				"upstream":      "shadow",
+				"percentage":    10,
+				"max_body_size": 4096,
+				"timeout":       "2s",
+			},
+			wantRoute: map[string]interface{}{
+				"handle": []map[string]interface{}{
+					{
+						"handler":       "olaf_mirror",
+						"upstreams":     []string{"localhost:8081", "unix//run/shadow.sock"},
+						"percentage":    10,
+						"max_body_size": int64(4096),
+						"timeout":       2 * time.Second,
+					},
+				},
+			},
+		},
+		{
+			name: "invalid config",
+			inConfig: map[string]interface{}{
+				"upstream":   "nonexistent",
+				"percentage": 101,
+				"timeout":    "2",
+			},
+			wantErrStr: `invalid config: plugin "mirror" config.upstream: service "nonexistent" not found; ` +
+				`plugin "mirror" config.percentage: must be between 0 and 100; ` +
+				`plugin "mirror" config.timeout: time: missing unit in duration "2"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := &olaf.Plugin{
+				Name:   "mirror",
+				Type:   olaf.PluginTypeMirror,
+				Config: c.inConfig,
+			}
+			route, err := mirror(p, services)
+			if c.wantErrStr != "" {
+				if err == nil || err.Error() != c.wantErrStr {
+					t.Fatalf("Err: got (%v), want (%s)", err, c.wantErrStr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(route, c.wantRoute) {
+				t.Fatalf("Route: got (%#v), want (%#v)", route, c.wantRoute)
+			}
+		})
+	}
+}
+
+func TestPluginFault(t *testing.T) {
+	cases := []struct {
+		name       string
+		inConfig   map[string]interface{}
+		wantRoute  map[string]interface{}
+		wantErrStr string
+	}{
+		{
+			name: "fixed delay",
+			inConfig: map[string]interface{}{
+				"delay":   "100ms",
+				"headers": map[string][]string{"X-Fault": {"delay"}},
+			},
+			wantRoute: map[string]interface{}{
+				"match": []map[string]interface{}{
+					{"header": map[string][]string{"X-Fault": {"delay"}}},
+				},
+				"handle": []map[string]interface{}{
+					{
+						"handler": "olaf_fault",
+						"delay":   100 * time.Millisecond,
+					},
+				},
+			},
+		},
+		{
+			name: "random delay and abort per consumer",
+			inConfig: map[string]interface{}{
+				"delay":            "100ms",
+				"max_delay":        "1s",
+				"abort_status":     503,
+				"abort_percentage": 10,
+				"consumer_key":     "{header.X-Consumer-ID}",
+				"consumers":        []string{"alice", "bob"},
+			},
+			wantRoute: map[string]interface{}{
+				"match": []map[string]interface{}{
+					{"expression": `{http.request.header.X-Consumer-ID} in ["alice", "bob"]`},
+				},
+				"handle": []map[string]interface{}{
+					{
+						"handler":          "olaf_fault",
+						"delay":            100 * time.Millisecond,
+						"max_delay":        time.Second,
+						"abort_status":     503,
+						"abort_percentage": 10,
+					},
+				},
+			},
+		},
+		{
+			name: "invalid config",
+			inConfig: map[string]interface{}{
+				"delay":        "1s",
+				"max_delay":    "100ms",
+				"abort_status": 1000,
+				"consumers":    []string{"alice"},
+			},
+			wantErrStr: `invalid config: plugin "fault" config.max_delay: must not be less than delay; ` +
+				`plugin "fault" config.abort_status: invalid status code 1000; ` +
+				`plugin "fault" config.consumer_key: invalid key ""`,
+		},
+		{
+			name:       "no fault",
+			inConfig:   map[string]interface{}{},
+			wantErrStr: `invalid config: plugin "fault" config: neither delay nor abort_status is specified`,
+		},
+		{
+			name:       "max delay only",
+			inConfig:   map[string]interface{}{"max_delay": "1s"},
+			wantErrStr: `invalid config: plugin "fault" config: neither delay nor abort_status is specified`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := &olaf.Plugin{
+				Name:   "fault",
+				Type:   olaf.PluginTypeFault,
+				Config: c.inConfig,
+			}
+			route, err := fault(p)
+			if c.wantErrStr != "" {
+				if err == nil || err.Error() != c.wantErrStr {
+					t.Fatalf("Err: got (%v), want (%s)", err, c.wantErrStr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(route, c.wantRoute) {
+				t.Fatalf("Route: got (%#v), want (%#v)", route, c.wantRoute)
+			}
+		})
+	}
+}
+
 func TestReverseProxy(t *testing.T) {
 	cases := []struct {
 		name      string
@@ -487,10 +919,192 @@ func TestReverseProxy(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			gotRoute := reverseProxy(c.inService, c.inMatcher)
+			gotRoute, err := reverseProxy(c.inService, c.inMatcher)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
 			if !reflect.DeepEqual(gotRoute, c.wantRoute) {
 				t.Fatalf("Route: got (%#v), want (%#v)", gotRoute, c.wantRoute)
 			}
 		})
 	}
 }
+
+func TestBuild_Errors(t *testing.T) {
+	cases := []struct {
+		name    string
+		inData  *olaf.Data
+		wantErr string
+	}{
+		{
+			name: "ok",
+			inData: &olaf.Data{
+				Services: map[string]*olaf.Service{
+					"web": {
+						Name: "web",
+						Upstream: &olaf.Upstream{
+							Backends: []*olaf.Backend{{Dial: "localhost:8080"}},
+						},
+					},
+				},
+				Routes: map[string]*olaf.Route{
+					"foo": {Name: "foo", ServiceName: "web", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+				},
+			},
+		},
+		{
+			name: "all problems",
+			inData: &olaf.Data{
+				Services: map[string]*olaf.Service{
+					"web": {
+						Name: "web",
+						Upstream: &olaf.Upstream{
+							Backends: []*olaf.Backend{
+								{Dial: "localhost:8080"},
+								{Dial: "udp/localhost:8080"},
+							},
+							HTTP: &olaf.TransportHTTP{DialTimeout: "5"},
+						},
+					},
+					"staging": {
+						Name: "staging",
+					},
+				},
+				Routes: map[string]*olaf.Route{
+					"foo": {Name: "foo", ServiceName: "web"},
+					"bar": {Name: "bar", ServiceName: "web"},
+					"baz": {Name: "baz", ServiceName: "nonexistent"},
+				},
+				Plugins: map[string]*olaf.Plugin{
+					"canary": {
+						Name:      "canary",
+						Type:      olaf.PluginTypeCanary,
+						RouteName: "foo",
+						Config: map[string]interface{}{
+							"upstream": "staging",
+							"key":      "tid",
+						},
+					},
+				},
+			},
+			wantErr: `invalid config: ` +
+				`plugin "canary" config.key: invalid key "tid"; ` +
+				`plugin "canary" config.whitelist: empty whitelist; ` +
+				`route "baz" service_name: service "nonexistent" not found; ` +
+				`service "staging" upstream: no upstream; ` +
+				`service "web" upstream.backends[1].dial: unsupported UDP address: "udp/localhost:8080"; ` +
+				`service "web" upstream.dial_timeout: time: missing unit in duration "5"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			routes, err := Build(c.inData)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("err: %v", err)
+				}
+				if len(routes) != len(c.inData.Routes) {
+					t.Fatalf("Routes: got (%d), want (%d)", len(routes), len(c.inData.Routes))
+				}
+				return
+			}
+
+			if _, ok := err.(Errors); !ok {
+				t.Fatalf("Err: got (%#v), want an Errors", err)
+			}
+			if err.Error() != c.wantErr {
+				t.Fatalf("Err: got (%s), want (%s)", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestSortRoutes(t *testing.T) {
+	routes := map[string]*olaf.Route{
+		"b":        {Name: "b", Matcher: olaf.Matcher{Paths: []string{"/api"}}},
+		"a":        {Name: "a", Matcher: olaf.Matcher{Paths: []string{"/api"}}},
+		"users":    {Name: "users", Matcher: olaf.Matcher{Paths: []string{"/api/users/*"}}},
+		"regexp":   {Name: "regexp", Matcher: olaf.Matcher{Paths: []string{"~: /api/users/(\\w+)/orders"}}},
+		"priority": {Name: "priority", Matcher: olaf.Matcher{Paths: []string{"/"}}, Priority: 1},
+		"host":     {Name: "host", Matcher: olaf.Matcher{Hosts: []string{"example.com"}, Paths: []string{"/"}}},
+		"wildcard": {Name: "wildcard", Matcher: olaf.Matcher{Hosts: []string{"*.example.com"}, Paths: []string{"/"}}},
+		"header":   {Name: "header", Matcher: olaf.Matcher{Paths: []string{"/api"}, Headers: map[string][]string{"X-Version": {"2"}}}},
+		"method":   {Name: "method", Matcher: olaf.Matcher{Paths: []string{"/api"}, Methods: []string{"GET"}}},
+	}
+
+	cases := []struct {
+		name   string
+		inAuto bool
+		want   []string
+	}{
+		{
+			name:   "path specificity",
+			inAuto: false,
+			want:   []string{"priority", "users", "a", "b", "header", "method", "host", "wildcard", "regexp"},
+		},
+		{
+			name:   "auto priority",
+			inAuto: true,
+			want:   []string{"priority", "host", "wildcard", "users", "header", "method", "a", "b", "regexp"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Run multiple times to cover the randomness of Go map.
+			for i := 0; i < 10; i++ {
+				var got []string
+				for _, r := range sortRoutes(routes, c.inAuto) {
+					got = append(got, r.Name)
+				}
+				if !reflect.DeepEqual(got, c.want) {
+					t.Fatalf("Routes: got (%v), want (%v)", got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuild_Deterministic(t *testing.T) {
+	data := &olaf.Data{
+		Services: map[string]*olaf.Service{
+			"web": {
+				Name: "web",
+				Upstream: &olaf.Upstream{
+					Backends: []*olaf.Backend{{Dial: "localhost:8080"}},
+				},
+			},
+		},
+		Routes: map[string]*olaf.Route{
+			"foo": {Name: "foo", ServiceName: "web", Matcher: olaf.Matcher{Paths: []string{"/foo"}}},
+			"bar": {Name: "bar", ServiceName: "web", Matcher: olaf.Matcher{Paths: []string{"/bar"}}},
+			"regexp": {Name: "regexp", ServiceName: "web", Matcher: olaf.Matcher{Paths: []string{
+				"~a: /a/(\\w+)",
+				"~b: /b/(\\w+)",
+				"~c: /c/(\\w+)",
+			}}},
+		},
+		Plugins: map[string]*olaf.Plugin{
+			"limit": {Name: "limit", Type: "rate_limit"},
+			"body":  {Name: "body", Type: "request_body_var", OrderAfter: "rate_limit"},
+		},
+	}
+
+	var wantHash string
+	for i := 0; i < 10; i++ {
+		routes, err := Build(data)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		hash, err := Hash(routes)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if i == 0 {
+			wantHash = hash
+		} else if hash != wantHash {
+			t.Fatalf("Hash: got (%s), want (%s)", hash, wantHash)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package builder
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RussellLuo/olaf"
+)
+
+// Conflict reports a route that can never match, since all the requests it
+// matches will be matched by an earlier route.
+type Conflict struct {
+	Route string `json:"route" yaml:"route"`
+	// The earlier route, which shadows Route.
+	ShadowedBy string `json:"shadowed_by" yaml:"shadowed_by"`
+	// Whether the two routes have the same matcher.
+	Duplicate bool `json:"duplicate" yaml:"duplicate"`
+}
+
+func (c *Conflict) String() string {
+	if c.Duplicate {
+		return fmt.Sprintf("route %q duplicates route %q", c.Route, c.ShadowedBy)
+	}
+	return fmt.Sprintf("route %q is shadowed by route %q", c.Route, c.ShadowedBy)
+}
+
+// FindConflicts finds the routes in data, which are shadowed by earlier ones
+// in the order of Build (with the given options). The result is in the same
+// order, and each route is reported at most once, along with the first route
+// shadowing it.
+//
+// The analysis is conservative: a regexp path is only known to be covered by
+// a catch-all path, or by an earlier regexp path of the same pattern.
+func FindConflicts(data *olaf.Data, opts Options) (conflicts []*Conflict) {
+	routes := sortRoutes(data.Routes, opts.AutoPriority)
+	for j, b := range routes {
+		for _, a := range routes[:j] {
+			if !coversMatcher(a.Matcher, b.Matcher) {
+				continue
+			}
+			conflicts = append(conflicts, &Conflict{
+				Route:      b.Name,
+				ShadowedBy: a.Name,
+				Duplicate:  coversMatcher(b.Matcher, a.Matcher),
+			})
+			break
+		}
+	}
+	return
+}
+
+// coversMatcher reports whether all the requests matched by b are also
+// matched by a.
+func coversMatcher(a, b olaf.Matcher) bool {
+	return (a.Protocol == "" || a.Protocol == b.Protocol) &&
+		coversValues(a.Methods, b.Methods, strings.EqualFold) &&
+		coversValues(a.Hosts, b.Hosts, coversHost) &&
+		coversHeaders(a.Headers, b.Headers) &&
+		coversValues(a.Paths, b.Paths, coversPath)
+}
+
+// coversValues reports whether the values a, which match anything if empty,
+// cover the values b, i.e. each of b is covered by any of a.
+func coversValues(a, b []string, covers func(a, b string) bool) bool {
+	if len(a) == 0 {
+		return true
+	}
+	if len(b) == 0 {
+		return false
+	}
+
+NextB:
+	for _, vb := range b {
+		for _, va := range a {
+			if covers(va, vb) {
+				continue NextB
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// coversHost reports whether the host a covers the host b. A wildcard host,
+// like "*.example.com", matches exactly one label.
+func coversHost(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	if !strings.HasPrefix(a, "*.") || strings.Contains(b, "*") {
+		return false
+	}
+	i := strings.Index(b, ".")
+	return i > 0 && strings.EqualFold(b[i:], a[1:])
+}
+
+// coversPath reports whether the path a covers the path b. Both of them may
+// be regexp paths.
+func coversPath(a, b string) bool {
+	ra := reRegexpPath.FindStringSubmatch(a)
+	rb := reRegexpPath.FindStringSubmatch(b)
+	switch {
+	case ra != nil:
+		return rb != nil && ra[2] == rb[2]
+	case a == "*" || a == "/*":
+		// All request paths start with "/".
+		return true
+	case rb != nil:
+		return false
+	case a == b:
+		return true
+	}
+
+	// Only prefix paths, like "/api/*", can cover other paths.
+	if !strings.HasSuffix(a, "*") || strings.Count(a, "*") > 1 {
+		return false
+	}
+	prefix := strings.TrimSuffix(a, "*")
+	path := strings.TrimSuffix(b, "*")
+	return !strings.Contains(path, "*") && strings.HasPrefix(path, prefix)
+}
+
+// coversHeaders reports whether the header matcher a covers the header
+// matcher b, i.e. each field required by a is also required by b, with
+// the values covered.
+func coversHeaders(a, b map[string][]string) bool {
+	fields := make(map[string][]string, len(b))
+	for k, v := range b {
+		fields[http.CanonicalHeaderKey(k)] = v
+	}
+
+	for k, va := range a {
+		vb, ok := fields[http.CanonicalHeaderKey(k)]
+		if !ok {
+			return false
+		}
+		if !coversValues(va, vb, coversHeaderValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func coversHeaderValue(a, b string) bool {
+	return a == "*" || a == b
+}
@@ -0,0 +1,119 @@
+package builder
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+func TestFindConflicts(t *testing.T) {
+	route := func(name string, priority float64, m olaf.Matcher) *olaf.Route {
+		return &olaf.Route{Name: name, Priority: priority, Matcher: m}
+	}
+
+	cases := []struct {
+		name          string
+		inRoutes      []*olaf.Route
+		inOpts        Options
+		wantConflicts []*Conflict
+	}{
+		{
+			name: "no conflicts",
+			inRoutes: []*olaf.Route{
+				route("users", 0, olaf.Matcher{Paths: []string{"/api/users/*"}}),
+				route("orders", 0, olaf.Matcher{Paths: []string{"/api/orders/*"}}),
+				route("not_found", math.Inf(-1), olaf.Matcher{Paths: []string{"/*"}}),
+			},
+		},
+		{
+			name: "catch-all with the sign wrong",
+			inRoutes: []*olaf.Route{
+				route("users", 0, olaf.Matcher{Paths: []string{"/api/users/*"}}),
+				route("regexp", 0, olaf.Matcher{Paths: []string{"~: /api/orders/(\\d+)"}}),
+				route("not_found", math.Inf(1), olaf.Matcher{Paths: []string{"/*"}}),
+			},
+			wantConflicts: []*Conflict{
+				{Route: "users", ShadowedBy: "not_found"},
+				{Route: "regexp", ShadowedBy: "not_found"},
+			},
+		},
+		{
+			name: "duplicates",
+			inRoutes: []*olaf.Route{
+				route("a", 0, olaf.Matcher{Methods: []string{"GET"}, Paths: []string{"/foo", "/bar"}}),
+				route("b", 0, olaf.Matcher{Methods: []string{"get"}, Paths: []string{"/bar", "/foo"}}),
+			},
+			wantConflicts: []*Conflict{
+				{Route: "b", ShadowedBy: "a", Duplicate: true},
+			},
+		},
+		{
+			name: "narrower matchers",
+			inRoutes: []*olaf.Route{
+				route("api", 1, olaf.Matcher{Hosts: []string{"*.example.com"}, Paths: []string{"/api/*"}}),
+				route("host", 0, olaf.Matcher{Hosts: []string{"api.example.com"}, Paths: []string{"/api/users"}}),
+				route("method", 0, olaf.Matcher{Hosts: []string{"www.example.com"}, Methods: []string{"GET"}, Paths: []string{"/api/orders/*"}}),
+				route("header", 0, olaf.Matcher{Hosts: []string{"www.example.com"}, Paths: []string{"/api"}, Headers: map[string][]string{"X-Version": {"2"}}}),
+			},
+			wantConflicts: []*Conflict{
+				{Route: "method", ShadowedBy: "api"},
+				{Route: "host", ShadowedBy: "api"},
+			},
+		},
+		{
+			name: "wider matchers",
+			inRoutes: []*olaf.Route{
+				route("api", 1, olaf.Matcher{Hosts: []string{"*.example.com"}, Methods: []string{"GET"}, Paths: []string{"/api/*"}}),
+				route("any_host", 0, olaf.Matcher{Paths: []string{"/api/a"}}),
+				route("deep_host", 0, olaf.Matcher{Hosts: []string{"a.b.example.com"}, Paths: []string{"/api/b"}}),
+				route("any_method", 0, olaf.Matcher{Hosts: []string{"a.example.com"}, Paths: []string{"/api/c"}}),
+				route("other_path", 0, olaf.Matcher{Hosts: []string{"a.example.com"}, Methods: []string{"GET"}, Paths: []string{"/api", "/api/d"}}),
+			},
+		},
+		{
+			name: "headers",
+			inRoutes: []*olaf.Route{
+				route("v2", 1, olaf.Matcher{Paths: []string{"/api"}, Headers: map[string][]string{"X-Version": {"2"}}}),
+				route("v2_beta", 0, olaf.Matcher{Paths: []string{"/api"}, Headers: map[string][]string{"x-version": {"2"}, "X-Beta": {"1"}}}),
+				route("v3", 0, olaf.Matcher{Paths: []string{"/api"}, Headers: map[string][]string{"X-Version": {"2", "3"}}}),
+			},
+			wantConflicts: []*Conflict{
+				{Route: "v2_beta", ShadowedBy: "v2"},
+			},
+		},
+		{
+			name: "resolved by auto priority",
+			inRoutes: []*olaf.Route{
+				route("api", 0, olaf.Matcher{Paths: []string{"/api/*"}}),
+				route("users", 0, olaf.Matcher{Hosts: []string{"example.com"}, Paths: []string{"/api/"}}),
+			},
+			inOpts: Options{AutoPriority: true},
+		},
+		{
+			name: "not resolved without auto priority",
+			inRoutes: []*olaf.Route{
+				route("api", 0, olaf.Matcher{Paths: []string{"/api/*"}}),
+				route("users", 0, olaf.Matcher{Hosts: []string{"example.com"}, Paths: []string{"/api/"}}),
+			},
+			wantConflicts: []*Conflict{
+				{Route: "users", ShadowedBy: "api"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := &olaf.Data{Routes: make(map[string]*olaf.Route)}
+			for _, r := range c.inRoutes {
+				data.Routes[r.Name] = r
+			}
+
+			conflicts := FindConflicts(data, c.inOpts)
+			if !reflect.DeepEqual(conflicts, c.wantConflicts) {
+				t.Fatalf("Conflicts: got (%v), want (%v)", conflicts, c.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// evalWhitelist evaluates the whitelist expression of a canary plugin, where
+// `$` denotes the value of the key. Only a subset of CEL, which is enough for
+// the usual whitelists, is supported:
+//
+//	literals:    123, "abc", true, false, [1, 2, 3]
+//	operators:   ==, !=, <, <=, >, >=, in, &&, ||, !, (...)
+//	methods:     startsWith, endsWith, contains, matches, size
+func evalWhitelist(expr string, value interface{}) (bool, error) {
+	p := &exprParser{value: value}
+	if err := p.tokenize(expr); err != nil {
+		return false, err
+	}
+
+	v, err := p.parseOr()
+	if err != nil {
+		return false, err
+	}
+	if p.pos < len(p.tokens) {
+		return false, fmt.Errorf("unexpected %q in whitelist", p.tokens[p.pos])
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("whitelist is not a boolean expression")
+	}
+	return b, nil
+}
+
+type exprParser struct {
+	value  interface{}
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) tokenize(s string) error {
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && rune(s[j]) != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string in whitelist")
+			}
+			p.tokens = append(p.tokens, s[i:j+1])
+			i = j + 1
+		case unicode.IsDigit(c) || c == '-' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1])):
+			j := i + 1
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, s[i:j])
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_') {
+				j++
+			}
+			p.tokens = append(p.tokens, s[i:j])
+			i = j
+		default:
+			if i+1 < len(s) {
+				if op := s[i : i+2]; op == "==" || op == "!=" || op == "<=" || op == ">=" || op == "&&" || op == "||" {
+					p.tokens = append(p.tokens, op)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("$<>!()[],.", c) {
+				return fmt.Errorf("unexpected %q in whitelist", c)
+			}
+			p.tokens = append(p.tokens, string(c))
+			i++
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) expect(tok string) error {
+	if p.peek() != tok {
+		return fmt.Errorf("expected %q in whitelist", tok)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) parseOr() (interface{}, error) {
+	return p.parseBinary("||", p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (interface{}, error) {
+	return p.parseBinary("&&", p.parseUnary)
+}
+
+// parseBinary parses the logical operations of op, whose operands are parsed
+// by next. Note that both operands are always evaluated.
+func (p *exprParser) parseBinary(op string, next func() (interface{}, error)) (interface{}, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == op {
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		l, ok1 := left.(bool)
+		r, ok2 := right.(bool)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("operands of %q must be booleans", op)
+		}
+		if op == "&&" {
+			left = l && r
+		} else {
+			left = l || r
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (interface{}, error) {
+	if p.peek() == "!" {
+		p.pos++
+		v, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operand of \"!\" must be a boolean")
+		}
+		return !b, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (interface{}, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "in":
+	default:
+		return left, nil
+	}
+	p.pos++
+
+	right, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	return compare(op, left, right)
+}
+
+func (p *exprParser) parsePostfix() (interface{}, error) {
+	v, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "." {
+		p.pos++
+		method := p.peek()
+		p.pos++
+		args, err := p.parseArgs("(", ")")
+		if err != nil {
+			return nil, err
+		}
+		if v, err = call(method, v, args); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (p *exprParser) parseArgs(open, close string) (args []interface{}, err error) {
+	if err := p.expect(open); err != nil {
+		return nil, err
+	}
+	for p.peek() != close {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.pos++
+	return args, nil
+}
+
+func (p *exprParser) parsePrimary() (interface{}, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of whitelist")
+	case tok == "$":
+		p.pos++
+		return p.value, nil
+	case tok == "(":
+		p.pos++
+		v, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return v, p.expect(")")
+	case tok == "[":
+		args, err := p.parseArgs("[", "]")
+		return args, err
+	case tok == "true" || tok == "false":
+		p.pos++
+		return tok == "true", nil
+	case tok[0] == '"' || tok[0] == '\'':
+		p.pos++
+		s, err := strconv.Unquote(`"` + strings.ReplaceAll(tok[1:len(tok)-1], `"`, `\"`) + `"`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s in whitelist", tok)
+		}
+		return s, nil
+	case unicode.IsDigit(rune(tok[0])) || tok[0] == '-':
+		p.pos++
+		if i, err := strconv.ParseInt(tok, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s in whitelist", tok)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("unexpected %q in whitelist", tok)
+}
+
+func compare(op string, left, right interface{}) (interface{}, error) {
+	if op == "in" {
+		list, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("right operand of \"in\" must be a list")
+		}
+		for _, v := range list {
+			if eq, err := compare("==", left, v); err == nil && eq.(bool) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	var c int
+	switch l := left.(type) {
+	case int64:
+		r, ok := right.(int64)
+		if !ok {
+			return nil, fmt.Errorf("can not compare %v with %v", left, right)
+		}
+		c = compareInt(l, r)
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("can not compare %v with %v", left, right)
+		}
+		c = compareFloat(l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("can not compare %q with %v", left, right)
+		}
+		c = strings.Compare(l, r)
+	case bool:
+		r, ok := right.(bool)
+		if !ok || (op != "==" && op != "!=") {
+			return nil, fmt.Errorf("can not compare %v with %v", left, right)
+		}
+		if l != r {
+			c = 1
+		}
+	default:
+		return nil, fmt.Errorf("can not compare %v with %v", left, right)
+	}
+
+	switch op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default: // ">="
+		return c >= 0, nil
+	}
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func call(method string, v interface{}, args []interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("method %q is not supported on %v", method, v)
+	}
+
+	if method == "size" {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("method %q takes no argument", method)
+		}
+		return int64(len(s)), nil
+	}
+
+	if len(args) != 1 {
+		return nil, fmt.Errorf("method %q takes one argument", method)
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument of method %q must be a string", method)
+	}
+
+	switch method {
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "matches":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("method %q is not supported", method)
+}
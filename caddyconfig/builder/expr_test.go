@@ -0,0 +1,76 @@
+package builder
+
+import (
+	"testing"
+)
+
+func TestEvalWhitelist(t *testing.T) {
+	cases := []struct {
+		name    string
+		inExpr  string
+		inValue interface{}
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "range",
+			inExpr:  "$ > 0 && $ <= 10",
+			inValue: int64(10),
+			want:    true,
+		},
+		{
+			name:    "out of range",
+			inExpr:  "$ > 0 && $ <= 10",
+			inValue: int64(11),
+			want:    false,
+		},
+		{
+			name:    "method",
+			inExpr:  `$.startsWith("tid")`,
+			inValue: "tid_1",
+			want:    true,
+		},
+		{
+			name:    "in list",
+			inExpr:  `$ in ["a", 'b'] || !($.size() > 1)`,
+			inValue: "b",
+			want:    true,
+		},
+		{
+			name:    "regexp",
+			inExpr:  `$.matches("^[0-9]+$")`,
+			inValue: "tid",
+			want:    false,
+		},
+		{
+			name:    "type mismatch",
+			inExpr:  `$ > "1"`,
+			inValue: int64(1),
+			wantErr: true,
+		},
+		{
+			name:    "not a boolean",
+			inExpr:  `$`,
+			inValue: "1",
+			wantErr: true,
+		},
+		{
+			name:    "syntax error",
+			inExpr:  `$ == (1`,
+			inValue: int64(1),
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := evalWhitelist(c.inExpr, c.inValue)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("Err: got (%v), want error (%v)", err, c.wantErr)
+			}
+			if got != c.want {
+				t.Fatalf("Result: got (%v), want (%v)", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package builder
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Request is a synthetic request to be routed by Simulate.
+type Request struct {
+	// The request protocol, "http" (the default) or "https".
+	Protocol string              `json:"protocol,omitempty" yaml:"protocol"`
+	Method   string              `json:"method" yaml:"method"`
+	Host     string              `json:"host" yaml:"host"`
+	Path     string              `json:"path" yaml:"path"`
+	Headers  map[string][]string `json:"headers,omitempty" yaml:"headers"`
+	Query    map[string][]string `json:"query,omitempty" yaml:"query"`
+	Cookies  map[string]string   `json:"cookies,omitempty" yaml:"cookies"`
+	// The JSON body, which is only used by the canary keys like `{body.<var>}`.
+	Body map[string]interface{} `json:"body,omitempty" yaml:"body"`
+}
+
+// Simulation is the result of routing a request by Simulate.
+type Simulation struct {
+	// Whether any route matches the request.
+	Matched bool `json:"matched" yaml:"matched"`
+	// The name of the matched route.
+	Route string `json:"route,omitempty" yaml:"route"`
+	// The names of the plugins applied to the route, in the order they run.
+	Plugins []string `json:"plugins,omitempty" yaml:"plugins"`
+	// The name of the last canary plugin evaluated, if any, and whether the
+	// request matches it, i.e. is proxied to the upstream service of the
+	// canary. Canaries of the same route are evaluated in order, until one
+	// matches.
+	Canary        string `json:"canary,omitempty" yaml:"canary"`
+	CanaryMatched bool   `json:"canary_matched" yaml:"canary_matched"`
+	// The name of the applied traffic_split plugin, if reached, and its
+	// splits, one of which the request is proxied to at random.
+	TrafficSplit string               `json:"traffic_split,omitempty" yaml:"traffic_split"`
+	Splits       []*olaf.TrafficSplit `json:"splits,omitempty" yaml:"splits"`
+	// The names of the applied fault plugins, which may delay or abort the
+	// request (i.e. the ones whose headers and consumers match the request).
+	Faults []string `json:"faults,omitempty" yaml:"faults"`
+	// The services receiving the copies of the request, if sampled, from the
+	// applied mirror plugins.
+	Mirrors []string `json:"mirrors,omitempty" yaml:"mirrors"`
+	// The service which the request is proxied to, if determined, and the
+	// path after all the URI manipulations.
+	Service string `json:"service,omitempty" yaml:"service"`
+	Path    string `json:"path,omitempty" yaml:"path"`
+	// The static response, if the matched route is a static one.
+	Response *olaf.StaticResponse `json:"response,omitempty" yaml:"response"`
+}
+
+// Simulate finds the route in data, which handles req, by evaluating the
+// matchers in the same order as Build (with the given options), and reports
+// what will happen to req.
+func Simulate(data *olaf.Data, req *Request, opts Options) (*Simulation, error) {
+	r := *req
+	if r.Protocol == "" {
+		r.Protocol = "http"
+	}
+	if r.Path == "" {
+		r.Path = "/"
+	}
+
+	for _, route := range sortRoutes(data.Routes, opts.AutoPriority) {
+		if !matchAny(buildRouteMatches(route.Matcher), &r) {
+			continue
+		}
+		return simulateRoute(data, route, &r)
+	}
+	return &Simulation{}, nil
+}
+
+func simulateRoute(data *olaf.Data, route *olaf.Route, r *Request) (*Simulation, error) {
+	sim := &Simulation{
+		Matched: true,
+		Route:   route.Name,
+		Service: route.ServiceName,
+	}
+
+	if route.Response != nil {
+		// Like buildSubRoutes, any other PROXY-related attributes are ignored.
+		sim.Service = ""
+		sim.Response = route.Response
+		return sim, nil
+	}
+
+	r.Path = rewritePath(route.URI, r.Path)
+
+	plugins, err := findAppliedPlugins(data.Plugins, route)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		sim.Plugins = append(sim.Plugins, p.Name)
+		if sim.CanaryMatched || sim.TrafficSplit != "" {
+			// The request has been proxied by a previous plugin.
+			continue
+		}
+		if p.Type == olaf.PluginTypeFault {
+			config := new(olaf.PluginFaultConfig)
+			if err := mapstructure.Decode(p.Config, config); err != nil {
+				return nil, fmt.Errorf("config of plugin %q cannot be decoded: %v", p.Name, err)
+			}
+			matched, err := matchFault(config, r)
+			if err != nil {
+				return nil, fmt.Errorf("plugin %q: %v", p.Name, err)
+			}
+			if matched {
+				sim.Faults = append(sim.Faults, p.Name)
+			}
+			continue
+		}
+		if p.Type == olaf.PluginTypeMirror {
+			sim.Mirrors = append(sim.Mirrors, olaf.MirrorUpstream(p))
+			continue
+		}
+		if p.Type == olaf.PluginTypeTrafficSplit {
+			config := new(olaf.PluginTrafficSplitConfig)
+			if err := mapstructure.Decode(p.Config, config); err != nil {
+				return nil, fmt.Errorf("config of plugin %q cannot be decoded: %v", p.Name, err)
+			}
+			sim.TrafficSplit = p.Name
+			sim.Service = ""
+			for _, split := range config.Splits {
+				if split != nil && split.Weight > 0 {
+					sim.Splits = append(sim.Splits, split)
+				}
+			}
+			continue
+		}
+		if p.Type != olaf.PluginTypeCanary {
+			continue
+		}
+
+		sim.Canary = p.Name
+		config := new(olaf.PluginCanaryConfig)
+		if err := mapstructure.Decode(p.Config, config); err != nil {
+			return nil, fmt.Errorf("config of plugin %q cannot be decoded: %v", p.Name, err)
+		}
+		matched, err := matchCanary(config, r)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %q: %v", p.Name, err)
+		}
+		if matched {
+			// The URI manipulations of the canary follow the ones of the route.
+			sim.CanaryMatched = true
+			sim.Service = config.UpstreamServiceName
+			r.Path = rewritePath(config.URI, r.Path)
+		}
+	}
+
+	sim.Path = r.Path
+	return sim, nil
+}
+
+// matchCanary reports whether r matches the canary config. Note that the
+// path of r has been rewritten per the route.
+func matchCanary(config *olaf.PluginCanaryConfig, r *Request) (bool, error) {
+	if len(config.Matcher) > 0 {
+		return matchAll(config.Matcher, r)
+	}
+
+	s, err := parseVar(config.KeyName)
+	if err != nil {
+		return false, err
+	}
+	value := interface{}(placeholder(s, r))
+
+	if config.Percentage != nil {
+		return olaf.InBucket(value.(string), *config.Percentage), nil
+	}
+
+	switch config.KeyType {
+	case "", "string":
+	case "int":
+		i, err := strconv.ParseInt(value.(string), 10, 64)
+		if err != nil {
+			// The conversion fails, so does the matcher.
+			return false, nil
+		}
+		value = i
+	default:
+		return false, fmt.Errorf("unsupported type %q", config.KeyType)
+	}
+
+	return evalWhitelist(config.Whitelist, value)
+}
+
+// matchFault reports whether r is in the scope of the fault config.
+func matchFault(config *olaf.PluginFaultConfig, r *Request) (bool, error) {
+	if !matchFields(config.Headers, func(k string) []string { return header(r.Headers, k) }) {
+		return false, nil
+	}
+	if len(config.Consumers) == 0 {
+		return true, nil
+	}
+
+	s, err := parseVar(config.ConsumerKey)
+	if err != nil {
+		return false, err
+	}
+	consumer := placeholder(s, r)
+	for _, c := range config.Consumers {
+		if c == consumer {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// placeholder returns the value of the Caddy placeholder s, which is one of
+// those generated by parseVar, for r.
+func placeholder(s string, r *Request) string {
+	name := strings.TrimSuffix(s, "}")
+	switch {
+	case strings.HasPrefix(name, "{http.request.uri.path."):
+		i, err := strconv.Atoi(strings.TrimPrefix(name, "{http.request.uri.path."))
+		segments := strings.Split(strings.TrimPrefix(r.Path, "/"), "/")
+		if err != nil || i < 0 || i >= len(segments) {
+			return ""
+		}
+		return segments[i]
+	case strings.HasPrefix(name, "{http.request.uri.query."):
+		if v := r.Query[strings.TrimPrefix(name, "{http.request.uri.query.")]; len(v) > 0 {
+			return v[0]
+		}
+	case strings.HasPrefix(name, "{http.request.header."):
+		return strings.Join(header(r.Headers, strings.TrimPrefix(name, "{http.request.header.")), ",")
+	case strings.HasPrefix(name, "{http.request.cookie."):
+		return r.Cookies[strings.TrimPrefix(name, "{http.request.cookie.")]
+	case strings.HasPrefix(name, "{http.request.body."):
+		if v, ok := r.Body[strings.TrimPrefix(name, "{http.request.body.")]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// rewritePath applies the URI manipulations, as done by manipulateURI, to p.
+func rewritePath(uri olaf.URI, p string) string {
+	if uri.StripPrefix != "" {
+		prefix := uri.StripPrefix
+		if !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+		p = strings.TrimPrefix(p, prefix)
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+	}
+	if uri.StripSuffix != "" {
+		p = strings.TrimSuffix(p, uri.StripSuffix)
+	}
+
+	targetPath := uri.TargetPath
+	if targetPath == "" && uri.AddPrefix != "" {
+		targetPath = uri.AddPrefix + "$"
+	}
+	if targetPath != "" {
+		p = strings.Replace(targetPath, "$", p, 1)
+	}
+	return p
+}
+
+// matchAny reports whether r matches any of the Caddy matcher sets. Like
+// Caddy, no matcher sets match all requests.
+func matchAny(sets []map[string]interface{}, r *Request) bool {
+	if len(sets) == 0 {
+		return true
+	}
+	for _, set := range sets {
+		// The matchers built by buildRouteMatches are always supported.
+		if ok, _ := matchAll(set, r); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAll reports whether r matches all the Caddy matchers in set.
+func matchAll(set map[string]interface{}, r *Request) (bool, error) {
+	for name, m := range set {
+		var ok bool
+		switch name {
+		case "protocol":
+			var protocol string
+			if err := mapstructure.Decode(m, &protocol); err != nil {
+				return false, err
+			}
+			ok = strings.EqualFold(protocol, r.Protocol)
+		case "method":
+			var methods []string
+			if err := mapstructure.Decode(m, &methods); err != nil {
+				return false, err
+			}
+			ok = matchValues(methods, strings.ToUpper(r.Method), func(a, b string) bool { return a == b })
+		case "host":
+			var hosts []string
+			if err := mapstructure.Decode(m, &hosts); err != nil {
+				return false, err
+			}
+			ok = matchValues(hosts, hostname(r.Host), matchHost)
+		case "path":
+			var paths []string
+			if err := mapstructure.Decode(m, &paths); err != nil {
+				return false, err
+			}
+			ok = matchValues(paths, strings.ToLower(r.Path), func(a, b string) bool {
+				return matchWildcard(strings.ToLower(a), b)
+			})
+		case "path_regexp":
+			var re struct {
+				Pattern string `mapstructure:"pattern"`
+			}
+			if err := mapstructure.Decode(m, &re); err != nil {
+				return false, err
+			}
+			matched, err := regexp.MatchString(re.Pattern, r.Path)
+			if err != nil {
+				return false, err
+			}
+			ok = matched
+		case "header":
+			var fields map[string][]string
+			if err := mapstructure.Decode(m, &fields); err != nil {
+				return false, err
+			}
+			ok = matchFields(fields, func(k string) []string { return header(r.Headers, k) })
+		case "query":
+			var fields map[string][]string
+			if err := mapstructure.Decode(m, &fields); err != nil {
+				return false, err
+			}
+			ok = matchFields(fields, func(k string) []string { return r.Query[k] })
+		case "olaf_bucket":
+			var bucket struct {
+				Key        string `mapstructure:"key"`
+				Percentage int    `mapstructure:"percentage"`
+			}
+			if err := mapstructure.Decode(m, &bucket); err != nil {
+				return false, err
+			}
+			ok = olaf.InBucket(placeholder(bucket.Key, r), bucket.Percentage)
+		default:
+			return false, fmt.Errorf("unsupported matcher %q", name)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func matchValues(patterns []string, v string, match func(pattern, v string) bool) bool {
+	for _, p := range patterns {
+		if match(p, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFields reports whether each of the fields has any of its values
+// matched. A field without values only needs to be present.
+func matchFields(fields map[string][]string, get func(k string) []string) bool {
+	for k, patterns := range fields {
+		values := get(k)
+		if len(values) == 0 {
+			return false
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+		matched := false
+		for _, v := range values {
+			if matchValues(patterns, v, matchWildcard) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchWildcard matches v against pattern like Caddy, which supports the
+// prefix (`foo*`), suffix (`*foo`) and substring (`*foo*`) wildcards, as
+// well as the glob patterns (see path.Match).
+func matchWildcard(pattern, v string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case !strings.Contains(pattern, "*"):
+		return pattern == v
+	case len(pattern) > 2 && strings.HasPrefix(pattern, "*") && strings.HasSuffix(pattern, "*"):
+		return strings.Contains(v, pattern[1:len(pattern)-1])
+	case strings.HasPrefix(pattern, "*") && strings.Count(pattern, "*") == 1:
+		return strings.HasSuffix(v, pattern[1:])
+	case strings.HasSuffix(pattern, "*") && strings.Count(pattern, "*") == 1:
+		return strings.HasPrefix(v, pattern[:len(pattern)-1])
+	}
+	matched, _ := path.Match(pattern, v)
+	return matched
+}
+
+func matchHost(pattern, host string) bool {
+	if strings.EqualFold(pattern, host) {
+		return true
+	}
+	return strings.HasPrefix(pattern, "*.") && coversHost(pattern, host)
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+func header(h map[string][]string, k string) []string {
+	for name, values := range h {
+		if http.CanonicalHeaderKey(name) == http.CanonicalHeaderKey(k) {
+			return values
+		}
+	}
+	return nil
+}
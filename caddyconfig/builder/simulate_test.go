@@ -0,0 +1,311 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+func TestSimulate(t *testing.T) {
+	data := &olaf.Data{
+		Services: map[string]*olaf.Service{
+			"web":      {Name: "web"},
+			"staging":  {Name: "staging"},
+			"internal": {Name: "internal"},
+		},
+		Routes: map[string]*olaf.Route{
+			"api": {
+				Name:        "api",
+				ServiceName: "web",
+				Matcher:     olaf.Matcher{Paths: []string{"/api/*"}},
+				URI:         olaf.URI{StripPrefix: "/api", TargetPath: "/v1$"},
+			},
+			"users": {
+				Name:        "users",
+				ServiceName: "web",
+				Matcher: olaf.Matcher{
+					Methods: []string{"GET"},
+					Hosts:   []string{"*.example.com"},
+					Paths:   []string{"/api/users/*"},
+					Headers: map[string][]string{"X-Version": {"2*"}},
+				},
+			},
+			"orders": {
+				Name:        "orders",
+				ServiceName: "web",
+				Matcher:     olaf.Matcher{Paths: []string{"~: /orders/\\d+$"}},
+			},
+			"profile": {
+				Name:        "profile",
+				ServiceName: "web",
+				Matcher:     olaf.Matcher{Paths: []string{"/profile"}},
+			},
+			"checkout": {
+				Name:        "checkout",
+				ServiceName: "web",
+				Matcher:     olaf.Matcher{Paths: []string{"/checkout"}},
+			},
+			"health": {
+				Name:     "health",
+				Matcher:  olaf.Matcher{Paths: []string{"/health"}},
+				Response: &olaf.StaticResponse{StatusCode: 200},
+			},
+		},
+		Plugins: map[string]*olaf.Plugin{
+			"limit": {Name: "limit", Type: "rate_limit"},
+			"canary": {
+				Name:       "canary",
+				Type:       olaf.PluginTypeCanary,
+				RouteName:  "api",
+				OrderAfter: "rate_limit",
+				Config: map[string]interface{}{
+					"upstream":    "staging",
+					"key":         "{query.tid}",
+					"type":        "int",
+					"whitelist":   "$ > 0 && $ <= 10",
+					"target_path": "/canary$",
+				},
+			},
+			"shadow": {
+				Name:       "shadow",
+				Type:       olaf.PluginTypeMirror,
+				RouteName:  "orders",
+				OrderAfter: "rate_limit",
+				Config: map[string]interface{}{
+					"upstream": "staging",
+				},
+			},
+			"internal": {
+				Name:       "internal",
+				Type:       olaf.PluginTypeCanary,
+				RouteName:  "profile",
+				OrderAfter: "rate_limit",
+				Order:      1,
+				Config: map[string]interface{}{
+					"upstream":  "internal",
+					"key":       "{header.X-Internal}",
+					"whitelist": `$ == "1"`,
+				},
+			},
+			"beta": {
+				Name:       "beta",
+				Type:       olaf.PluginTypeCanary,
+				RouteName:  "profile",
+				OrderAfter: "rate_limit",
+				Order:      2,
+				Config: map[string]interface{}{
+					"upstream":   "staging",
+					"key":        "{header.X-User-ID}",
+					"percentage": 50,
+				},
+			},
+			"chaos": {
+				Name:       "chaos",
+				Type:       olaf.PluginTypeFault,
+				RouteName:  "checkout",
+				OrderAfter: "rate_limit",
+				Config: map[string]interface{}{
+					"abort_status":     503,
+					"abort_percentage": 10,
+					"headers":          map[string][]string{"X-Chaos": {"on"}},
+				},
+			},
+			"split": {
+				Name:       "split",
+				Type:       olaf.PluginTypeTrafficSplit,
+				RouteName:  "checkout",
+				OrderAfter: "rate_limit",
+				Config: map[string]interface{}{
+					"splits": []interface{}{
+						map[string]interface{}{"service": "web", "weight": 90},
+						map[string]interface{}{"service": "legacy", "weight": 0},
+						map[string]interface{}{"service": "staging", "weight": 10},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name    string
+		inReq   *Request
+		wantSim *Simulation
+	}{
+		{
+			name:  "canary matched",
+			inReq: &Request{Method: "GET", Host: "example.com", Path: "/api/foo", Query: map[string][]string{"tid": {"5"}}},
+			wantSim: &Simulation{
+				Matched:       true,
+				Route:         "api",
+				Plugins:       []string{"limit", "canary"},
+				Canary:        "canary",
+				CanaryMatched: true,
+				Service:       "staging",
+				Path:          "/canary/v1/foo",
+			},
+		},
+		{
+			name:  "canary not matched",
+			inReq: &Request{Method: "GET", Host: "example.com", Path: "/api/foo", Query: map[string][]string{"tid": {"11"}}},
+			wantSim: &Simulation{
+				Matched: true,
+				Route:   "api",
+				Plugins: []string{"limit", "canary"},
+				Canary:  "canary",
+				Service: "web",
+				Path:    "/v1/foo",
+			},
+		},
+		{
+			name: "more specific route",
+			inReq: &Request{
+				Method:  "get",
+				Host:    "www.example.com:8080",
+				Path:    "/api/users/1",
+				Headers: map[string][]string{"x-version": {"2.1"}},
+			},
+			wantSim: &Simulation{
+				Matched: true,
+				Route:   "users",
+				Plugins: []string{"limit"},
+				Service: "web",
+				Path:    "/api/users/1",
+			},
+		},
+		{
+			name:  "header not matched",
+			inReq: &Request{Method: "GET", Host: "www.example.com", Path: "/api/users/1"},
+			wantSim: &Simulation{
+				Matched: true,
+				Route:   "api",
+				Plugins: []string{"limit", "canary"},
+				Canary:  "canary",
+				Service: "web",
+				Path:    "/v1/users/1",
+			},
+		},
+		{
+			name:  "regexp path",
+			inReq: &Request{Method: "POST", Path: "/orders/123"},
+			wantSim: &Simulation{
+				Matched: true,
+				Route:   "orders",
+				Plugins: []string{"limit", "shadow"},
+				Mirrors: []string{"staging"},
+				Service: "web",
+				Path:    "/orders/123",
+			},
+		},
+		{
+			name:  "canary percentage matched",
+			inReq: &Request{Method: "GET", Path: "/profile", Headers: map[string][]string{"X-User-ID": {"bob"}}},
+			wantSim: &Simulation{
+				Matched:       true,
+				Route:         "profile",
+				Plugins:       []string{"limit", "internal", "beta"},
+				Canary:        "beta",
+				CanaryMatched: true,
+				Service:       "staging",
+				Path:          "/profile",
+			},
+		},
+		{
+			name: "first canary matched",
+			inReq: &Request{Method: "GET", Path: "/profile", Headers: map[string][]string{
+				"X-User-ID":  {"bob"},
+				"X-Internal": {"1"},
+			}},
+			wantSim: &Simulation{
+				Matched:       true,
+				Route:         "profile",
+				Plugins:       []string{"limit", "internal", "beta"},
+				Canary:        "internal",
+				CanaryMatched: true,
+				Service:       "internal",
+				Path:          "/profile",
+			},
+		},
+		{
+			name:  "canary percentage not matched",
+			inReq: &Request{Method: "GET", Path: "/profile", Headers: map[string][]string{"X-User-ID": {"alice"}}},
+			wantSim: &Simulation{
+				Matched: true,
+				Route:   "profile",
+				Plugins: []string{"limit", "internal", "beta"},
+				Canary:  "beta",
+				Service: "web",
+				Path:    "/profile",
+			},
+		},
+		{
+			name:  "canary percentage without key",
+			inReq: &Request{Method: "GET", Path: "/profile"},
+			wantSim: &Simulation{
+				Matched: true,
+				Route:   "profile",
+				Plugins: []string{"limit", "internal", "beta"},
+				Canary:  "beta",
+				Service: "web",
+				Path:    "/profile",
+			},
+		},
+		{
+			name:  "traffic split",
+			inReq: &Request{Method: "GET", Path: "/checkout"},
+			wantSim: &Simulation{
+				Matched:      true,
+				Route:        "checkout",
+				Plugins:      []string{"limit", "chaos", "split"},
+				TrafficSplit: "split",
+				Splits: []*olaf.TrafficSplit{
+					{ServiceName: "web", Weight: 90},
+					{ServiceName: "staging", Weight: 10},
+				},
+				Path: "/checkout",
+			},
+		},
+		{
+			name:  "fault",
+			inReq: &Request{Method: "GET", Path: "/checkout", Headers: map[string][]string{"X-Chaos": {"on"}}},
+			wantSim: &Simulation{
+				Matched:      true,
+				Route:        "checkout",
+				Plugins:      []string{"limit", "chaos", "split"},
+				Faults:       []string{"chaos"},
+				TrafficSplit: "split",
+				Splits: []*olaf.TrafficSplit{
+					{ServiceName: "web", Weight: 90},
+					{ServiceName: "staging", Weight: 10},
+				},
+				Path: "/checkout",
+			},
+		},
+		{
+			name:  "static response",
+			inReq: &Request{Method: "GET", Path: "/health"},
+			wantSim: &Simulation{
+				Matched:  true,
+				Route:    "health",
+				Response: &olaf.StaticResponse{StatusCode: 200},
+			},
+		},
+		{
+			name:    "not matched",
+			inReq:   &Request{Method: "GET", Path: "/orders/abc"},
+			wantSim: &Simulation{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sim, err := Simulate(data, c.inReq, Options{})
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(sim, c.wantSim) {
+				t.Fatalf("Simulation: got (%+v), want (%+v)", sim, c.wantSim)
+			}
+		})
+	}
+}
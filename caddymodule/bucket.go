@@ -0,0 +1,44 @@
+package caddymodule
+
+import (
+	"net/http"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	caddy.RegisterModule(MatchBucket{})
+}
+
+// MatchBucket matches a stable percentage of clients, which are bucketed by
+// the hash of a key (see olaf.Bucket). It's used by the canary plugins in the
+// percentage mode, since there is no hash function in the CEL expressions.
+type MatchBucket struct {
+	// The key differentiating one client from another, which usually
+	// contains placeholders (e.g. `{http.request.header.X-User-ID}`).
+	Key string `json:"key,omitempty"`
+
+	// The percentage (from 0 to 100) of clients to match.
+	Percentage int `json:"percentage,omitempty"`
+}
+
+// CaddyModule returns the Caddy module information.
+func (MatchBucket) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.olaf_bucket",
+		New: func() caddy.Module { return new(MatchBucket) },
+	}
+}
+
+// Match implements caddyhttp.RequestMatcher.
+func (m MatchBucket) Match(r *http.Request) bool {
+	repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	return olaf.InBucket(repl.ReplaceAll(m.Key, ""), m.Percentage)
+}
+
+// Interface guards
+var (
+	_ caddyhttp.RequestMatcher = (*MatchBucket)(nil)
+)
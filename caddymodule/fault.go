@@ -0,0 +1,119 @@
+package caddymodule
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	caddy.RegisterModule(Fault{})
+}
+
+// Fault implements a handler that injects faults, for testing the resilience
+// of clients. A request may be delayed, and then aborted with the given status
+// code, before being handled by the following handlers.
+type Fault struct {
+	// The delay injected before the request is handled. If MaxDelay is also
+	// specified, a random delay between the two is injected instead.
+	Delay    caddy.Duration `json:"delay,omitempty"`
+	MaxDelay caddy.Duration `json:"max_delay,omitempty"`
+
+	// The percentage (from 1 to 100) of requests to be delayed. Default: 100.
+	DelayPercentage int `json:"delay_percentage,omitempty"`
+
+	// The status code of the response, with which the request is aborted.
+	AbortStatus int `json:"abort_status,omitempty"`
+
+	// The percentage (from 1 to 100) of requests to be aborted. Default: 100.
+	AbortPercentage int `json:"abort_percentage,omitempty"`
+}
+
+// CaddyModule returns the Caddy module information.
+func (Fault) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.olaf_fault",
+		New: func() caddy.Module { return new(Fault) },
+	}
+}
+
+// Provision implements caddy.Provisioner.
+func (f *Fault) Provision(ctx caddy.Context) error {
+	if f.DelayPercentage == 0 {
+		f.DelayPercentage = 100
+	}
+	if f.AbortPercentage == 0 {
+		f.AbortPercentage = 100
+	}
+	return nil
+}
+
+// Validate implements caddy.Validator.
+func (f *Fault) Validate() error {
+	// As in the builder, max_delay alone is not enough.
+	if f.Delay == 0 && f.AbortStatus == 0 {
+		return fmt.Errorf("neither delay nor abort_status is specified")
+	}
+	if f.MaxDelay != 0 && f.MaxDelay < f.Delay {
+		return fmt.Errorf("max_delay must not be less than delay")
+	}
+	if f.AbortStatus != 0 && (f.AbortStatus < 100 || f.AbortStatus > 599) {
+		return fmt.Errorf("invalid abort_status %d", f.AbortStatus)
+	}
+	if f.DelayPercentage < 0 || f.DelayPercentage > 100 {
+		return fmt.Errorf("delay_percentage must be between 1 and 100")
+	}
+	if f.AbortPercentage < 0 || f.AbortPercentage > 100 {
+		return fmt.Errorf("abort_percentage must be between 1 and 100")
+	}
+	return nil
+}
+
+// ServeHTTP implements caddyhttp.MiddlewareHandler.
+func (f *Fault) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if d := f.delay(); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return r.Context().Err()
+		}
+	}
+
+	if f.AbortStatus != 0 && sample(f.AbortPercentage) {
+		w.WriteHeader(f.AbortStatus)
+		return nil
+	}
+
+	return next.ServeHTTP(w, r)
+}
+
+// delay returns the delay for the current request, which is zero if the
+// request is not sampled.
+func (f *Fault) delay() time.Duration {
+	if (f.Delay == 0 && f.MaxDelay == 0) || !sample(f.DelayPercentage) {
+		return 0
+	}
+	d := time.Duration(f.Delay)
+	if f.MaxDelay > f.Delay {
+		d += time.Duration(rand.Int63n(int64(f.MaxDelay - f.Delay))) // nolint:gosec
+	}
+	return d
+}
+
+// sample reports whether the current request is sampled per percentage.
+func sample(percentage int) bool {
+	return percentage >= 100 || rand.Intn(100) < percentage // nolint:gosec
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Fault)(nil)
+	_ caddy.Validator             = (*Fault)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Fault)(nil)
+)
@@ -0,0 +1,237 @@
+package caddymodule
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func newTestFault(t *testing.T, f *Fault) *Fault {
+	if err := f.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return f
+}
+
+// serveFault serves a request by f, and returns the status code of the
+// response, along with whether the next handler has been called.
+func serveFault(ctx context.Context, f *Fault) (code int, called bool, err error) {
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		called = true
+		return nil
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	err = f.ServeHTTP(w, r, next)
+	return w.Code, called, err
+}
+
+func TestFault_Provision(t *testing.T) {
+	f := &Fault{AbortStatus: 503, DelayPercentage: 10}
+	if err := f.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	want := &Fault{AbortStatus: 503, DelayPercentage: 10, AbortPercentage: 100}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatalf("Fault: got (%+v), want (%+v)", f, want)
+	}
+}
+
+func TestFault_Validate(t *testing.T) {
+	cases := []struct {
+		name       string
+		inFault    *Fault
+		wantErrStr string
+	}{
+		{
+			name:    "delay",
+			inFault: &Fault{Delay: caddy.Duration(time.Second)},
+		},
+		{
+			name:    "abort",
+			inFault: &Fault{AbortStatus: 503},
+		},
+		{
+			name:    "max delay with abort",
+			inFault: &Fault{MaxDelay: caddy.Duration(time.Second), AbortStatus: 503},
+		},
+		{
+			name:       "no fault",
+			inFault:    &Fault{},
+			wantErrStr: "neither delay nor abort_status is specified",
+		},
+		{
+			name:       "max delay only",
+			inFault:    &Fault{MaxDelay: caddy.Duration(time.Second)},
+			wantErrStr: "neither delay nor abort_status is specified",
+		},
+		{
+			name:       "max delay less than delay",
+			inFault:    &Fault{Delay: caddy.Duration(time.Second), MaxDelay: caddy.Duration(time.Millisecond)},
+			wantErrStr: "max_delay must not be less than delay",
+		},
+		{
+			name:       "invalid abort status",
+			inFault:    &Fault{AbortStatus: 1000},
+			wantErrStr: "invalid abort_status 1000",
+		},
+		{
+			name:       "invalid delay percentage",
+			inFault:    &Fault{Delay: caddy.Duration(time.Second), DelayPercentage: 101},
+			wantErrStr: "delay_percentage must be between 1 and 100",
+		},
+		{
+			name:       "invalid abort percentage",
+			inFault:    &Fault{AbortStatus: 503, AbortPercentage: -1},
+			wantErrStr: "abort_percentage must be between 1 and 100",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.inFault.Validate()
+			if c.wantErrStr == "" {
+				if err != nil {
+					t.Fatalf("Err: got (%v), want (nil)", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != c.wantErrStr {
+				t.Fatalf("Err: got (%v), want (%s)", err, c.wantErrStr)
+			}
+		})
+	}
+}
+
+func TestFault_ServeHTTP(t *testing.T) {
+	cases := []struct {
+		name       string
+		inFault    *Fault
+		wantCode   int
+		wantCalled bool
+		wantDelay  time.Duration
+	}{
+		{
+			name:       "delay",
+			inFault:    &Fault{Delay: caddy.Duration(50 * time.Millisecond)},
+			wantCode:   http.StatusOK,
+			wantCalled: true,
+			wantDelay:  50 * time.Millisecond,
+		},
+		{
+			name:     "abort",
+			inFault:  &Fault{AbortStatus: http.StatusServiceUnavailable},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:      "delay and abort",
+			inFault:   &Fault{Delay: caddy.Duration(50 * time.Millisecond), AbortStatus: http.StatusBadGateway},
+			wantCode:  http.StatusBadGateway,
+			wantDelay: 50 * time.Millisecond,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newTestFault(t, c.inFault)
+
+			start := time.Now()
+			code, called, err := serveFault(context.Background(), f)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed < c.wantDelay {
+				t.Fatalf("Delay: got (%v), want (>= %v)", elapsed, c.wantDelay)
+			}
+			if code != c.wantCode {
+				t.Fatalf("StatusCode: got (%d), want (%d)", code, c.wantCode)
+			}
+			if called != c.wantCalled {
+				t.Fatalf("Called: got (%v), want (%v)", called, c.wantCalled)
+			}
+		})
+	}
+}
+
+func TestFault_ServeHTTP_Canceled(t *testing.T) {
+	f := newTestFault(t, &Fault{Delay: caddy.Duration(time.Hour)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, called, err := serveFault(ctx, f)
+	if err != context.Canceled {
+		t.Fatalf("Err: got (%v), want (%v)", err, context.Canceled)
+	}
+	if called {
+		t.Fatalf("Called: got (true), want (false)")
+	}
+}
+
+func TestFault_RandomDelay(t *testing.T) {
+	f := newTestFault(t, &Fault{
+		Delay:    caddy.Duration(100 * time.Millisecond),
+		MaxDelay: caddy.Duration(200 * time.Millisecond),
+	})
+
+	for i := 0; i < 1000; i++ {
+		if d := f.delay(); d < 100*time.Millisecond || d >= 200*time.Millisecond {
+			t.Fatalf("Delay: got (%v), want (between 100ms and 200ms)", d)
+		}
+	}
+}
+
+func TestFault_Percentage(t *testing.T) {
+	cases := []struct {
+		name    string
+		inFault *Fault
+		count   func(f *Fault) bool
+	}{
+		{
+			name: "delay percentage",
+			inFault: &Fault{
+				Delay:           caddy.Duration(time.Second),
+				DelayPercentage: 30,
+			},
+			count: func(f *Fault) bool { return f.delay() > 0 },
+		},
+		{
+			name: "abort percentage",
+			inFault: &Fault{
+				AbortStatus:     http.StatusServiceUnavailable,
+				AbortPercentage: 30,
+			},
+			count: func(f *Fault) bool {
+				code, _, _ := serveFault(context.Background(), f)
+				return code == http.StatusServiceUnavailable
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newTestFault(t, c.inFault)
+
+			var got int
+			for i := 0; i < 1000; i++ {
+				if c.count(f) {
+					got++
+				}
+			}
+
+			// Allow a deviation of 5%.
+			if got < 250 || got > 350 {
+				t.Fatalf("Count: got (%d), want (300±50)", got)
+			}
+		})
+	}
+}
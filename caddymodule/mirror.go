@@ -0,0 +1,203 @@
+package caddymodule
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	caddy.RegisterModule(Mirror{})
+}
+
+const (
+	// DefaultMirrorMaxBodySize is the default maximum size of the request
+	// body to be mirrored.
+	DefaultMirrorMaxBodySize = 1 << 20
+	// DefaultMirrorTimeout is the default maximum time allowed for a mirrored
+	// request.
+	DefaultMirrorTimeout = 10 * time.Second
+
+	// maxMirrorRequests is the maximum number of in-flight mirrored requests
+	// per handler, beyond which new copies are dropped.
+	maxMirrorRequests = 100
+)
+
+// Mirror implements a handler that sends a copy of each request (or a sampled
+// percentage of them) to another upstream, in a fire-and-forget way. The
+// responses, as well as the errors, of the copies are discarded, so the
+// original requests are never affected.
+type Mirror struct {
+	// The addresses of the upstream backends, in the same format as the ones
+	// of `reverse_proxy`. The copies are sent to them in turn.
+	Upstreams []string `json:"upstreams,omitempty"`
+
+	// The percentage (from 1 to 100) of requests to mirror. Default: 100.
+	Percentage int `json:"percentage,omitempty"`
+
+	// The maximum size in bytes of the request body. Requests with larger
+	// bodies are not mirrored. Default: 1 MiB.
+	MaxBodySize int64 `json:"max_body_size,omitempty"`
+
+	// The maximum time allowed for a mirrored request. Default: 10s.
+	Timeout caddy.Duration `json:"timeout,omitempty"`
+
+	addrs    []caddy.NetworkAddress
+	next     uint32
+	inflight chan struct{}
+	client   *http.Client
+}
+
+// CaddyModule returns the Caddy module information.
+func (Mirror) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.olaf_mirror",
+		New: func() caddy.Module { return new(Mirror) },
+	}
+}
+
+// Provision implements caddy.Provisioner.
+func (m *Mirror) Provision(ctx caddy.Context) error {
+	if m.Percentage == 0 {
+		m.Percentage = 100
+	}
+	if m.MaxBodySize == 0 {
+		m.MaxBodySize = DefaultMirrorMaxBodySize
+	}
+	if m.Timeout == 0 {
+		m.Timeout = caddy.Duration(DefaultMirrorTimeout)
+	}
+
+	for _, upstream := range m.Upstreams {
+		addr, err := caddy.ParseNetworkAddress(upstream)
+		if err != nil {
+			return fmt.Errorf("invalid upstream %q: %v", upstream, err)
+		}
+		if addr.PortRangeSize() != 1 {
+			return fmt.Errorf("upstream %q must be a single address", upstream)
+		}
+		m.addrs = append(m.addrs, addr)
+	}
+
+	// Dial the upstream backends by their own networks (e.g. unix sockets),
+	// which are looked up by the (fake) hosts of the request URLs.
+	var dialer net.Dialer
+	m.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, host string) (net.Conn, error) {
+				var i int
+				if _, err := fmt.Sscanf(host, "upstream-%d:80", &i); err != nil || i >= len(m.addrs) {
+					return nil, fmt.Errorf("unknown upstream %q", host)
+				}
+				addr := m.addrs[i]
+				return dialer.DialContext(ctx, addr.Network, addr.JoinHostPort(0))
+			},
+			MaxIdleConnsPerHost: maxMirrorRequests,
+		},
+		// Never follow the redirects of the upstream.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	m.inflight = make(chan struct{}, maxMirrorRequests)
+
+	return nil
+}
+
+// Validate implements caddy.Validator.
+func (m *Mirror) Validate() error {
+	if len(m.Upstreams) == 0 {
+		return fmt.Errorf("no upstreams")
+	}
+	if m.Percentage < 0 || m.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 1 and 100")
+	}
+	if m.MaxBodySize < 0 {
+		return fmt.Errorf("negative max_body_size")
+	}
+	return nil
+}
+
+// ServeHTTP implements caddyhttp.MiddlewareHandler.
+func (m *Mirror) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if sample(m.Percentage) {
+		// Reserve an in-flight slot before copying the request, so that no
+		// body is buffered just to be dropped.
+		select {
+		case m.inflight <- struct{}{}:
+			if req, ok := m.copyRequest(r); ok {
+				go m.send(req)
+			} else {
+				<-m.inflight
+			}
+		default:
+			// Too many in-flight copies, drop this one.
+		}
+	}
+	return next.ServeHTTP(w, r)
+}
+
+// copyRequest returns a copy of r, which targets the next upstream backend.
+// The body of r is read (up to MaxBodySize), and then restored for the
+// following handlers.
+func (m *Mirror) copyRequest(r *http.Request) (*http.Request, bool) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		buf, err := ioutil.ReadAll(io.LimitReader(r.Body, m.MaxBodySize+1))
+		r.Body = readCloser{
+			Reader: io.MultiReader(bytes.NewReader(buf), r.Body),
+			Closer: r.Body,
+		}
+		if err != nil || int64(len(buf)) > m.MaxBodySize {
+			return nil, false
+		}
+		body = buf
+	}
+
+	// Detach the copy from the original request, which may finish first.
+	req := r.Clone(context.Background())
+	req.RequestURI = ""
+	req.URL.Scheme = "http"
+	req.URL.Host = fmt.Sprintf("upstream-%d:80", int(atomic.AddUint32(&m.next, 1)-1)%len(m.addrs))
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	return req, true
+}
+
+func (m *Mirror) send(req *http.Request) {
+	defer func() { <-m.inflight }()
+
+	ctx, cancel := context.WithTimeout(req.Context(), time.Duration(m.Timeout))
+	defer cancel()
+
+	resp, err := m.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body) // nolint:errcheck
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Mirror)(nil)
+	_ caddy.Validator             = (*Mirror)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Mirror)(nil)
+)
@@ -0,0 +1,188 @@
+package caddymodule
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// mirrorUpstream is an upstream backend recording the mirrored requests.
+type mirrorUpstream struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newMirrorUpstream(t *testing.T, block <-chan struct{}) *mirrorUpstream {
+	u := new(mirrorUpstream)
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		u.mu.Lock()
+		u.bodies = append(u.bodies, string(body))
+		u.mu.Unlock()
+		if block != nil {
+			<-block
+		}
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *mirrorUpstream) received() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.bodies...)
+}
+
+func newTestMirror(t *testing.T, m *Mirror, u *mirrorUpstream) *Mirror {
+	m.Upstreams = []string{u.Listener.Addr().String()}
+	if err := m.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return m
+}
+
+// serveMirror serves a request with body by m, and returns the body received
+// by the next handler.
+func serveMirror(t *testing.T, m *Mirror, body string) string {
+	var got string
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		b, err := ioutil.ReadAll(r.Body)
+		got = string(b)
+		return err
+	})
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if err := m.ServeHTTP(httptest.NewRecorder(), r, next); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return got
+}
+
+// waitMirror waits until all the in-flight copies of m have been sent.
+func waitMirror(t *testing.T, m *Mirror) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.inflight) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("In-flight copies: got (%d), want (0)", len(m.inflight))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMirror_ServeHTTP(t *testing.T) {
+	cases := []struct {
+		name         string
+		inMirror     *Mirror
+		inBody       string
+		wantReceived []string
+	}{
+		{
+			name:         "mirrored",
+			inMirror:     &Mirror{},
+			inBody:       "hello",
+			wantReceived: []string{"hello"},
+		},
+		{
+			name:         "mirrored without body",
+			inMirror:     &Mirror{},
+			inBody:       "",
+			wantReceived: []string{""},
+		},
+		{
+			name:         "body at the limit",
+			inMirror:     &Mirror{MaxBodySize: 5},
+			inBody:       "hello",
+			wantReceived: []string{"hello"},
+		},
+		{
+			name:     "body too large",
+			inMirror: &Mirror{MaxBodySize: 4},
+			inBody:   "hello",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			u := newMirrorUpstream(t, nil)
+			m := newTestMirror(t, c.inMirror, u)
+
+			if got := serveMirror(t, m, c.inBody); got != c.inBody {
+				t.Fatalf("Body: got (%q), want (%q)", got, c.inBody)
+			}
+
+			waitMirror(t, m)
+			got := u.received()
+			if len(got) != len(c.wantReceived) {
+				t.Fatalf("Received: got (%q), want (%q)", got, c.wantReceived)
+			}
+			for i := range got {
+				if got[i] != c.wantReceived[i] {
+					t.Fatalf("Received: got (%q), want (%q)", got, c.wantReceived)
+				}
+			}
+		})
+	}
+}
+
+func TestMirror_Percentage(t *testing.T) {
+	u := newMirrorUpstream(t, nil)
+	m := newTestMirror(t, &Mirror{Percentage: 30}, u)
+
+	for i := 0; i < 1000; i++ {
+		serveMirror(t, m, "hello")
+		waitMirror(t, m)
+	}
+
+	// Allow a deviation of 5%.
+	if got := len(u.received()); got < 250 || got > 350 {
+		t.Fatalf("Received: got (%d), want (300±50)", got)
+	}
+}
+
+func TestMirror_MaxInflight(t *testing.T) {
+	block := make(chan struct{})
+	u := newMirrorUpstream(t, block)
+	m := newTestMirror(t, &Mirror{}, u)
+
+	// The copies are held by the upstream, so the ones beyond the limit are
+	// dropped rather than queued.
+	for i := 0; i < maxMirrorRequests+10; i++ {
+		if got := serveMirror(t, m, "hello"); got != "hello" {
+			t.Fatalf("Body: got (%q), want (%q)", got, "hello")
+		}
+	}
+	if got := len(m.inflight); got != maxMirrorRequests {
+		t.Fatalf("In-flight copies: got (%d), want (%d)", got, maxMirrorRequests)
+	}
+
+	// No body is buffered for the dropped copies.
+	var copied bool
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, copied = r.Body.(readCloser)
+		return nil
+	})
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	if err := m.ServeHTTP(httptest.NewRecorder(), r, next); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if copied {
+		t.Fatalf("Copied: got (true), want (false)")
+	}
+
+	close(block)
+	waitMirror(t, m)
+	if got := len(u.received()); got != maxMirrorRequests {
+		t.Fatalf("Received: got (%d), want (%d)", got, maxMirrorRequests)
+	}
+}
@@ -32,6 +32,10 @@ type Olaf struct {
 	// Maximum time allowed for a complete connection and request. This
 	// option is useful only if Type is TypeHTTP.
 	Timeout caddy.Duration `json:"timeout,omitempty"`
+
+	// Whether to order the routes of the same priority automatically, by
+	// the specificity of their matchers.
+	AutoPriority bool `json:"auto_priority,omitempty"`
 }
 
 // CaddyModule returns the Caddy module information.
@@ -60,7 +64,9 @@ func (o *Olaf) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.
 
 // UnmarshalCaddyfile implements caddyfile.Unmarshaler. Syntax:
 //
-//    olaf <path>
+//    olaf <path> {
+//        auto_priority
+//    }
 //
 func (o *Olaf) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err error) {
 	if !d.Next() || !d.NextArg() {
@@ -68,6 +74,18 @@ func (o *Olaf) UnmarshalCaddyfile(d *caddyfile.Dispenser) (err error) {
 	}
 	path := d.Val()
 
+	for d.NextBlock(0) {
+		switch d.Val() {
+		case "auto_priority":
+			if d.NextArg() {
+				return d.ArgErr()
+			}
+			o.AutoPriority = true
+		default:
+			return d.Errf("unrecognized subdirective %s", d.Val())
+		}
+	}
+
 	if strings.HasPrefix(path, "http://") {
 		o.Type = TypeHTTP
 		o.Path = path
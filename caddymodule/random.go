@@ -0,0 +1,56 @@
+package caddymodule
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	caddy.RegisterModule(MatchRandom{})
+}
+
+// randomBucketVar is the name of the request variable, which holds the
+// random bucket of the current request.
+const randomBucketVar = "olaf.random_bucket"
+
+// MatchRandom matches a percentage of requests, by a random bucket (from 0 to
+// 99) drawn once per request. Since all the matchers of the same request share
+// the bucket, the ones with cumulative percentages (e.g. 50, 80 and 100) split
+// requests into mutually exclusive parts (e.g. 50%, 30% and 20%). It's used by
+// the traffic_split plugins.
+type MatchRandom struct {
+	// The percentage (from 0 to 100) of requests to match.
+	Percentage int `json:"percentage,omitempty"`
+}
+
+// CaddyModule returns the Caddy module information.
+func (MatchRandom) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.olaf_random",
+		New: func() caddy.Module { return new(MatchRandom) },
+	}
+}
+
+// Match implements caddyhttp.RequestMatcher.
+func (m MatchRandom) Match(r *http.Request) bool {
+	return randomBucket(r) < m.Percentage
+}
+
+// randomBucket returns the random bucket of r, which is drawn on the first
+// call, and then kept in the request variables.
+func randomBucket(r *http.Request) int {
+	if bucket, ok := caddyhttp.GetVar(r.Context(), randomBucketVar).(int); ok {
+		return bucket
+	}
+	bucket := rand.Intn(100) // nolint:gosec
+	caddyhttp.SetVar(r.Context(), randomBucketVar, bucket)
+	return bucket
+}
+
+// Interface guards
+var (
+	_ caddyhttp.RequestMatcher = (*MatchRandom)(nil)
+)
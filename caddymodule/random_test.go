@@ -0,0 +1,42 @@
+package caddymodule
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func TestMatchRandom(t *testing.T) {
+	splits := []MatchRandom{{Percentage: 50}, {Percentage: 80}, {Percentage: 100}}
+
+	counts := make([]int, len(splits))
+	for i := 0; i < 10000; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, make(map[string]interface{})))
+
+		// The first matched split takes the request.
+		matched := -1
+		for j, m := range splits {
+			if m.Match(r) {
+				if matched == -1 {
+					matched = j
+				}
+			} else if matched != -1 {
+				t.Fatalf("Split %d: got (unmatched), want (matched) after split %d", j, matched)
+			}
+		}
+		if matched == -1 {
+			t.Fatalf("Splits: got (unmatched), want the last one matched")
+		}
+		counts[matched]++
+	}
+
+	// Allow a deviation of 3%.
+	for i, want := range []int{5000, 3000, 2000} {
+		if got := counts[i]; got < want-300 || got > want+300 {
+			t.Fatalf("Count %d: got (%d), want (%d±300)", i, got, want)
+		}
+	}
+}
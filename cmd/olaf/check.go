@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/RussellLuo/olaf/admin"
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+// check runs the `check` command, which validates a config file (see
+// admin.Validate), and returns the exit code:
+//
+//	0  the config is valid
+//	1  the config has errors or conflicts
+//	2  the config can not be loaded
+func check(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.StringVar(&configFile, "config", "../../caddyconfig/adapter/apis.yaml", "Olaf config file")
+	autoPriority := fs.Bool("auto-priority", false, "Order the routes automatically by the specificity of their matchers")
+	fs.Parse(args) // nolint:errcheck
+
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		return 2
+	}
+	data, err := yaml.Parse(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		return 2
+	}
+
+	v := admin.Validate(data, builder.Options{AutoPriority: *autoPriority})
+	for _, e := range v.Errors {
+		fmt.Printf("error: %s\n", e)
+	}
+	for _, c := range v.Conflicts {
+		fmt.Printf("conflict: %s\n", c)
+	}
+	if !v.Valid {
+		return 1
+	}
+
+	fmt.Println("ok")
+	return 0
+}
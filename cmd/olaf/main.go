@@ -2,36 +2,168 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"syscall"
 
 	"github.com/RussellLuo/olaf/admin"
 	"github.com/RussellLuo/olaf/store/yaml"
+	"github.com/go-chi/chi"
 )
 
+// version is the build version, which can be set by
+// `-ldflags "-X main.version=<version>"`.
+var version string
+
 var (
 	httpAddr   string
 	configFile string
+
+	apiKeysFile  string
+	htpasswdFile string
+	tlsCertFile  string
+	tlsKeyFile   string
+	clientCAFile string
+	rbacFile     string
+
+	auditFile       string
+	auditMaxSize    int64
+	auditMaxBackups int
 )
 
 func main() {
+	// Subcommands.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(check(os.Args[2:]))
+		case "simulate":
+			os.Exit(simulate(os.Args[2:]))
+		}
+	}
+
 	flag.StringVar(&httpAddr, "addr", ":2020", "HTTP listen address")
 	flag.StringVar(&configFile, "config", "../../caddyconfig/adapter/apis.yaml", "Olaf config file")
+	flag.StringVar(&apiKeysFile, "api-keys", "", "API keys file, each line in the form of `<identity>:<key>`")
+	flag.StringVar(&htpasswdFile, "htpasswd", "", "htpasswd file for basic auth")
+	flag.StringVar(&tlsCertFile, "tls-cert", "", "TLS certificate file")
+	flag.StringVar(&tlsKeyFile, "tls-key", "", "TLS key file")
+	flag.StringVar(&clientCAFile, "client-ca", "", "CA file for verifying client certificates (mTLS)")
+	flag.StringVar(&rbacFile, "rbac", "", "RBAC policy file (requires authentication)")
+	flag.StringVar(&auditFile, "audit-log", "", "Audit log file (JSON lines)")
+	flag.Int64Var(&auditMaxSize, "audit-max-size", 100<<20, "Maximum size in bytes of the audit log file before it gets rotated")
+	flag.IntVar(&auditMaxBackups, "audit-max-backups", 10, "Maximum number of rotated audit log files to keep")
 	flag.Parse()
 
 	store := yaml.New(configFile)
+	metrics := admin.NewMetrics(store, store)
+	svc := admin.NewMetered(metrics, store)
+
+	authn, err := newAuthenticator()
+	if err != nil {
+		log.Fatalf("err: %v", err)
+	}
+	var policy *admin.Policy
+	if rbacFile != "" {
+		if authn == nil {
+			log.Fatalf("err: -rbac requires authentication")
+		}
+		policy, err = admin.LoadPolicy(rbacFile)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		svc = admin.NewRBAC(policy, svc)
+	}
+
+	// Audit outside of RBAC, to record the denied changes as well.
+	var auditLog *admin.AuditFile
+	if auditFile != "" {
+		auditLog, err = admin.OpenAuditFile(auditFile, auditMaxSize, auditMaxBackups)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		defer auditLog.Close()
+		svc = admin.NewAudit(auditLog, svc)
+	}
+
+	// Encode the config in YAML the same way as the config file.
+	router := admin.NewRouter(svc, admin.Codec{MarshalConfig: yaml.Marshal})
+	router.Method("GET", "/openapi.json", admin.NewOpenAPIHandler())
+	router.Method("GET", "/events", admin.NewEventsHandler(store, policy, store))
+
+	webhooks := admin.NewWebhooks(store, nil)
+	router.Mount("/webhooks", webhooks.Handler(policy, store))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go webhooks.Run(ctx) // nolint:errcheck
+	if auditLog != nil {
+		router.Method("GET", "/audit", admin.NewAuditHandler(auditLog, policy))
+	}
+	router.Mount("/validate", admin.NewValidationHandler(svc))
+	router.Method("POST", "/simulate", admin.NewSimulationHandler(svc))
+
+	var handler http.Handler = router
+	if authn != nil {
+		handler = admin.AuthMiddleware(authn)(handler)
+	} else {
+		log.Println("WARNING: no authentication is configured for the admin API")
+	}
+
+	// The status and metrics endpoints are served without authentication,
+	// for probes and scrapers.
+	root := chi.NewRouter()
+	root.Use(metrics.Middleware)
+	root.Mount("/status", admin.NewStatusHandler(store, store, buildVersion()))
+	root.Method("GET", "/metrics", metrics.Handler())
+	root.Mount("/", handler)
+	handler = root
+
+	handler = admin.RequestIDMiddleware(handler)
+	handler = admin.NegotiationMiddleware(handler)
+
 	server := &http.Server{
 		Addr:    httpAddr,
-		Handler: admin.NewHTTPRouter(store, admin.NewCodecs()),
+		Handler: handler,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		server.TLSConfig = &tls.Config{
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			ClientCAs:  pool,
+		}
 	}
 
+	// Reload the config file on SIGHUP.
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		for range c {
+			if err := store.Reload(); err != nil {
+				log.Printf("failed to reload config: %v", err)
+				continue
+			}
+			log.Printf("config reloaded")
+		}
+	}()
+
 	errs := make(chan error, 2)
 	go func() {
+		if tlsCertFile != "" {
+			log.Printf("transport=HTTPS addr=%s\n", httpAddr)
+			errs <- server.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
+			return
+		}
 		log.Printf("transport=HTTP addr=%s\n", httpAddr)
 		errs <- server.ListenAndServe()
 	}()
@@ -46,3 +178,59 @@ func main() {
 
 	log.Printf("terminated, err:%v", <-errs)
 }
+
+// buildVersion returns the build version, which defaults to the version of
+// the main module if not set.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// newAuthenticator creates an authenticator from the flags. If no
+// authentication is configured, nil is returned.
+func newAuthenticator() (admin.Authenticator, error) {
+	var authns admin.Authenticators
+
+	if apiKeysFile != "" {
+		keys, err := admin.LoadAPIKeys(apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		authns = append(authns, keys)
+	}
+	if htpasswdFile != "" {
+		users, err := admin.LoadHtpasswd(htpasswdFile)
+		if err != nil {
+			return nil, err
+		}
+		authns = append(authns, users)
+	}
+	if clientCAFile != "" {
+		if tlsCertFile == "" {
+			return nil, fmt.Errorf("-client-ca requires -tls-cert and -tls-key")
+		}
+		authns = append(authns, admin.ClientCert)
+	}
+
+	if len(authns) == 0 {
+		return nil, nil
+	}
+	return authns, nil
+}
+
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", filename)
+	}
+	return pool, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/RussellLuo/olaf/caddyconfig/builder"
+	"github.com/RussellLuo/olaf/store/yaml"
+)
+
+// pairs is a repeatable flag of key-value pairs, separated by sep.
+type pairs struct {
+	sep string
+	m   map[string][]string
+}
+
+func (p *pairs) String() string { return "" }
+
+func (p *pairs) Set(s string) error {
+	parts := strings.SplitN(s, p.sep, 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("must be in the form of `<key>%s<value>`", p.sep)
+	}
+	if p.m == nil {
+		p.m = make(map[string][]string)
+	}
+	k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	p.m[k] = append(p.m[k], v)
+	return nil
+}
+
+// simulate runs the `simulate` command, which prints the result of routing
+// a synthetic request per a config file (see builder.Simulate).
+func simulate(args []string) int {
+	req := new(builder.Request)
+	headers := &pairs{sep: ":"}
+	query := &pairs{sep: "="}
+	cookies := &pairs{sep: "="}
+	var body string
+
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	fs.StringVar(&configFile, "config", "../../caddyconfig/adapter/apis.yaml", "Olaf config file")
+	autoPriority := fs.Bool("auto-priority", false, "Order the routes automatically by the specificity of their matchers")
+	fs.StringVar(&req.Protocol, "protocol", "http", "Request protocol")
+	fs.StringVar(&req.Method, "method", "GET", "Request method")
+	fs.StringVar(&req.Host, "host", "", "Request host")
+	fs.StringVar(&req.Path, "path", "/", "Request path")
+	fs.Var(headers, "header", "Request header in the form of `<key>:<value>` (repeatable)")
+	fs.Var(query, "query", "Query parameter in the form of `<key>=<value>` (repeatable)")
+	fs.Var(cookies, "cookie", "Cookie in the form of `<name>=<value>` (repeatable)")
+	fs.StringVar(&body, "body", "", "JSON request body")
+	fs.Parse(args) // nolint:errcheck
+
+	req.Headers = headers.m
+	req.Query = query.m
+	for k, v := range cookies.m {
+		if req.Cookies == nil {
+			req.Cookies = make(map[string]string)
+		}
+		req.Cookies[k] = v[len(v)-1]
+	}
+	if body != "" {
+		if err := json.Unmarshal([]byte(body), &req.Body); err != nil {
+			fmt.Fprintf(os.Stderr, "err: invalid -body: %v\n", err)
+			return 2
+		}
+	}
+
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		return 2
+	}
+	data, err := yaml.Parse(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		return 2
+	}
+
+	sim, err := builder.Simulate(data, req, builder.Options{AutoPriority: *autoPriority})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(sim, "", "  ")
+	fmt.Println(string(out))
+	return 0
+}
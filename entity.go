@@ -2,6 +2,8 @@ package olaf
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
 var (
@@ -16,19 +18,48 @@ var (
 
 	ErrUpstreamNotFound = errors.New("upstream not found")
 
+	ErrInvalidOperation = errors.New("invalid operation")
+
 	ErrMethodNotImplemented = errors.New("method not implemented")
 )
 
 const (
-	PluginTypeCanary = "canary"
+	PluginTypeCanary       = "canary"
+	PluginTypeTrafficSplit = "traffic_split"
+	PluginTypeMirror       = "mirror"
+	PluginTypeFault        = "fault"
+)
+
+const (
+	KindService  = "service"
+	KindRoute    = "route"
+	KindPlugin   = "plugin"
+	KindUpstream = "upstream"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpRename = "rename"
 )
 
 type Service struct {
+	// The immutable ID of the service.
+	ID       string    `json:"id" yaml:"id"`
 	Name     string    `json:"name" yaml:"name"`
 	Upstream *Upstream `json:"upstream" yaml:"upstream"`
+	Tags     []string  `json:"tags" yaml:"tags"`
+
+	// The Unix times when the entity was created and last updated.
+	CreatedAt int64 `json:"created_at" yaml:"created_at"`
+	UpdatedAt int64 `json:"updated_at" yaml:"updated_at"`
 }
 
 type Upstream struct {
+	// The immutable ID of the upstream.
+	ID string `json:"id" yaml:"id"`
+
 	Backends []*Backend `json:"backends" yaml:"backends"`
 
 	HTTP *TransportHTTP `json:"http" yaml:"http"`
@@ -38,6 +69,10 @@ type Upstream struct {
 
 	HeaderUp   *HeaderOps `json:"header_up" yaml:"header_up"`
 	HeaderDown *HeaderOps `json:"header_down" yaml:"header_down"`
+
+	// The Unix times when the entity was created and last updated.
+	CreatedAt int64 `json:"created_at" yaml:"created_at"`
+	UpdatedAt int64 `json:"updated_at" yaml:"updated_at"`
 }
 
 type Backend struct {
@@ -99,6 +134,9 @@ type StaticResponse struct {
 }
 
 type Route struct {
+	// The immutable ID of the route.
+	ID string `json:"id" yaml:"id"`
+
 	ServiceName string `json:"service_name" yaml:"service_name"`
 
 	// Route name must be unique.
@@ -109,9 +147,18 @@ type Route struct {
 
 	// Routes will be matched from highest priority to lowest.
 	Priority float64 `json:"priority" yaml:"priority"`
+
+	Tags []string `json:"tags" yaml:"tags"`
+
+	// The Unix times when the entity was created and last updated.
+	CreatedAt int64 `json:"created_at" yaml:"created_at"`
+	UpdatedAt int64 `json:"updated_at" yaml:"updated_at"`
 }
 
 type Plugin struct {
+	// The immutable ID of the plugin.
+	ID string `json:"id" yaml:"id"`
+
 	Disabled bool `json:"disabled" yaml:"disabled"`
 
 	Name       string                 `json:"name" yaml:"name"`
@@ -119,8 +166,18 @@ type Plugin struct {
 	OrderAfter string                 `json:"order_after" yaml:"order_after"`
 	Config     map[string]interface{} `json:"config" yaml:"config"`
 
+	// The order among the plugins of the same type applied to a route, from
+	// the lowest to the highest. Plugins of the same order are sorted by name.
+	Order int `json:"order" yaml:"order"`
+
 	RouteName   string `json:"route_name" yaml:"route_name"`
 	ServiceName string `json:"service_name" yaml:"service_name"`
+
+	Tags []string `json:"tags" yaml:"tags"`
+
+	// The Unix times when the entity was created and last updated.
+	CreatedAt int64 `json:"created_at" yaml:"created_at"`
+	UpdatedAt int64 `json:"updated_at" yaml:"updated_at"`
 }
 
 type PluginCanaryConfig struct {
@@ -130,6 +187,12 @@ type PluginCanaryConfig struct {
 	KeyType   string `json:"type" yaml:"type" mapstructure:"type"`
 	Whitelist string `json:"whitelist" yaml:"whitelist" mapstructure:"whitelist"`
 
+	// The percentage of clients, bucketed by the hash of the key (see Bucket),
+	// to be routed to the upstream service. It's an alternative to Whitelist.
+	// If specified, 0 is valid and routes no clients, so that a rollout can
+	// start from 0%.
+	Percentage *int `json:"percentage,omitempty" yaml:"percentage,omitempty" mapstructure:"percentage"`
+
 	// The advanced matcher.
 	// See https://caddyserver.com/docs/json/apps/http/servers/routes/match/
 	Matcher map[string]interface{} `json:"matcher" yaml:"matcher" mapstructure:"matcher"`
@@ -137,9 +200,231 @@ type PluginCanaryConfig struct {
 	URI `yaml:",inline" mapstructure:",squash"`
 }
 
+type PluginTrafficSplitConfig struct {
+	// The weights of all the splits must add up to 100.
+	Splits []*TrafficSplit `json:"splits" yaml:"splits" mapstructure:"splits"`
+}
+
+type TrafficSplit struct {
+	ServiceName string `json:"service" yaml:"service" mapstructure:"service"`
+	// The percentage of requests to be routed to the service.
+	Weight int `json:"weight" yaml:"weight" mapstructure:"weight"`
+}
+
+type PluginMirrorConfig struct {
+	// The name of the service receiving the copies of requests.
+	UpstreamServiceName string `json:"upstream" yaml:"upstream" mapstructure:"upstream"`
+
+	// The percentage of requests to be mirrored. Default: 100.
+	Percentage int `json:"percentage" yaml:"percentage" mapstructure:"percentage"`
+	// The maximum size in bytes of the request body. Requests with larger
+	// bodies are not mirrored. Default: 1 MiB.
+	MaxBodySize int64 `json:"max_body_size" yaml:"max_body_size" mapstructure:"max_body_size"`
+	// The maximum time allowed for a mirrored request. Default: 10s.
+	Timeout string `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+}
+
+type PluginFaultConfig struct {
+	// The delay injected before the request is handled. If MaxDelay is
+	// also specified, a random delay between the two is injected instead.
+	Delay    string `json:"delay" yaml:"delay" mapstructure:"delay"`
+	MaxDelay string `json:"max_delay" yaml:"max_delay" mapstructure:"max_delay"`
+	// The percentage of requests to be delayed. Default: 100.
+	DelayPercentage int `json:"delay_percentage" yaml:"delay_percentage" mapstructure:"delay_percentage"`
+
+	// The status code of the response, with which the request is aborted.
+	AbortStatus int `json:"abort_status" yaml:"abort_status" mapstructure:"abort_status"`
+	// The percentage of requests to be aborted. Default: 100.
+	AbortPercentage int `json:"abort_percentage" yaml:"abort_percentage" mapstructure:"abort_percentage"`
+
+	// Only inject faults into the requests carrying these headers.
+	Headers map[string][]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+
+	// Only inject faults into the requests of these consumers, which are
+	// identified by the value of the key (e.g. `{header.X-Consumer-ID}`).
+	ConsumerKey string   `json:"consumer_key" yaml:"consumer_key" mapstructure:"consumer_key"`
+	Consumers   []string `json:"consumers" yaml:"consumers" mapstructure:"consumers"`
+}
+
 type Data struct {
 	Version  string              `json:"version" yaml:"version"`
 	Services map[string]*Service `json:"services" yaml:"services"`
 	Routes   map[string]*Route   `json:"routes" yaml:"routes"`
 	Plugins  map[string]*Plugin  `json:"plugins" yaml:"plugins"`
 }
+
+// Operation is a single change to be applied as part of a batch.
+type Operation struct {
+	// The operation type: "create", "update", "delete" or "rename".
+	Op string `json:"op" yaml:"op"`
+	// The entity kind: "service", "route" or "plugin".
+	Kind string `json:"kind" yaml:"kind"`
+	// The name (or ID) of the entity to be updated, deleted or renamed.
+	Name string `json:"name" yaml:"name"`
+	// Whether to also delete the dependents of the entity to be deleted.
+	Cascade bool `json:"cascade" yaml:"cascade"`
+	// The new name of the entity to be renamed.
+	NewName string `json:"new_name" yaml:"new_name"`
+
+	// The entity to be created or updated, which must match Kind.
+	Service *Service `json:"service" yaml:"service"`
+	Route   *Route   `json:"route" yaml:"route"`
+	Plugin  *Plugin  `json:"plugin" yaml:"plugin"`
+}
+
+// Change describes an entity that has been changed by an operation.
+type Change struct {
+	Kind string `json:"kind" yaml:"kind"`
+	Name string `json:"name" yaml:"name"`
+	// The original name, if the entity has been renamed.
+	OldName string `json:"old_name,omitempty" yaml:"old_name,omitempty"`
+	// The changed fields.
+	Fields []string `json:"fields" yaml:"fields"`
+}
+
+// OperationError reports the failed operation within a batch.
+type OperationError struct {
+	Index int
+	Op    *Operation
+	Err   error
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s): %v", e.Index, e.Op.Op, e.Op.Kind, e.Err)
+}
+
+func (e *OperationError) Unwrap() error { return e.Err }
+
+// ReferenceError reports an entity that refers to a non-existent one.
+type ReferenceError struct {
+	Kind string `json:"kind" yaml:"kind"`
+	Name string `json:"name" yaml:"name"`
+
+	RefKind string `json:"ref_kind" yaml:"ref_kind"`
+	RefName string `json:"ref_name" yaml:"ref_name"`
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("%s %q of %s %q not found", e.RefKind, e.RefName, e.Kind, e.Name)
+}
+
+// EntityRef identifies an entity by its kind and name.
+type EntityRef struct {
+	Kind string `json:"kind" yaml:"kind"`
+	Name string `json:"name" yaml:"name"`
+}
+
+func (r EntityRef) String() string {
+	return fmt.Sprintf("%s %q", r.Kind, r.Name)
+}
+
+// DependentsError reports an entity that cannot be deleted, since there are
+// other entities still referring to it.
+type DependentsError struct {
+	Kind       string
+	Name       string
+	Dependents []EntityRef
+}
+
+func (e *DependentsError) Error() string {
+	var refs []string
+	for _, r := range e.Dependents {
+		refs = append(refs, r.String())
+	}
+	return fmt.Sprintf("%s %q is still referred to by %s", e.Kind, e.Name, strings.Join(refs, ", "))
+}
+
+// IntegrityError holds all the dangling references found in a configuration.
+type IntegrityError []*ReferenceError
+
+func (e IntegrityError) Error() string {
+	var msgs []string
+	for _, re := range e {
+		msgs = append(msgs, re.Error())
+	}
+	return "broken references: " + strings.Join(msgs, "; ")
+}
+
+// EntityError reports an error about the entity of the given kind and name.
+type EntityError struct {
+	Kind string
+	Name string
+	Err  error
+}
+
+func (e *EntityError) Error() string {
+	return fmt.Sprintf("%v: %q", e.Err, e.Name)
+}
+
+func (e *EntityError) Unwrap() error { return e.Err }
+
+// NotFoundError returns an error saying that the entity of the given kind
+// and name (or ID) does not exist.
+func NotFoundError(kind, name string) error {
+	var err error
+	switch kind {
+	case KindService:
+		err = ErrServiceNotFound
+	case KindRoute:
+		err = ErrRouteNotFound
+	case KindPlugin:
+		err = ErrPluginNotFound
+	case KindUpstream:
+		err = ErrUpstreamNotFound
+	default:
+		err = fmt.Errorf("%s not found", kind)
+	}
+	return &EntityError{Kind: kind, Name: name, Err: err}
+}
+
+// ExistsError returns an error saying that the entity of the given kind and
+// name already exists.
+func ExistsError(kind, name string) error {
+	var err error
+	switch kind {
+	case KindService:
+		err = ErrServiceExists
+	case KindRoute:
+		err = ErrRouteExists
+	case KindPlugin:
+		err = ErrPluginExists
+	default:
+		err = fmt.Errorf("%s already exists", kind)
+	}
+	return &EntityError{Kind: kind, Name: name, Err: err}
+}
+
+// FieldError describes why a field of an entity is invalid.
+type FieldError struct {
+	Field   string `json:"field" yaml:"field"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationError reports the invalid fields of an entity. It is also an
+// ErrInvalidOperation.
+type ValidationError struct {
+	Kind   string
+	Fields []*FieldError
+}
+
+// InvalidField returns a ValidationError with a single invalid field.
+func InvalidField(kind, field, message string) error {
+	return &ValidationError{
+		Kind:   kind,
+		Fields: []*FieldError{{Field: field, Message: message}},
+	}
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, fe := range e.Fields {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Kind, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() error { return ErrInvalidOperation }
@@ -0,0 +1,224 @@
+package olaf
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var ErrRevisionCompacted = errors.New("revision compacted")
+
+// Event types.
+const (
+	EventCreate = "create"
+	EventUpdate = "update"
+	EventDelete = "delete"
+)
+
+// Event describes a change of an entity.
+type Event struct {
+	// The revision of the config after the change. Revisions start from 1
+	// and increase by 1 for each event.
+	Revision int64  `json:"revision"`
+	Type     string `json:"type"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	// The entity after the change, or the deleted entity.
+	Entity interface{} `json:"entity"`
+}
+
+// Notifier is implemented by the stores that notify of changes.
+type Notifier interface {
+	// Revision returns the revision of the current config, which is 0 if
+	// no change has been made.
+	Revision() int64
+
+	// Watch returns a channel, which receives the events after the revision
+	// since, until ctx is done. If since is negative, only the new events are
+	// sent. If the events after since are no longer available, or since is
+	// beyond the current revision (e.g. the revisions have restarted since
+	// the receiver watched), ErrRevisionCompacted is returned.
+	//
+	// The channel is closed when ctx is done, or if the receiver can not
+	// keep up with the events, in which case it should watch again from the
+	// last received revision.
+	Watch(ctx context.Context, since int64) (<-chan *Event, error)
+}
+
+// EventLog is a Notifier keeping the most recent events in memory, which can
+// be embedded into stores.
+type EventLog struct {
+	size int
+
+	mu       sync.Mutex
+	revision int64
+	history  []*Event
+	watchers map[chan *Event]struct{}
+}
+
+// NewEventLog creates an EventLog, which keeps at most size events.
+func NewEventLog(size int) *EventLog {
+	return &EventLog{
+		size:     size,
+		watchers: make(map[chan *Event]struct{}),
+	}
+}
+
+func (l *EventLog) Revision() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.revision
+}
+
+// Publish assigns revisions to events in order, and sends them to the
+// watchers.
+func (l *EventLog) Publish(events ...*Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, e := range events {
+		l.revision++
+		e.Revision = l.revision
+
+		l.history = append(l.history, e)
+		if len(l.history) > l.size {
+			l.history = l.history[len(l.history)-l.size:]
+		}
+
+		for ch := range l.watchers {
+			select {
+			case ch <- e:
+			default:
+				// Drop the slow watcher.
+				delete(l.watchers, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+func (l *EventLog) Watch(ctx context.Context, since int64) (<-chan *Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if since > l.revision {
+		// The receiver has seen revisions that do not exist, so it must
+		// resync rather than skip the changes up to since.
+		return nil, ErrRevisionCompacted
+	}
+
+	var missed []*Event
+	if since >= 0 && since < l.revision {
+		oldest := l.revision - int64(len(l.history)) + 1
+		if since+1 < oldest {
+			return nil, ErrRevisionCompacted
+		}
+		missed = l.history[since+1-oldest:]
+	}
+
+	ch := make(chan *Event, len(missed)+64)
+	for _, e := range missed {
+		ch <- e
+	}
+	l.watchers[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.watchers[ch]; ok {
+			delete(l.watchers, ch)
+			close(ch)
+		}
+	}()
+
+	return ch, nil
+}
+
+// Diff returns the events that change old into new. The entities are matched
+// by their IDs, and an entity is considered updated if it has been replaced.
+//
+// The deletions come first, in the order of plugins, routes and services,
+// followed by the creations and updates in the reverse order, so that every
+// event refers to existing entities only.
+func Diff(old, new *Data) []*Event {
+	if old == nil {
+		old = &Data{}
+	}
+
+	deletions := make(map[string][]*Event)
+	var changes []*Event
+	diff := func(kind string, oldEntities, newEntities map[string]entity) {
+		var dels, chgs []*Event
+		for id, e := range oldEntities {
+			if _, ok := newEntities[id]; !ok {
+				dels = append(dels, &Event{Type: EventDelete, Kind: kind, Name: e.name(), Entity: e})
+			}
+		}
+		for id, e := range newEntities {
+			oldE, ok := oldEntities[id]
+			switch {
+			case !ok:
+				chgs = append(chgs, &Event{Type: EventCreate, Kind: kind, Name: e.name(), Entity: e})
+			case oldE != e:
+				chgs = append(chgs, &Event{Type: EventUpdate, Kind: kind, Name: e.name(), Entity: e})
+			}
+		}
+		sortEvents(dels)
+		sortEvents(chgs)
+		deletions[kind] = dels
+		changes = append(changes, chgs...)
+	}
+
+	diff(KindService, servicesByID(old), servicesByID(new))
+	diff(KindRoute, routesByID(old), routesByID(new))
+	diff(KindPlugin, pluginsByID(old), pluginsByID(new))
+
+	var events []*Event
+	for _, kind := range []string{KindPlugin, KindRoute, KindService} {
+		events = append(events, deletions[kind]...)
+	}
+	return append(events, changes...)
+}
+
+func sortEvents(events []*Event) {
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+}
+
+// entity is the common interface of the entities, for diffing purposes.
+type entity interface {
+	id() string
+	name() string
+}
+
+func (s *Service) id() string   { return s.ID }
+func (s *Service) name() string { return s.Name }
+func (r *Route) id() string     { return r.ID }
+func (r *Route) name() string   { return r.Name }
+func (p *Plugin) id() string    { return p.ID }
+func (p *Plugin) name() string  { return p.Name }
+
+func servicesByID(data *Data) map[string]entity {
+	m := make(map[string]entity)
+	for _, s := range data.Services {
+		m[s.ID] = s
+	}
+	return m
+}
+
+func routesByID(data *Data) map[string]entity {
+	m := make(map[string]entity)
+	for _, r := range data.Routes {
+		m[r.ID] = r
+	}
+	return m
+}
+
+func pluginsByID(data *Data) map[string]entity {
+	m := make(map[string]entity)
+	for _, p := range data.Plugins {
+		m[p.ID] = p
+	}
+	return m
+}
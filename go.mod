@@ -9,5 +9,7 @@ require (
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/go-kit/kit v0.10.0
 	github.com/mitchellh/mapstructure v1.1.2
+	github.com/prometheus/client_golang v1.11.0
+	golang.org/x/crypto v0.0.0-20210915214749-c084706c2272
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,40 @@
+package olaf
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+)
+
+// namespaceID is the UUID namespace for name-based entity IDs.
+var namespaceID = [16]byte{
+	0x6f, 0x6c, 0x61, 0x66, 0x2d, 0x65, 0x4e, 0x74,
+	0x9a, 0x1f, 0x3c, 0x2b, 0x5d, 0x08, 0x71, 0xe4,
+}
+
+// NewID generates a random (version 4) UUID.
+func NewID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(fmt.Errorf("failed to generate ID: %v", err))
+	}
+	return formatUUID(u, 4)
+}
+
+// NameID generates a name-based (version 5) UUID for the entity of the given
+// kind and name. The result is always the same for the same input.
+func NameID(kind, name string) string {
+	h := sha1.New()
+	h.Write(namespaceID[:])            // nolint:errcheck
+	h.Write([]byte(kind + ":" + name)) // nolint:errcheck
+
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+	return formatUUID(u, 5)
+}
+
+func formatUUID(u [16]byte, version byte) string {
+	u[6] = (u[6] & 0x0f) | version<<4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
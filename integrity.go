@@ -0,0 +1,181 @@
+package olaf
+
+import (
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// CheckIntegrity checks that every reference in data points to an existing
+// entity. All the dangling references, if any, are returned as an IntegrityError.
+func CheckIntegrity(data *Data) error {
+	var errs IntegrityError
+
+	for _, name := range SortedNames(data.Routes) {
+		r := data.Routes[name]
+		if _, ok := data.Services[r.ServiceName]; !ok {
+			errs = append(errs, &ReferenceError{
+				Kind:    KindRoute,
+				Name:    r.Name,
+				RefKind: KindService,
+				RefName: r.ServiceName,
+			})
+		}
+	}
+
+	for _, name := range SortedNames(data.Plugins) {
+		p := data.Plugins[name]
+		if p.ServiceName != "" {
+			if _, ok := data.Services[p.ServiceName]; !ok {
+				errs = append(errs, &ReferenceError{
+					Kind:    KindPlugin,
+					Name:    p.Name,
+					RefKind: KindService,
+					RefName: p.ServiceName,
+				})
+			}
+		}
+		if p.RouteName != "" {
+			if _, ok := data.Routes[p.RouteName]; !ok {
+				errs = append(errs, &ReferenceError{
+					Kind:    KindPlugin,
+					Name:    p.Name,
+					RefKind: KindRoute,
+					RefName: p.RouteName,
+				})
+			}
+		}
+		for _, upstream := range UpstreamServices(p) {
+			if _, ok := data.Services[upstream]; !ok {
+				errs = append(errs, &ReferenceError{
+					Kind:    KindPlugin,
+					Name:    p.Name,
+					RefKind: KindService,
+					RefName: upstream,
+				})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// FindDependents finds all the entities in data that refer to the entity
+// identified by kind and name, either directly or indirectly (e.g. the plugins
+// applied to a route of a service). The result is ordered by deletion, i.e.
+// routes go before their plugins.
+func FindDependents(data *Data, kind, name string) (refs []EntityRef) {
+	switch kind {
+	case KindService:
+		var routeNames []string
+		for _, rn := range SortedNames(data.Routes) {
+			if data.Routes[rn].ServiceName == name {
+				routeNames = append(routeNames, rn)
+				refs = append(refs, EntityRef{Kind: KindRoute, Name: rn})
+			}
+		}
+		for _, pn := range SortedNames(data.Plugins) {
+			p := data.Plugins[pn]
+			if p.ServiceName == name || containsString(UpstreamServices(p), name) || containsString(routeNames, p.RouteName) {
+				refs = append(refs, EntityRef{Kind: KindPlugin, Name: pn})
+			}
+		}
+	case KindRoute:
+		for _, pn := range SortedNames(data.Plugins) {
+			if data.Plugins[pn].RouteName == name {
+				refs = append(refs, EntityRef{Kind: KindPlugin, Name: pn})
+			}
+		}
+	}
+	return
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CanaryUpstream returns the name of the upstream service of p, if p is
+// a canary plugin. Otherwise, an empty string is returned.
+func CanaryUpstream(p *Plugin) string {
+	if p.Type != PluginTypeCanary {
+		return ""
+	}
+	config := new(PluginCanaryConfig)
+	if err := mapstructure.Decode(p.Config, config); err != nil {
+		return ""
+	}
+	return config.UpstreamServiceName
+}
+
+// MirrorUpstream returns the name of the upstream service of p, if p is
+// a mirror plugin. Otherwise, an empty string is returned.
+func MirrorUpstream(p *Plugin) string {
+	if p.Type != PluginTypeMirror {
+		return ""
+	}
+	config := new(PluginMirrorConfig)
+	if err := mapstructure.Decode(p.Config, config); err != nil {
+		return ""
+	}
+	return config.UpstreamServiceName
+}
+
+// TrafficSplitServices returns the names of the services of p, if p is
+// a traffic_split plugin. Otherwise, nil is returned.
+func TrafficSplitServices(p *Plugin) (names []string) {
+	if p.Type != PluginTypeTrafficSplit {
+		return nil
+	}
+	config := new(PluginTrafficSplitConfig)
+	if err := mapstructure.Decode(p.Config, config); err != nil {
+		return nil
+	}
+	for _, s := range config.Splits {
+		if s != nil && s.ServiceName != "" && !containsString(names, s.ServiceName) {
+			names = append(names, s.ServiceName)
+		}
+	}
+	return names
+}
+
+// UpstreamServices returns the names of the services, which p proxies
+// (or mirrors) requests to, if p is a built-in plugin (i.e. canary,
+// traffic_split or mirror).
+func UpstreamServices(p *Plugin) []string {
+	if upstream := CanaryUpstream(p); upstream != "" {
+		return []string{upstream}
+	}
+	if upstream := MirrorUpstream(p); upstream != "" {
+		return []string{upstream}
+	}
+	return TrafficSplitServices(p)
+}
+
+// SortedNames returns the names of the entities in m, which is a map of
+// services, routes or plugins, in sorted order.
+func SortedNames(m interface{}) (keys []string) {
+	switch m := m.(type) {
+	case map[string]*Service:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*Route:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*Plugin:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
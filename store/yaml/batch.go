@@ -0,0 +1,369 @@
+package yaml
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RussellLuo/olaf"
+)
+
+// now returns the current Unix time. It is a variable for testing purposes.
+var now = func() int64 { return time.Now().Unix() }
+
+// Batch applies ops in order as a single transaction. Either all of them are
+// applied, or none is if any operation fails or the resulting config has
+// broken references.
+func (s *Store) Batch(ctx context.Context, ops []*olaf.Operation) (err error) {
+	return s.transact(func(data *olaf.Data) error {
+		for i, op := range ops {
+			if err := apply(data, op); err != nil {
+				return &olaf.OperationError{Index: i, Op: op, Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+// transact calls f with a copy of the current config, which will take the
+// place of the current one only if f succeeds and the changed config has no
+// broken references. The changes are then published to the watchers.
+func (s *Store) transact(f func(data *olaf.Data) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Changes can not be made on top of a config that has failed to load,
+	// since they would be discarded by the next reload anyway.
+	if err := s.loaded(); err != nil {
+		return err
+	}
+
+	data := copyData(s.data)
+	if err := f(data); err != nil {
+		return err
+	}
+
+	if err := olaf.CheckIntegrity(data); err != nil {
+		return err
+	}
+
+	s.events.Publish(olaf.Diff(s.data, data)...)
+	s.data = data
+	return nil
+}
+
+// copyData makes a copy of data, whose maps can be changed without affecting
+// the original ones. The entities are shared, so they must be replaced rather
+// than modified in place.
+func copyData(data *olaf.Data) *olaf.Data {
+	c := &olaf.Data{
+		Services: make(map[string]*olaf.Service),
+		Routes:   make(map[string]*olaf.Route),
+		Plugins:  make(map[string]*olaf.Plugin),
+	}
+	if data == nil {
+		return c
+	}
+
+	c.Version = data.Version
+	for k, v := range data.Services {
+		c.Services[k] = v
+	}
+	for k, v := range data.Routes {
+		c.Routes[k] = v
+	}
+	for k, v := range data.Plugins {
+		c.Plugins[k] = v
+	}
+	return c
+}
+
+func apply(data *olaf.Data, op *olaf.Operation) error {
+	switch op.Kind {
+	case olaf.KindService:
+		return applyService(data, op)
+	case olaf.KindRoute:
+		return applyRoute(data, op)
+	case olaf.KindPlugin:
+		return applyPlugin(data, op)
+	default:
+		return fmt.Errorf("%w: unknown kind %q", olaf.ErrInvalidOperation, op.Kind)
+	}
+}
+
+func applyService(data *olaf.Data, op *olaf.Operation) error {
+	name := resolveName(data, olaf.KindService, op.Name)
+
+	switch op.Op {
+	case olaf.OpCreate:
+		if op.Service == nil || op.Service.Name == "" {
+			return olaf.InvalidField(olaf.KindService, "name", "is required")
+		}
+		if _, ok := data.Services[op.Service.Name]; ok {
+			return olaf.ExistsError(olaf.KindService, op.Service.Name)
+		}
+		svc := *op.Service
+		if err := newIdentity(data, olaf.KindService, &svc.ID); err != nil {
+			return err
+		}
+		svc.CreatedAt, svc.UpdatedAt = now(), now()
+		if err := stampUpstream(data, &svc, nil); err != nil {
+			return err
+		}
+		data.Services[svc.Name] = &svc
+
+	case olaf.OpUpdate:
+		if op.Service == nil {
+			return fmt.Errorf("%w: service is required", olaf.ErrInvalidOperation)
+		}
+		old, ok := data.Services[name]
+		if !ok {
+			return olaf.NotFoundError(olaf.KindService, op.Name)
+		}
+		if op.Service.Name != "" && op.Service.Name != name {
+			return olaf.InvalidField(olaf.KindService, "name", "cannot be changed")
+		}
+		if err := checkIdentity(olaf.KindService, op.Service.ID, old.ID); err != nil {
+			return err
+		}
+		svc := *op.Service
+		svc.Name = name
+		svc.ID, svc.CreatedAt, svc.UpdatedAt = old.ID, old.CreatedAt, now()
+		if err := stampUpstream(data, &svc, old); err != nil {
+			return err
+		}
+		data.Services[svc.Name] = &svc
+
+	case olaf.OpDelete:
+		if _, ok := data.Services[name]; !ok {
+			return olaf.NotFoundError(olaf.KindService, op.Name)
+		}
+		if err := deleteDependents(data, olaf.KindService, name, op.Cascade); err != nil {
+			return err
+		}
+		delete(data.Services, name)
+
+	case olaf.OpRename:
+		_, err := renameService(data, name, op.NewName)
+		return err
+
+	default:
+		return fmt.Errorf("%w: unknown op %q", olaf.ErrInvalidOperation, op.Op)
+	}
+	return nil
+}
+
+func applyRoute(data *olaf.Data, op *olaf.Operation) error {
+	name := resolveName(data, olaf.KindRoute, op.Name)
+
+	switch op.Op {
+	case olaf.OpCreate:
+		if op.Route == nil || op.Route.Name == "" {
+			return olaf.InvalidField(olaf.KindRoute, "name", "is required")
+		}
+		if _, ok := data.Routes[op.Route.Name]; ok {
+			return olaf.ExistsError(olaf.KindRoute, op.Route.Name)
+		}
+		r := *op.Route
+		if err := newIdentity(data, olaf.KindRoute, &r.ID); err != nil {
+			return err
+		}
+		r.ServiceName = resolveName(data, olaf.KindService, r.ServiceName)
+		r.CreatedAt, r.UpdatedAt = now(), now()
+		data.Routes[r.Name] = &r
+
+	case olaf.OpUpdate:
+		if op.Route == nil {
+			return fmt.Errorf("%w: route is required", olaf.ErrInvalidOperation)
+		}
+		old, ok := data.Routes[name]
+		if !ok {
+			return olaf.NotFoundError(olaf.KindRoute, op.Name)
+		}
+		if op.Route.Name != "" && op.Route.Name != name {
+			return olaf.InvalidField(olaf.KindRoute, "name", "cannot be changed")
+		}
+		if err := checkIdentity(olaf.KindRoute, op.Route.ID, old.ID); err != nil {
+			return err
+		}
+		r := *op.Route
+		r.Name = name
+		r.ID, r.CreatedAt, r.UpdatedAt = old.ID, old.CreatedAt, now()
+		if r.ServiceName == "" {
+			r.ServiceName = old.ServiceName
+		}
+		r.ServiceName = resolveName(data, olaf.KindService, r.ServiceName)
+		data.Routes[r.Name] = &r
+
+	case olaf.OpDelete:
+		if _, ok := data.Routes[name]; !ok {
+			return olaf.NotFoundError(olaf.KindRoute, op.Name)
+		}
+		if err := deleteDependents(data, olaf.KindRoute, name, op.Cascade); err != nil {
+			return err
+		}
+		delete(data.Routes, name)
+
+	case olaf.OpRename:
+		_, err := renameRoute(data, name, op.NewName)
+		return err
+
+	default:
+		return fmt.Errorf("%w: unknown op %q", olaf.ErrInvalidOperation, op.Op)
+	}
+	return nil
+}
+
+func applyPlugin(data *olaf.Data, op *olaf.Operation) error {
+	name := resolveName(data, olaf.KindPlugin, op.Name)
+
+	switch op.Op {
+	case olaf.OpCreate:
+		if op.Plugin == nil || op.Plugin.Type == "" {
+			return olaf.InvalidField(olaf.KindPlugin, "type", "is required")
+		}
+		p := *op.Plugin
+		p.ServiceName = resolveName(data, olaf.KindService, p.ServiceName)
+		p.RouteName = resolveName(data, olaf.KindRoute, p.RouteName)
+		if p.Name == "" {
+			p.Name = newPluginName(data, &p)
+			// Tell the caller the generated name.
+			op.Plugin.Name = p.Name
+		}
+		if _, ok := data.Plugins[p.Name]; ok {
+			return olaf.ExistsError(olaf.KindPlugin, p.Name)
+		}
+		if err := newIdentity(data, olaf.KindPlugin, &p.ID); err != nil {
+			return err
+		}
+		p.CreatedAt, p.UpdatedAt = now(), now()
+		data.Plugins[p.Name] = &p
+
+	case olaf.OpUpdate:
+		if op.Plugin == nil {
+			return fmt.Errorf("%w: plugin is required", olaf.ErrInvalidOperation)
+		}
+		old, ok := data.Plugins[name]
+		if !ok {
+			return olaf.NotFoundError(olaf.KindPlugin, op.Name)
+		}
+		if op.Plugin.Name != "" && op.Plugin.Name != name {
+			return olaf.InvalidField(olaf.KindPlugin, "name", "cannot be changed")
+		}
+		if err := checkIdentity(olaf.KindPlugin, op.Plugin.ID, old.ID); err != nil {
+			return err
+		}
+		p := *op.Plugin
+		p.Name = name
+		p.ID, p.CreatedAt, p.UpdatedAt = old.ID, old.CreatedAt, now()
+		// Keep the scope of the plugin, if not specified.
+		if p.ServiceName == "" && p.RouteName == "" {
+			p.ServiceName, p.RouteName = old.ServiceName, old.RouteName
+		}
+		p.ServiceName = resolveName(data, olaf.KindService, p.ServiceName)
+		p.RouteName = resolveName(data, olaf.KindRoute, p.RouteName)
+		data.Plugins[p.Name] = &p
+
+	case olaf.OpDelete:
+		if _, ok := data.Plugins[name]; !ok {
+			return olaf.NotFoundError(olaf.KindPlugin, op.Name)
+		}
+		delete(data.Plugins, name)
+
+	default:
+		return fmt.Errorf("%w: unknown op %q", olaf.ErrInvalidOperation, op.Op)
+	}
+	return nil
+}
+
+// newIdentity generates an ID for a new entity of the given kind if *id is
+// empty. Otherwise, the given ID is checked to be unique.
+func newIdentity(data *olaf.Data, kind string, id *string) error {
+	if *id == "" {
+		*id = olaf.NewID()
+		return nil
+	}
+	if idTaken(data, kind, *id) {
+		return olaf.InvalidField(kind, "id", fmt.Sprintf("%q is already in use", *id))
+	}
+	return nil
+}
+
+// checkIdentity ensures that the ID of an existing entity is not changed.
+func checkIdentity(kind, id, oldID string) error {
+	if id != "" && id != oldID {
+		return olaf.InvalidField(kind, "id", "cannot be changed")
+	}
+	return nil
+}
+
+// stampUpstream sets the ID and timestamps of the upstream of svc, which are
+// inherited from the upstream of old (if any).
+func stampUpstream(data *olaf.Data, svc, old *olaf.Service) error {
+	if svc.Upstream == nil {
+		return nil
+	}
+
+	u := *svc.Upstream
+	if old != nil && old.Upstream != nil {
+		if err := checkIdentity(olaf.KindUpstream, u.ID, old.Upstream.ID); err != nil {
+			return err
+		}
+		u.ID, u.CreatedAt = old.Upstream.ID, old.Upstream.CreatedAt
+	} else {
+		if err := newIdentity(data, olaf.KindUpstream, &u.ID); err != nil {
+			return err
+		}
+		u.CreatedAt = now()
+	}
+	u.UpdatedAt = now()
+
+	svc.Upstream = &u
+	return nil
+}
+
+// deleteDependents deletes all the dependents of the given entity if cascade
+// is true. Otherwise, a DependentsError is returned if there is any dependent.
+func deleteDependents(data *olaf.Data, kind, name string, cascade bool) error {
+	refs := olaf.FindDependents(data, kind, name)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	if !cascade {
+		return &olaf.DependentsError{
+			Kind:       kind,
+			Name:       name,
+			Dependents: refs,
+		}
+	}
+
+	for _, ref := range refs {
+		switch ref.Kind {
+		case olaf.KindRoute:
+			delete(data.Routes, ref.Name)
+		case olaf.KindPlugin:
+			delete(data.Plugins, ref.Name)
+		}
+	}
+	return nil
+}
+
+// newPluginName generates a unique name for p, following the same naming
+// convention as Parse.
+func newPluginName(data *olaf.Data, p *olaf.Plugin) string {
+	prefix := "plugin"
+	switch {
+	case p.RouteName != "":
+		prefix = p.RouteName + "_plugin"
+	case p.ServiceName != "":
+		prefix = p.ServiceName + "_plugin"
+	}
+
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("%s_%d", prefix, i)
+		if _, ok := data.Plugins[name]; !ok {
+			return name
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package yaml
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+const testConfig = `
+services:
+- name: production
+  upstream:
+    backends: ["localhost:2222"]
+  routes:
+  - name: foo
+    paths:
+    - /foo
+- name: staging
+  upstream:
+    backends: ["localhost:3333"]
+`
+
+func newTestStore(t *testing.T) *Store {
+	data, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return &Store{data: data, events: olaf.NewEventLog(eventLogSize)}
+}
+
+func TestStore_Batch(t *testing.T) {
+	upstream := &olaf.Upstream{
+		Backends: []*olaf.Backend{{Dial: "localhost:4444"}},
+	}
+
+	cases := []struct {
+		name         string
+		inOps        []*olaf.Operation
+		wantErr      error
+		wantServices []string
+		wantRoutes   []string
+		wantPlugins  []string
+	}{
+		{
+			name: "create a service along with its route and plugin",
+			inOps: []*olaf.Operation{
+				{
+					Op:      olaf.OpCreate,
+					Kind:    olaf.KindService,
+					Service: &olaf.Service{Name: "canary", Upstream: upstream},
+				},
+				{
+					Op:    olaf.OpCreate,
+					Kind:  olaf.KindRoute,
+					Route: &olaf.Route{Name: "bar", ServiceName: "production"},
+				},
+				{
+					Op:   olaf.OpCreate,
+					Kind: olaf.KindPlugin,
+					Plugin: &olaf.Plugin{
+						Type:      olaf.PluginTypeCanary,
+						RouteName: "bar",
+						Config:    map[string]interface{}{"upstream": "canary"},
+					},
+				},
+			},
+			wantServices: []string{"canary", "production", "staging"},
+			wantRoutes:   []string{"bar", "foo"},
+			wantPlugins:  []string{"bar_plugin_0"},
+		},
+		{
+			name: "broken reference",
+			inOps: []*olaf.Operation{
+				{
+					Op:    olaf.OpCreate,
+					Kind:  olaf.KindRoute,
+					Route: &olaf.Route{Name: "bar", ServiceName: "production"},
+				},
+				{
+					Op:   olaf.OpCreate,
+					Kind: olaf.KindPlugin,
+					Plugin: &olaf.Plugin{
+						Type:      olaf.PluginTypeCanary,
+						RouteName: "bar",
+						Config:    map[string]interface{}{"upstream": "canary"},
+					},
+				},
+			},
+			wantErr:      olaf.IntegrityError{},
+			wantServices: []string{"production", "staging"},
+			wantRoutes:   []string{"foo"},
+		},
+		{
+			name: "failed operation",
+			inOps: []*olaf.Operation{
+				{
+					Op:   olaf.OpDelete,
+					Kind: olaf.KindRoute,
+					Name: "foo",
+				},
+				{
+					Op:      olaf.OpCreate,
+					Kind:    olaf.KindService,
+					Service: &olaf.Service{Name: "staging", Upstream: upstream},
+				},
+			},
+			wantErr:      olaf.ErrServiceExists,
+			wantServices: []string{"production", "staging"},
+			wantRoutes:   []string{"foo"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestStore(t)
+
+			err := s.Batch(context.Background(), c.inOps)
+			switch want := c.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("Err: got (%v), want (nil)", err)
+				}
+			case olaf.IntegrityError:
+				if !errors.As(err, &want) {
+					t.Fatalf("Err: got (%v), want (%T)", err, want)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("Err: got (%v), want (%v)", err, want)
+				}
+			}
+
+			assertNames(t, "Services", olaf.SortedNames(s.data.Services), c.wantServices)
+			assertNames(t, "Routes", olaf.SortedNames(s.data.Routes), c.wantRoutes)
+			assertNames(t, "Plugins", olaf.SortedNames(s.data.Plugins), c.wantPlugins)
+		})
+	}
+}
+
+func assertNames(t *testing.T, kind string, got, want []string) {
+	if len(got) != len(want) {
+		t.Fatalf("%s: got (%v), want (%v)", kind, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got (%v), want (%v)", kind, got, want)
+		}
+	}
+}
+
+func TestStore_DeleteService(t *testing.T) {
+	plugin := &olaf.Plugin{
+		Name:      "foo_canary",
+		Type:      olaf.PluginTypeCanary,
+		RouteName: "foo",
+		Config:    map[string]interface{}{"upstream": "staging"},
+	}
+
+	cases := []struct {
+		name          string
+		inServiceName string
+		inCascade     bool
+		wantErr       *olaf.DependentsError
+		wantServices  []string
+		wantRoutes    []string
+		wantPlugins   []string
+	}{
+		{
+			name:          "referred to by a canary plugin",
+			inServiceName: "staging",
+			wantErr: &olaf.DependentsError{
+				Kind: olaf.KindService,
+				Name: "staging",
+				Dependents: []olaf.EntityRef{
+					{Kind: olaf.KindPlugin, Name: "foo_canary"},
+				},
+			},
+			wantServices: []string{"production", "staging"},
+			wantRoutes:   []string{"foo"},
+			wantPlugins:  []string{"foo_canary"},
+		},
+		{
+			name:          "cascade to the canary plugin",
+			inServiceName: "staging",
+			inCascade:     true,
+			wantServices:  []string{"production"},
+			wantRoutes:    []string{"foo"},
+		},
+		{
+			name:          "cascade to routes and their plugins",
+			inServiceName: "production",
+			inCascade:     true,
+			wantServices:  []string{"staging"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestStore(t)
+			if _, err := s.CreatePlugin(context.Background(), "", "", plugin); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			err := s.DeleteService(context.Background(), c.inServiceName, "", c.inCascade)
+			if c.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Err: got (%v), want (nil)", err)
+				}
+			} else {
+				var depErr *olaf.DependentsError
+				if !errors.As(err, &depErr) || !reflect.DeepEqual(depErr, c.wantErr) {
+					t.Fatalf("Err: got (%#v), want (%#v)", err, c.wantErr)
+				}
+			}
+
+			assertNames(t, "Services", olaf.SortedNames(s.data.Services), c.wantServices)
+			assertNames(t, "Routes", olaf.SortedNames(s.data.Routes), c.wantRoutes)
+			assertNames(t, "Plugins", olaf.SortedNames(s.data.Plugins), c.wantPlugins)
+		})
+	}
+}
+
+func TestStore_UpdatePlugin(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	p, err := s.CreatePlugin(ctx, "", "", &olaf.Plugin{Type: "rate_limit", RouteName: "foo"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The update body leaves the scope of the plugin empty.
+	if err := s.UpdatePlugin(ctx, "", "", p.Name, &olaf.Plugin{Type: "rate_limit", Config: map[string]interface{}{"rate": "10r/s"}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if got := s.data.Plugins[p.Name]; got.RouteName != "foo" {
+		t.Fatalf("RouteName: got (%q), want (%q)", got.RouteName, "foo")
+	}
+}
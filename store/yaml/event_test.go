@@ -0,0 +1,71 @@
+package yaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+func TestStore_Watch(t *testing.T) {
+	s := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := s.Watch(ctx, -1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	err = s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpDelete, Kind: olaf.KindService, Name: "staging"},
+		{Op: olaf.OpCreate, Kind: olaf.KindRoute, Route: &olaf.Route{
+			Name:        "bar",
+			ServiceName: "production",
+			Matcher:     olaf.Matcher{Paths: []string{"/bar"}},
+		}},
+		{Op: olaf.OpUpdate, Kind: olaf.KindRoute, Name: "foo", Route: &olaf.Route{
+			ServiceName: "production",
+			Matcher:     olaf.Matcher{Paths: []string{"/foo2"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	want := []string{
+		"1 delete service staging",
+		"2 create route bar",
+		"3 update route foo",
+	}
+	var got []string
+	for range want {
+		e := <-ch
+		got = append(got, fmt.Sprintf("%d %s %s %s", e.Revision, e.Type, e.Kind, e.Name))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Events: got (%v), want (%v)", got, want)
+	}
+	if rev := s.Revision(); rev != 3 {
+		t.Fatalf("Revision: got (%d), want (3)", rev)
+	}
+
+	// Resume from revision 1.
+	ch, err = s.Watch(ctx, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if e := <-ch; e.Revision != 2 {
+		t.Fatalf("Revision: got (%d), want (2)", e.Revision)
+	}
+
+	// Resume from a compacted revision.
+	s.events = olaf.NewEventLog(1)
+	s.events.Publish(&olaf.Event{}, &olaf.Event{})
+	if _, err := s.Watch(ctx, 0); !errors.Is(err, olaf.ErrRevisionCompacted) {
+		t.Fatalf("Err: got (%v), want (%v)", err, olaf.ErrRevisionCompacted)
+	}
+}
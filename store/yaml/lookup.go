@@ -0,0 +1,102 @@
+package yaml
+
+import (
+	"github.com/RussellLuo/olaf"
+)
+
+// lookupService finds the service by its name or ID.
+func lookupService(data *olaf.Data, key string) (*olaf.Service, bool) {
+	if svc, ok := data.Services[key]; ok {
+		return svc, true
+	}
+	for _, svc := range data.Services {
+		if svc.ID == key {
+			return svc, true
+		}
+	}
+	return nil, false
+}
+
+// lookupRoute finds the route by its name or ID.
+func lookupRoute(data *olaf.Data, key string) (*olaf.Route, bool) {
+	if r, ok := data.Routes[key]; ok {
+		return r, true
+	}
+	for _, r := range data.Routes {
+		if r.ID == key {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// lookupPlugin finds the plugin by its name or ID.
+func lookupPlugin(data *olaf.Data, key string) (*olaf.Plugin, bool) {
+	if p, ok := data.Plugins[key]; ok {
+		return p, true
+	}
+	for _, p := range data.Plugins {
+		if p.ID == key {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// lookupUpstream finds the service, whose upstream has the given ID.
+func lookupUpstream(data *olaf.Data, id string) (*olaf.Service, bool) {
+	for _, svc := range data.Services {
+		if svc.Upstream != nil && svc.Upstream.ID == id {
+			return svc, true
+		}
+	}
+	return nil, false
+}
+
+// idTaken reports whether id has been used by any entity of the given kind.
+func idTaken(data *olaf.Data, kind, id string) bool {
+	switch kind {
+	case olaf.KindService:
+		for _, svc := range data.Services {
+			if svc.ID == id {
+				return true
+			}
+		}
+	case olaf.KindRoute:
+		for _, r := range data.Routes {
+			if r.ID == id {
+				return true
+			}
+		}
+	case olaf.KindPlugin:
+		for _, p := range data.Plugins {
+			if p.ID == id {
+				return true
+			}
+		}
+	case olaf.KindUpstream:
+		_, ok := lookupUpstream(data, id)
+		return ok
+	}
+	return false
+}
+
+// resolveName returns the name of the entity identified by key, which is
+// either the name or the ID. If not found, key is returned as is.
+func resolveName(data *olaf.Data, kind, key string) string {
+	switch kind {
+	case olaf.KindService:
+		if svc, ok := lookupService(data, key); ok {
+			return svc.Name
+		}
+	case olaf.KindRoute:
+		if r, ok := lookupRoute(data, key); ok {
+			return r.Name
+		}
+	case olaf.KindPlugin:
+		if p, ok := lookupPlugin(data, key); ok {
+			return p.Name
+		}
+	}
+	return key
+}
@@ -0,0 +1,211 @@
+package yaml
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+func TestParse_IDs(t *testing.T) {
+	data1, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	data2, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	svc1, svc2 := data1.Services["production"], data2.Services["production"]
+	if svc1.ID == "" || svc1.ID != svc2.ID {
+		t.Fatalf("Service ID: got (%q, %q), want the same non-empty IDs", svc1.ID, svc2.ID)
+	}
+	if svc1.Upstream.ID == "" || svc1.Upstream.ID != svc2.Upstream.ID {
+		t.Fatalf("Upstream ID: got (%q, %q), want the same non-empty IDs", svc1.Upstream.ID, svc2.Upstream.ID)
+	}
+	if r1, r2 := data1.Routes["foo"], data2.Routes["foo"]; r1.ID == "" || r1.ID != r2.ID {
+		t.Fatalf("Route ID: got (%q, %q), want the same non-empty IDs", r1.ID, r2.ID)
+	}
+	if svc1.ID == data1.Services["staging"].ID {
+		t.Fatalf("Service ID: got duplicate ID %q", svc1.ID)
+	}
+}
+
+func TestParse_UnnamedIDs(t *testing.T) {
+	// ids returns the paths of the routes, and the types of the plugins, by
+	// their IDs.
+	ids := func(config string) (routes, plugins map[string]string) {
+		data, err := Parse([]byte(config))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		routes, plugins = make(map[string]string), make(map[string]string)
+		for _, r := range data.Routes {
+			routes[r.ID] = r.Paths[0]
+		}
+		for _, p := range data.Plugins {
+			plugins[p.ID] = p.Type
+		}
+		return routes, plugins
+	}
+
+	routes1, plugins1 := ids(`
+services:
+- name: production
+  routes:
+  - paths: [/foo]
+    plugins:
+    - type: rate_limit
+  - paths: [/baz]
+  plugins:
+  - type: rate_limit
+`)
+	// Insert a route and a plugin before the existing ones.
+	routes2, plugins2 := ids(`
+services:
+- name: production
+  routes:
+  - paths: [/bar]
+    plugins:
+    - type: canary
+  - paths: [/foo]
+    plugins:
+    - type: rate_limit
+  - paths: [/baz]
+  plugins:
+  - type: canary
+  - type: rate_limit
+`)
+
+	for id, path := range routes1 {
+		if routes2[id] != path {
+			t.Fatalf("Route %q: got (%q), want (%q)", id, routes2[id], path)
+		}
+	}
+	for id, typ := range plugins1 {
+		if plugins2[id] != typ {
+			t.Fatalf("Plugin %q: got (%q), want (%q)", id, plugins2[id], typ)
+		}
+	}
+}
+
+func TestParse_UnnamedServiceIDs(t *testing.T) {
+	// ids returns the descriptions of the entities by their IDs.
+	ids := func(config string) map[string]string {
+		data, err := Parse([]byte(config))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		m := make(map[string]string)
+		for _, s := range data.Services {
+			m[s.ID] = "service " + s.Upstream.Backends[0].Dial
+			m[s.Upstream.ID] = "upstream " + s.Upstream.Backends[0].Dial
+		}
+		for _, r := range data.Routes {
+			m[r.ID] = "route " + r.Paths[0]
+		}
+		for _, p := range data.Plugins {
+			m[p.ID] = "plugin " + p.Type + " of " + p.RouteName
+		}
+		return m
+	}
+
+	ids1 := ids(`
+services:
+- upstream:
+    backends: ["localhost:2222"]
+  routes:
+  - paths: [/foo]
+    plugins:
+    - type: rate_limit
+- upstream:
+    backends: ["localhost:3333"]
+  routes:
+  - paths: [/baz]
+`)
+	// Insert a service between the existing ones.
+	ids2 := ids(`
+services:
+- upstream:
+    backends: ["localhost:2222"]
+  routes:
+  - paths: [/foo]
+    plugins:
+    - type: rate_limit
+- upstream:
+    backends: ["localhost:4444"]
+  routes:
+  - paths: [/bar]
+- upstream:
+    backends: ["localhost:3333"]
+  routes:
+  - paths: [/baz]
+`)
+
+	for id, desc := range ids1 {
+		if ids2[id] != desc {
+			t.Fatalf("Entity %q: got (%q), want (%q)", id, ids2[id], desc)
+		}
+	}
+}
+
+func TestStore_LookupByID(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	svc := s.data.Services["production"]
+	route := s.data.Routes["foo"]
+
+	gotSvc, err := s.GetService(ctx, svc.ID, "")
+	if err != nil || gotSvc != svc {
+		t.Fatalf("GetService: got (%v, %v), want (%v, <nil>)", gotSvc, err, svc)
+	}
+
+	gotRoute, err := s.GetRoute(ctx, svc.ID, route.ID)
+	if err != nil || gotRoute != route {
+		t.Fatalf("GetRoute: got (%v, %v), want (%v, <nil>)", gotRoute, err, route)
+	}
+
+	gotUpstream, err := s.GetUpstream(ctx, svc.Upstream.ID, "")
+	if err != nil || gotUpstream != svc.Upstream {
+		t.Fatalf("GetUpstream: got (%v, %v), want (%v, <nil>)", gotUpstream, err, svc.Upstream)
+	}
+
+	if err := s.DeleteRoute(ctx, "", route.ID, false); err != nil {
+		t.Fatalf("DeleteRoute: got (%v), want (<nil>)", err)
+	}
+	if _, err := s.GetRoute(ctx, "", "foo"); !errors.Is(err, olaf.ErrRouteNotFound) {
+		t.Fatalf("GetRoute: got (%v), want (%v)", err, olaf.ErrRouteNotFound)
+	}
+}
+
+func TestStore_UpdateIdentity(t *testing.T) {
+	defer func(f func() int64) { now = f }(now)
+	now = func() int64 { return 100 }
+
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.CreateRoute(ctx, "staging", &olaf.Route{Name: "bar"}); err != nil {
+		t.Fatalf("CreateRoute: got (%v), want (<nil>)", err)
+	}
+	old, _ := s.GetRoute(ctx, "", "bar")
+	if old.ID == "" || old.CreatedAt != 100 || old.UpdatedAt != 100 {
+		t.Fatalf("Route: got (%+v), want an ID and timestamps of 100", old)
+	}
+
+	now = func() int64 { return 200 }
+	if err := s.UpdateRoute(ctx, "", old.ID, &olaf.Route{Matcher: olaf.Matcher{Paths: []string{"/bar"}}}); err != nil {
+		t.Fatalf("UpdateRoute: got (%v), want (<nil>)", err)
+	}
+	got, _ := s.GetRoute(ctx, "", "bar")
+	if got.ID != old.ID || got.CreatedAt != 100 || got.UpdatedAt != 200 || got.ServiceName != "staging" {
+		t.Fatalf("Route: got (%+v), want ID %q and timestamps of (100, 200)", got, old.ID)
+	}
+
+	err := s.UpdateRoute(ctx, "", "bar", &olaf.Route{ID: "other"})
+	if !errors.Is(err, olaf.ErrInvalidOperation) {
+		t.Fatalf("UpdateRoute: got (%v), want (%v)", err, olaf.ErrInvalidOperation)
+	}
+}
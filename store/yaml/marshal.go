@@ -0,0 +1,170 @@
+package yaml
+
+import (
+	"bytes"
+
+	"github.com/RussellLuo/olaf"
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal encodes data in the declarative layout recognized by Parse, where
+// routes and plugins are nested within the services (and routes) they belong
+// to. Saving the output and loading it again (see Parse) will result in the
+// same output.
+//
+// Since there is only one max_requests per upstream in the declarative layout,
+// the one of the first backend is used.
+func Marshal(data *olaf.Data) ([]byte, error) {
+	c := new(content)
+
+	for _, name := range olaf.SortedNames(data.Services) {
+		svc := data.Services[name]
+		s := &service{
+			ID:        svc.ID,
+			Name:      svc.Name,
+			Upstream:  marshalUpstream(svc.Upstream),
+			Tags:      svc.Tags,
+			CreatedAt: svc.CreatedAt,
+			UpdatedAt: svc.UpdatedAt,
+		}
+
+		for _, name := range olaf.SortedNames(data.Routes) {
+			r := data.Routes[name]
+			if r.ServiceName != svc.Name {
+				continue
+			}
+			newR := *r
+			newR.ServiceName = "" // implied by the nesting
+			s.Routes = append(s.Routes, &route{
+				Route: &newR,
+				Plugins: marshalPlugins(data, func(p *olaf.Plugin) bool {
+					return p.RouteName == r.Name
+				}),
+			})
+		}
+
+		s.Plugins = marshalPlugins(data, func(p *olaf.Plugin) bool {
+			return p.RouteName == "" && p.ServiceName == svc.Name
+		})
+
+		c.Services = append(c.Services, s)
+	}
+
+	c.Plugins = marshalPlugins(data, func(p *olaf.Plugin) bool {
+		return p.RouteName == "" && p.ServiceName == ""
+	})
+
+	var node yaml.Node
+	if err := node.Encode(c); err != nil {
+		return nil, err
+	}
+	prune(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalUpstream(u *olaf.Upstream) *upstream {
+	if u == nil {
+		return nil
+	}
+
+	out := &upstream{
+		ID:         u.ID,
+		HeaderUp:   u.HeaderUp,
+		HeaderDown: u.HeaderDown,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+	}
+	for i, b := range u.Backends {
+		out.Backends = append(out.Backends, b.Dial)
+		if i == 0 {
+			out.MaxRequests = b.MaxRequests
+		}
+	}
+	if u.HTTP != nil {
+		out.DialTimeout = u.HTTP.DialTimeout
+	}
+	if lb := u.LoadBalancing; lb != nil {
+		out.LBPolicy = lb.Policy
+		out.LBTryDuration = lb.TryDuration
+		out.LBTryInterval = lb.Interval
+	}
+	if hc := u.ActiveHealthChecks; hc != nil {
+		out.HealthURI = hc.URI
+		out.HealthPort = hc.Port
+		out.HealthInterval = hc.Interval
+		out.HealthTimeout = hc.Timeout
+		out.HealthStatus = hc.StatusCode
+	}
+	return out
+}
+
+// marshalPlugins returns the plugins selected by f, without the references
+// implied by the nesting.
+func marshalPlugins(data *olaf.Data, f func(p *olaf.Plugin) bool) (plugins []*olaf.Plugin) {
+	for _, name := range olaf.SortedNames(data.Plugins) {
+		p := data.Plugins[name]
+		if !f(p) {
+			continue
+		}
+		newP := *p
+		newP.ServiceName, newP.RouteName = "", ""
+		plugins = append(plugins, &newP)
+	}
+	return plugins
+}
+
+// prune removes the fields, whose values are null, zero or empty, from the
+// mappings within node. The plugin configs are left as is.
+//
+// Collections are removed only if they are empty before pruning, so that an
+// empty struct (e.g. a static response using all the defaults) is kept.
+func prune(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, n := range node.Content {
+			prune(n)
+		}
+	case yaml.MappingNode:
+		var content []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if isZero(value) {
+				continue
+			}
+			if key.Value != "config" {
+				prune(value)
+			}
+			content = append(content, key, value)
+		}
+		node.Content = content
+	}
+}
+
+func isZero(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!null":
+			return true
+		case "!!str":
+			return node.Value == ""
+		case "!!int", "!!float":
+			return node.Value == "0"
+		case "!!bool":
+			return node.Value == "false"
+		}
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(node.Content) == 0
+	}
+	return false
+}
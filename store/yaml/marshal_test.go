@@ -0,0 +1,118 @@
+package yaml
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+const testMarshalConfig = `
+services:
+- name: static
+  routes:
+  - methods: [GET]
+    paths: [/health-check]
+    response:
+      status_code: 200
+  - priority: -.inf
+    response:
+      status_code: 404
+- name: production
+  tags: [team-a]
+  upstream:
+    backends: ["localhost:2222", "localhost:2223"]
+    dial_timeout: 5s
+    max_requests: 100
+    lb_policy: round_robin
+    health_uri: /health
+    header_down:
+      add:
+        Server: ["Production"]
+  routes:
+  - hosts: [example.com]
+    paths: [~:^/bar/\w+]
+    strip_prefix: /api
+    plugins:
+    - type: rate_limit
+      config:
+        key: '{query.id}'
+        rate: 10r/s
+    - type: canary
+      config:
+        key: '{query.id}'
+        type: int
+        whitelist: $ > 0 && $ <= 10
+        upstream: staging
+- name: staging
+  upstream:
+    backends: ["localhost:3333"]
+plugins:
+- type: request_body_var
+`
+
+func TestMarshal(t *testing.T) {
+	// A config changed through the store.
+	s := newTestStore(t)
+	setTimestamps(s.data, 1)
+	if _, err := s.CreatePlugin(context.Background(), "", "foo", &olaf.Plugin{
+		Type:   olaf.PluginTypeCanary,
+		Config: map[string]interface{}{"upstream": "staging", "key": "{query.id}"},
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	changed, _ := s.GetConfig(context.Background())
+
+	cases := []struct {
+		name     string
+		inData   func() *olaf.Data
+		wantText []string
+	}{
+		{
+			name: "parsed from a file",
+			inData: func() *olaf.Data {
+				data, err := Parse([]byte(testMarshalConfig))
+				if err != nil {
+					t.Fatalf("err: %v", err)
+				}
+				return data
+			},
+			wantText: []string{"priority: -.inf", "name: production_route_0_plugin_1\n"},
+		},
+		{
+			name:     "changed through the store",
+			inData:   func() *olaf.Data { return changed },
+			wantText: []string{"name: foo_plugin_0\n"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := c.inData()
+			out, err := Marshal(data)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			for _, text := range c.wantText {
+				if !strings.Contains(string(out), text) {
+					t.Fatalf("Output: got (%s), want (...%s...)", out, text)
+				}
+			}
+
+			// Save and load again.
+			parsed, err := Parse(out)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			got, err := Marshal(parsed)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !bytes.Equal(got, out) {
+				t.Fatalf("Output: got (%s), want (%s)", got, out)
+			}
+		})
+	}
+}
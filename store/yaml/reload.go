@@ -0,0 +1,95 @@
+package yaml
+
+import (
+	"reflect"
+
+	"github.com/RussellLuo/olaf"
+)
+
+// Reload loads the file again, and replaces the current config with the
+// loaded one, discarding the changes made since the last load. The changes
+// are then published to the watchers.
+//
+// If the file can not be loaded, or the loaded config has broken references,
+// the current config is kept, and the error is returned (and reported by
+// Check until the next successful load).
+func (s *Store) Reload() error {
+	data, err := s.load()
+	if err == nil {
+		err = olaf.CheckIntegrity(data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recordLoad(err)
+	if err != nil {
+		return err
+	}
+
+	keepUnchanged(s.data, data)
+	s.events.Publish(olaf.Diff(s.data, data)...)
+	s.data = data
+	return nil
+}
+
+// Loads returns the numbers of the successful and failed loads, including the
+// one on creation.
+func (s *Store) Loads() (successes, failures int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loads, s.loadFailures
+}
+
+func (s *Store) recordLoad(err error) {
+	s.loadErr = err
+	if err != nil {
+		s.loadFailures++
+		return
+	}
+	s.loads++
+}
+
+// keepUnchanged replaces the entities in data, which are the same as the
+// current ones except for the timestamps, with the current ones. Thus, only
+// the changed entities will be reported (see olaf.Diff).
+func keepUnchanged(current, data *olaf.Data) {
+	if current == nil {
+		return
+	}
+
+	for name, svc := range data.Services {
+		if old, ok := current.Services[name]; ok && sameService(old, svc) {
+			data.Services[name] = old
+		}
+	}
+	for name, r := range data.Routes {
+		if old, ok := current.Routes[name]; ok {
+			newR := *r
+			newR.CreatedAt, newR.UpdatedAt = old.CreatedAt, old.UpdatedAt
+			if reflect.DeepEqual(old, &newR) {
+				data.Routes[name] = old
+			}
+		}
+	}
+	for name, p := range data.Plugins {
+		if old, ok := current.Plugins[name]; ok {
+			newP := *p
+			newP.CreatedAt, newP.UpdatedAt = old.CreatedAt, old.UpdatedAt
+			if reflect.DeepEqual(old, &newP) {
+				data.Plugins[name] = old
+			}
+		}
+	}
+}
+
+func sameService(old, svc *olaf.Service) bool {
+	newSvc := *svc
+	newSvc.CreatedAt, newSvc.UpdatedAt = old.CreatedAt, old.UpdatedAt
+	if svc.Upstream != nil && old.Upstream != nil {
+		u := *svc.Upstream
+		u.CreatedAt, u.UpdatedAt = old.Upstream.CreatedAt, old.Upstream.UpdatedAt
+		newSvc.Upstream = &u
+	}
+	return reflect.DeepEqual(old, &newSvc)
+}
@@ -0,0 +1,218 @@
+package yaml
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/olaf"
+)
+
+func TestStore_Reload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		// Change the modification time, which is used as the timestamps.
+		mtime := time.Now().Add(time.Hour)
+		if err := os.Chtimes(filename, mtime, mtime); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	write(testConfig)
+	s := New(filename)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.Watch(ctx, -1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		name          string
+		inContent     string
+		wantErr       bool
+		wantEvents    []string
+		wantRoutes    []string
+		wantSuccesses int64
+		wantFailures  int64
+	}{
+		{
+			name:          "changed",
+			inContent:     strings.Replace(testConfig, "- /foo", "- /foo2", 1),
+			wantEvents:    []string{"update route foo"},
+			wantRoutes:    []string{"/foo2"},
+			wantSuccesses: 2,
+		},
+		{
+			name:          "invalid",
+			inContent:     "services: [",
+			wantErr:       true,
+			wantRoutes:    []string{"/foo2"},
+			wantSuccesses: 2,
+			wantFailures:  1,
+		},
+		{
+			name:          "broken references",
+			inContent:     "plugins:\n- type: canary\n  config:\n    upstream: nonexistent\n",
+			wantErr:       true,
+			wantRoutes:    []string{"/foo2"},
+			wantSuccesses: 2,
+			wantFailures:  2,
+		},
+		{
+			name:          "recovered",
+			inContent:     testConfig,
+			wantEvents:    []string{"update route foo"},
+			wantRoutes:    []string{"/foo"},
+			wantSuccesses: 3,
+			wantFailures:  2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			write(c.inContent)
+			err := s.Reload()
+			if (err != nil) != c.wantErr {
+				t.Fatalf("Err: got (%v), want error (%v)", err, c.wantErr)
+			}
+			if err := s.Check(ctx); (err != nil) != c.wantErr {
+				t.Fatalf("Check: got (%v), want error (%v)", err, c.wantErr)
+			}
+
+			var events []string
+			for range c.wantEvents {
+				e := <-ch
+				events = append(events, fmt.Sprintf("%s %s %s", e.Type, e.Kind, e.Name))
+			}
+			if !reflect.DeepEqual(events, c.wantEvents) {
+				t.Fatalf("Events: got (%v), want (%v)", events, c.wantEvents)
+			}
+
+			route, err := s.GetRoute(ctx, "", "foo")
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(route.Paths, c.wantRoutes) {
+				t.Fatalf("Paths: got (%v), want (%v)", route.Paths, c.wantRoutes)
+			}
+
+			successes, failures := s.Loads()
+			if successes != c.wantSuccesses || failures != c.wantFailures {
+				t.Fatalf("Loads: got (%d, %d), want (%d, %d)", successes, failures, c.wantSuccesses, c.wantFailures)
+			}
+		})
+	}
+
+	// No more events, since the unchanged entities are kept.
+	select {
+	case e := <-ch:
+		t.Fatalf("Event: got (%+v), want none", e)
+	default:
+	}
+}
+
+func TestStore_NotLoaded(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "missing.yaml"))
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "GetConfig",
+			call: func() error {
+				_, err := s.GetConfig(ctx)
+				return err
+			},
+		},
+		{
+			name: "ListServices",
+			call: func() error {
+				_, err := s.ListServices(ctx)
+				return err
+			},
+		},
+		{
+			name: "GetService",
+			call: func() error {
+				_, err := s.GetService(ctx, "production", "")
+				return err
+			},
+		},
+		{
+			name: "GetService by route",
+			call: func() error {
+				_, err := s.GetService(ctx, "", "foo")
+				return err
+			},
+		},
+		{
+			name: "ListRoutes",
+			call: func() error {
+				_, err := s.ListRoutes(ctx, "production")
+				return err
+			},
+		},
+		{
+			name: "GetRoute",
+			call: func() error {
+				_, err := s.GetRoute(ctx, "", "foo")
+				return err
+			},
+		},
+		{
+			name: "ListPlugins",
+			call: func() error {
+				_, err := s.ListPlugins(ctx, "", "")
+				return err
+			},
+		},
+		{
+			name: "GetPlugin",
+			call: func() error {
+				_, err := s.GetPlugin(ctx, "", "", "foo_plugin_0")
+				return err
+			},
+		},
+		{
+			name: "ListUpstreams",
+			call: func() error {
+				_, err := s.ListUpstreams(ctx)
+				return err
+			},
+		},
+		{
+			name: "GetUpstream",
+			call: func() error {
+				_, err := s.GetUpstream(ctx, "", "production")
+				return err
+			},
+		},
+		{
+			name: "CreateService",
+			call: func() error {
+				return s.CreateService(ctx, &olaf.Service{Name: "production"})
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.call()
+			if err == nil || !strings.HasPrefix(err.Error(), "no config loaded from ") {
+				t.Fatalf("Err: got (%v), want (no config loaded from ...)", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package yaml
+
+import (
+	"context"
+	"strings"
+
+	"github.com/RussellLuo/olaf"
+	"github.com/mitchellh/mapstructure"
+)
+
+// RenameService renames a service, as well as rewrites all the references
+// to it in a single transaction. The routes and plugins, whose names are
+// derived from the service name (see Parse), will also be renamed.
+func (s *Store) RenameService(ctx context.Context, serviceName, newName string) (changes []*olaf.Change, err error) {
+	err = s.transact(func(data *olaf.Data) (err error) {
+		changes, err = renameService(data, resolveName(data, olaf.KindService, serviceName), newName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// RenameRoute renames a route, as well as rewrites all the references to it
+// in a single transaction. The plugins, whose names are derived from the
+// route name (see Parse), will also be renamed.
+func (s *Store) RenameRoute(ctx context.Context, routeName, newName string) (changes []*olaf.Change, err error) {
+	err = s.transact(func(data *olaf.Data) (err error) {
+		changes, err = renameRoute(data, resolveName(data, olaf.KindRoute, routeName), newName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func renameService(data *olaf.Data, oldName, newName string) ([]*olaf.Change, error) {
+	svc, ok := data.Services[oldName]
+	if !ok {
+		return nil, olaf.NotFoundError(olaf.KindService, oldName)
+	}
+	if err := checkNewName(olaf.KindService, oldName, newName); err != nil {
+		return nil, err
+	}
+	if _, ok := data.Services[newName]; ok {
+		return nil, olaf.ExistsError(olaf.KindService, newName)
+	}
+
+	cs := newChangeSet()
+
+	newSvc := *svc
+	newSvc.Name = newName
+	newSvc.UpdatedAt = now()
+	delete(data.Services, oldName)
+	data.Services[newName] = &newSvc
+	cs.renamed(olaf.KindService, oldName, newName)
+
+	// Rename the routes and plugins with derived names.
+	for _, rn := range olaf.SortedNames(data.Routes) {
+		if data.Routes[rn].ServiceName != oldName {
+			continue
+		}
+		if newRN, ok := derivedName(rn, oldName+"_route_", newName+"_route_"); ok {
+			changes, err := renameRoute(data, rn, newRN)
+			if err != nil {
+				return nil, err
+			}
+			cs.merge(changes)
+		}
+	}
+	for _, pn := range olaf.SortedNames(data.Plugins) {
+		if data.Plugins[pn].ServiceName != oldName {
+			continue
+		}
+		if newPN, ok := derivedName(pn, oldName+"_plugin_", newName+"_plugin_"); ok {
+			if err := renamePlugin(data, pn, newPN); err != nil {
+				return nil, err
+			}
+			cs.renamed(olaf.KindPlugin, pn, newPN)
+		}
+	}
+
+	// Rewrite the references.
+	for _, rn := range olaf.SortedNames(data.Routes) {
+		r := data.Routes[rn]
+		if r.ServiceName == oldName {
+			newR := *r
+			newR.ServiceName = newName
+			newR.UpdatedAt = now()
+			data.Routes[rn] = &newR
+			cs.changed(olaf.KindRoute, rn, "service_name")
+		}
+	}
+	for _, pn := range olaf.SortedNames(data.Plugins) {
+		p := data.Plugins[pn]
+		if p.ServiceName == oldName {
+			newP := *p
+			newP.ServiceName = newName
+			newP.UpdatedAt = now()
+			data.Plugins[pn] = &newP
+			cs.changed(olaf.KindPlugin, pn, "service_name")
+		}
+		if olaf.CanaryUpstream(data.Plugins[pn]) == oldName || olaf.MirrorUpstream(data.Plugins[pn]) == oldName {
+			newP := *data.Plugins[pn]
+			newP.Config = make(map[string]interface{})
+			for k, v := range p.Config {
+				newP.Config[k] = v
+			}
+			newP.Config["upstream"] = newName
+			newP.UpdatedAt = now()
+			data.Plugins[pn] = &newP
+			cs.changed(olaf.KindPlugin, pn, "config.upstream")
+		}
+		if splits, ok := renameSplitServices(data.Plugins[pn], oldName, newName); ok {
+			newP := *data.Plugins[pn]
+			newP.Config = make(map[string]interface{})
+			for k, v := range p.Config {
+				newP.Config[k] = v
+			}
+			newP.Config["splits"] = splits
+			newP.UpdatedAt = now()
+			data.Plugins[pn] = &newP
+			cs.changed(olaf.KindPlugin, pn, "config.splits")
+		}
+	}
+
+	return cs.changes, nil
+}
+
+// renameSplitServices returns the splits of p, with the service oldName
+// renamed to newName, if p is a traffic_split plugin referring to oldName.
+func renameSplitServices(p *olaf.Plugin, oldName, newName string) (splits []interface{}, ok bool) {
+	for _, name := range olaf.TrafficSplitServices(p) {
+		if name == oldName {
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+
+	config := new(olaf.PluginTrafficSplitConfig)
+	if err := mapstructure.Decode(p.Config, config); err != nil {
+		return nil, false
+	}
+	for _, split := range config.Splits {
+		if split == nil {
+			continue
+		}
+		name := split.ServiceName
+		if name == oldName {
+			name = newName
+		}
+		splits = append(splits, map[string]interface{}{
+			"service": name,
+			"weight":  split.Weight,
+		})
+	}
+	return splits, true
+}
+
+func renameRoute(data *olaf.Data, oldName, newName string) ([]*olaf.Change, error) {
+	r, ok := data.Routes[oldName]
+	if !ok {
+		return nil, olaf.NotFoundError(olaf.KindRoute, oldName)
+	}
+	if err := checkNewName(olaf.KindRoute, oldName, newName); err != nil {
+		return nil, err
+	}
+	if _, ok := data.Routes[newName]; ok {
+		return nil, olaf.ExistsError(olaf.KindRoute, newName)
+	}
+
+	cs := newChangeSet()
+
+	newR := *r
+	newR.Name = newName
+	newR.UpdatedAt = now()
+	delete(data.Routes, oldName)
+	data.Routes[newName] = &newR
+	cs.renamed(olaf.KindRoute, oldName, newName)
+
+	for _, pn := range olaf.SortedNames(data.Plugins) {
+		p := data.Plugins[pn]
+		if p.RouteName != oldName {
+			continue
+		}
+
+		newP := *p
+		newP.RouteName = newName
+		newP.UpdatedAt = now()
+		data.Plugins[pn] = &newP
+
+		if newPN, ok := derivedName(pn, oldName+"_plugin_", newName+"_plugin_"); ok {
+			if err := renamePlugin(data, pn, newPN); err != nil {
+				return nil, err
+			}
+			cs.renamed(olaf.KindPlugin, pn, newPN)
+			pn = newPN
+		}
+		cs.changed(olaf.KindPlugin, pn, "route_name")
+	}
+
+	return cs.changes, nil
+}
+
+func renamePlugin(data *olaf.Data, oldName, newName string) error {
+	if _, ok := data.Plugins[newName]; ok {
+		return olaf.ExistsError(olaf.KindPlugin, newName)
+	}
+	p := *data.Plugins[oldName]
+	p.Name = newName
+	p.UpdatedAt = now()
+	delete(data.Plugins, oldName)
+	data.Plugins[newName] = &p
+	return nil
+}
+
+func checkNewName(kind, oldName, newName string) error {
+	switch {
+	case newName == "":
+		return olaf.InvalidField(kind, "new_name", "is required")
+	case newName == oldName:
+		return olaf.InvalidField(kind, "new_name", "is the same as the old one")
+	}
+	return nil
+}
+
+// derivedName reports whether name is in the form of `<oldPrefix><i>`, which
+// is generated by Parse. If so, the new name `<newPrefix><i>` is returned.
+func derivedName(name, oldPrefix, newPrefix string) (string, bool) {
+	if !strings.HasPrefix(name, oldPrefix) {
+		return "", false
+	}
+	index := strings.TrimPrefix(name, oldPrefix)
+	if index == "" || strings.Trim(index, "0123456789") != "" {
+		return "", false
+	}
+	return newPrefix + index, true
+}
+
+// changeSet collects the changes of entities, which are identified by their
+// latest names.
+type changeSet struct {
+	changes []*olaf.Change
+	index   map[olaf.EntityRef]*olaf.Change
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{index: make(map[olaf.EntityRef]*olaf.Change)}
+}
+
+func (cs *changeSet) get(kind, name string) *olaf.Change {
+	ref := olaf.EntityRef{Kind: kind, Name: name}
+	c, ok := cs.index[ref]
+	if !ok {
+		c = &olaf.Change{Kind: kind, Name: name}
+		cs.changes = append(cs.changes, c)
+		cs.index[ref] = c
+	}
+	return c
+}
+
+func (cs *changeSet) changed(kind, name string, fields ...string) {
+	c := cs.get(kind, name)
+	c.Fields = append(c.Fields, fields...)
+}
+
+func (cs *changeSet) renamed(kind, oldName, newName string) {
+	oldRef := olaf.EntityRef{Kind: kind, Name: oldName}
+	c, ok := cs.index[oldRef]
+	if ok {
+		delete(cs.index, oldRef)
+		c.Name = newName
+		cs.index[olaf.EntityRef{Kind: kind, Name: newName}] = c
+	} else {
+		c = cs.get(kind, newName)
+	}
+	if c.OldName == "" {
+		c.OldName = oldName
+	}
+	c.Fields = append(c.Fields, "name")
+}
+
+func (cs *changeSet) merge(changes []*olaf.Change) {
+	for _, change := range changes {
+		c := cs.get(change.Kind, change.Name)
+		if c.OldName == "" {
+			c.OldName = change.OldName
+		}
+		c.Fields = append(c.Fields, change.Fields...)
+	}
+}
@@ -0,0 +1,89 @@
+package yaml
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/RussellLuo/olaf"
+)
+
+func TestStore_RenameService(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.CreateRoute(ctx, "production", &olaf.Route{Name: "production_route_0"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	plugins := []*olaf.Plugin{
+		{Type: "rate_limit", RouteName: "production_route_0"},
+		{Type: "request_body_var", ServiceName: "production"},
+		{Name: "canary", Type: olaf.PluginTypeCanary, RouteName: "foo", Config: map[string]interface{}{"upstream": "production"}},
+		{Name: "shadow", Type: olaf.PluginTypeMirror, RouteName: "foo", Config: map[string]interface{}{"upstream": "production"}},
+		{Name: "split", Type: olaf.PluginTypeTrafficSplit, RouteName: "foo", Config: map[string]interface{}{
+			"splits": []interface{}{
+				map[string]interface{}{"service": "production", "weight": 90},
+				map[string]interface{}{"service": "staging", "weight": 10},
+			},
+		}},
+	}
+	for _, p := range plugins {
+		if _, err := s.CreatePlugin(ctx, "", "", p); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	changes, err := s.RenameService(ctx, "production", "prod")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	wantChanges := []*olaf.Change{
+		{Kind: olaf.KindService, Name: "prod", OldName: "production", Fields: []string{"name"}},
+		{Kind: olaf.KindRoute, Name: "prod_route_0", OldName: "production_route_0", Fields: []string{"name", "service_name"}},
+		{Kind: olaf.KindPlugin, Name: "prod_route_0_plugin_0", OldName: "production_route_0_plugin_0", Fields: []string{"name", "route_name"}},
+		{Kind: olaf.KindPlugin, Name: "prod_plugin_0", OldName: "production_plugin_0", Fields: []string{"name", "service_name"}},
+		{Kind: olaf.KindRoute, Name: "foo", Fields: []string{"service_name"}},
+		{Kind: olaf.KindPlugin, Name: "canary", Fields: []string{"config.upstream"}},
+		{Kind: olaf.KindPlugin, Name: "shadow", Fields: []string{"config.upstream"}},
+		{Kind: olaf.KindPlugin, Name: "split", Fields: []string{"config.splits"}},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		for _, c := range changes {
+			t.Logf("%+v", c)
+		}
+		t.Fatalf("Changes: got (%+v), want (%+v)", changes, wantChanges)
+	}
+
+	assertNames(t, "Services", olaf.SortedNames(s.data.Services), []string{"prod", "staging"})
+	assertNames(t, "Routes", olaf.SortedNames(s.data.Routes), []string{"foo", "prod_route_0"})
+	assertNames(t, "Plugins", olaf.SortedNames(s.data.Plugins), []string{"canary", "prod_plugin_0", "prod_route_0_plugin_0", "shadow", "split"})
+	if got := olaf.CanaryUpstream(s.data.Plugins["canary"]); got != "prod" {
+		t.Fatalf("Upstream: got (%q), want (%q)", got, "prod")
+	}
+	if got := olaf.MirrorUpstream(s.data.Plugins["shadow"]); got != "prod" {
+		t.Fatalf("Mirror: got (%q), want (%q)", got, "prod")
+	}
+	if got, want := olaf.TrafficSplitServices(s.data.Plugins["split"]), []string{"prod", "staging"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Splits: got (%v), want (%v)", got, want)
+	}
+}
+
+func TestStore_RenameRoute(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := s.RenameRoute(ctx, "foo", "production"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := s.RenameRoute(ctx, "unknown", "bar"); !errors.Is(err, olaf.ErrRouteNotFound) {
+		t.Fatalf("Err: got (%v), want (%v)", err, olaf.ErrRouteNotFound)
+	}
+	if _, err := s.RenameRoute(ctx, "production", ""); err == nil {
+		t.Fatal("Err: got (nil), want (non-nil)")
+	}
+
+	assertNames(t, "Routes", olaf.SortedNames(s.data.Routes), []string{"production"})
+}
@@ -2,24 +2,46 @@ package yaml
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"sort"
+	"sync"
 
 	"github.com/RussellLuo/olaf"
 	"gopkg.in/yaml.v3"
 )
 
+// Store is a store backed by a YAML file. The file is loaded on creation (and
+// on every reload, see Reload), and all changes are made in memory and are
+// not written back.
 type Store struct {
 	filename string
 
+	mu   sync.RWMutex
 	data *olaf.Data
+	// The error that occurred when the file was last loaded, if any.
+	loadErr error
+	// The numbers of the successful and failed loads.
+	loads, loadFailures int64
+
+	events *olaf.EventLog
 }
 
+// eventLogSize is the number of the most recent events kept for watchers to
+// resume from.
+const eventLogSize = 1000
+
 func New(filename string) *Store {
-	s := &Store{filename: filename}
+	s := &Store{
+		filename: filename,
+		events:   olaf.NewEventLog(eventLogSize),
+	}
 
-	data, err := s.GetConfig(context.Background())
+	data, err := s.load()
+	s.recordLoad(err)
 	if err != nil {
 		log.Printf("failed to get config: %v\n", err)
 		return s
@@ -29,7 +51,7 @@ func New(filename string) *Store {
 	return s
 }
 
-func (s *Store) GetConfig(ctx context.Context) (*olaf.Data, error) {
+func (s *Store) load() (*olaf.Data, error) {
 	c, err := ioutil.ReadFile(s.filename)
 	if err != nil {
 		return nil, err
@@ -40,14 +62,69 @@ func (s *Store) GetConfig(ctx context.Context) (*olaf.Data, error) {
 		return nil, err
 	}
 
+	// Entities without timestamps are considered to be created and updated
+	// at the time the file was last modified.
+	if fi, err := os.Stat(s.filename); err == nil {
+		setTimestamps(data, fi.ModTime().Unix())
+	}
+
 	return data, nil
 }
 
+// Revision implements olaf.Notifier.
+func (s *Store) Revision() int64 {
+	return s.events.Revision()
+}
+
+// Watch implements olaf.Notifier.
+func (s *Store) Watch(ctx context.Context, since int64) (<-chan *olaf.Event, error) {
+	return s.events.Watch(ctx, since)
+}
+
+// Check reports whether the file was successfully loaded the last time.
+func (s *Store) Check(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.loadErr != nil {
+		return fmt.Errorf("failed to load config from %s: %v", s.filename, s.loadErr)
+	}
+	return nil
+}
+
+func (s *Store) GetConfig(ctx context.Context) (*olaf.Data, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+	return s.data, nil
+}
+
+// loaded returns an error if no config has been loaded (i.e. the initial load
+// failed, and no reload has succeeded since). The caller must hold s.mu.
+func (s *Store) loaded() error {
+	if s.data == nil {
+		return fmt.Errorf("no config loaded from %s", s.filename)
+	}
+	return nil
+}
+
 func (s *Store) CreateService(ctx context.Context, svc *olaf.Service) (err error) {
-	return olaf.ErrMethodNotImplemented
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpCreate, Kind: olaf.KindService, Service: svc},
+	})
 }
 
 func (s *Store) ListServices(ctx context.Context) (services []*olaf.Service, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
 	for _, svc := range s.data.Services {
 		services = append(services, svc)
 	}
@@ -55,8 +132,15 @@ func (s *Store) ListServices(ctx context.Context) (services []*olaf.Service, err
 }
 
 func (s *Store) GetService(ctx context.Context, serviceName, routeName string) (*olaf.Service, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
 	if routeName != "" {
-		r, ok := s.data.Routes[routeName]
+		r, ok := lookupRoute(s.data, routeName)
 		if !ok {
 			return nil, olaf.ErrServiceNotFound
 		}
@@ -64,26 +148,51 @@ func (s *Store) GetService(ctx context.Context, serviceName, routeName string) (
 		serviceName = r.ServiceName
 	}
 
-	svc, ok := s.data.Services[serviceName]
+	svc, ok := lookupService(s.data, serviceName)
 	if !ok {
-		return nil, olaf.ErrServiceNotFound
+		return nil, olaf.NotFoundError(olaf.KindService, serviceName)
 	}
 	return svc, nil
 }
 
 func (s *Store) UpdateService(ctx context.Context, serviceName, routeName string, svc *olaf.Service) (err error) {
-	return olaf.ErrMethodNotImplemented
+	old, err := s.GetService(ctx, serviceName, routeName)
+	if err != nil {
+		return err
+	}
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpUpdate, Kind: olaf.KindService, Name: old.Name, Service: svc},
+	})
 }
 
-func (s *Store) DeleteService(ctx context.Context, serviceName, routeName string) (err error) {
-	return olaf.ErrMethodNotImplemented
+func (s *Store) DeleteService(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	old, err := s.GetService(ctx, serviceName, routeName)
+	if err != nil {
+		return err
+	}
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpDelete, Kind: olaf.KindService, Name: old.Name, Cascade: cascade},
+	})
 }
 
 func (s *Store) CreateRoute(ctx context.Context, serviceName string, route *olaf.Route) (err error) {
-	return olaf.ErrMethodNotImplemented
+	if serviceName != "" {
+		route.ServiceName = serviceName
+	}
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpCreate, Kind: olaf.KindRoute, Route: route},
+	})
 }
 
 func (s *Store) ListRoutes(ctx context.Context, serviceName string) (routes []*olaf.Route, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
+	serviceName = resolveName(s.data, olaf.KindService, serviceName)
 	for _, r := range s.data.Routes {
 		if serviceName != "" {
 			if r.ServiceName == serviceName {
@@ -97,26 +206,65 @@ func (s *Store) ListRoutes(ctx context.Context, serviceName string) (routes []*o
 }
 
 func (s *Store) GetRoute(ctx context.Context, serviceName, routeName string) (route *olaf.Route, err error) {
-	route, ok := s.data.Routes[routeName]
-	if !ok || (serviceName != "" && route.ServiceName != serviceName) {
-		return nil, olaf.ErrRouteNotFound
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
+	route, ok := lookupRoute(s.data, routeName)
+	if !ok || (serviceName != "" && route.ServiceName != resolveName(s.data, olaf.KindService, serviceName)) {
+		return nil, olaf.NotFoundError(olaf.KindRoute, routeName)
 	}
 	return route, nil
 }
 
 func (s *Store) UpdateRoute(ctx context.Context, serviceName, routeName string, route *olaf.Route) (err error) {
-	return olaf.ErrMethodNotImplemented
+	old, err := s.GetRoute(ctx, serviceName, routeName)
+	if err != nil {
+		return err
+	}
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpUpdate, Kind: olaf.KindRoute, Name: old.Name, Route: route},
+	})
 }
 
-func (s *Store) DeleteRoute(ctx context.Context, serviceName, routeName string) (err error) {
-	return olaf.ErrMethodNotImplemented
+func (s *Store) DeleteRoute(ctx context.Context, serviceName, routeName string, cascade bool) (err error) {
+	old, err := s.GetRoute(ctx, serviceName, routeName)
+	if err != nil {
+		return err
+	}
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpDelete, Kind: olaf.KindRoute, Name: old.Name, Cascade: cascade},
+	})
 }
 
 func (s *Store) CreatePlugin(ctx context.Context, serviceName, routeName string, p *olaf.Plugin) (plugin *olaf.Plugin, err error) {
-	return nil, olaf.ErrMethodNotImplemented
+	if serviceName != "" {
+		p.ServiceName = serviceName
+	}
+	if routeName != "" {
+		p.RouteName = routeName
+	}
+	if err := s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpCreate, Kind: olaf.KindPlugin, Plugin: p},
+	}); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
 func (s *Store) ListPlugins(ctx context.Context, serviceName, routeName string) (plugins []*olaf.Plugin, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
+	serviceName = resolveName(s.data, olaf.KindService, serviceName)
+	routeName = resolveName(s.data, olaf.KindRoute, routeName)
 	for _, p := range s.data.Plugins {
 		switch {
 		case serviceName != "":
@@ -135,42 +283,125 @@ func (s *Store) ListPlugins(ctx context.Context, serviceName, routeName string)
 }
 
 func (s *Store) GetPlugin(ctx context.Context, serviceName, routeName, pluginName string) (plugin *olaf.Plugin, err error) {
-	plugin, ok := s.data.Plugins[pluginName]
-	if !ok || (serviceName != "" && plugin.ServiceName != serviceName) || (routeName != "" && plugin.RouteName != routeName) {
-		return nil, olaf.ErrPluginNotFound
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
+	plugin, ok := lookupPlugin(s.data, pluginName)
+	if !ok ||
+		(serviceName != "" && plugin.ServiceName != resolveName(s.data, olaf.KindService, serviceName)) ||
+		(routeName != "" && plugin.RouteName != resolveName(s.data, olaf.KindRoute, routeName)) {
+		return nil, olaf.NotFoundError(olaf.KindPlugin, pluginName)
 	}
 	return plugin, nil
 }
 
 func (s *Store) UpdatePlugin(ctx context.Context, serviceName, routeName, pluginName string, plugin *olaf.Plugin) (err error) {
-	return olaf.ErrMethodNotImplemented
+	old, err := s.GetPlugin(ctx, serviceName, routeName, pluginName)
+	if err != nil {
+		return err
+	}
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpUpdate, Kind: olaf.KindPlugin, Name: old.Name, Plugin: plugin},
+	})
 }
 
 func (s *Store) DeletePlugin(ctx context.Context, serviceName, routeName, pluginName string) (err error) {
-	return olaf.ErrMethodNotImplemented
+	old, err := s.GetPlugin(ctx, serviceName, routeName, pluginName)
+	if err != nil {
+		return err
+	}
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpDelete, Kind: olaf.KindPlugin, Name: old.Name},
+	})
 }
 
 func (s *Store) ListUpstreams(ctx context.Context) (upstreams []*olaf.Upstream, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
 	for _, svc := range s.data.Services {
 		upstreams = append(upstreams, svc.Upstream)
 	}
 	return
 }
 
+// GetUpstream gets the upstream by its ID (i.e. upstreamName), or by the
+// name or ID of the service it belongs to.
 func (s *Store) GetUpstream(ctx context.Context, upstreamName, serviceName string) (upstream *olaf.Upstream, err error) {
+	svc, err := s.getUpstreamService(upstreamName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return svc.Upstream, nil
+}
+
+func (s *Store) UpdateUpstream(ctx context.Context, upstreamName, serviceName string, upstream *olaf.Upstream) (err error) {
+	old, err := s.getUpstreamService(upstreamName, serviceName)
+	if err != nil {
+		return err
+	}
+	svc := *old
+	svc.Upstream = upstream
+	return s.Batch(ctx, []*olaf.Operation{
+		{Op: olaf.OpUpdate, Kind: olaf.KindService, Name: svc.Name, Service: &svc},
+	})
+}
+
+func (s *Store) getUpstreamService(upstreamName, serviceName string) (*olaf.Service, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.loaded(); err != nil {
+		return nil, err
+	}
+
 	if upstreamName != "" {
-		return nil, olaf.ErrMethodNotImplemented
+		svc, ok := lookupUpstream(s.data, upstreamName)
+		if !ok {
+			return nil, olaf.NotFoundError(olaf.KindUpstream, upstreamName)
+		}
+		return svc, nil
 	}
 
-	svc, ok := s.data.Services[serviceName]
+	svc, ok := lookupService(s.data, serviceName)
 	if !ok {
 		return nil, olaf.ErrUpstreamNotFound
 	}
-	return svc.Upstream, nil
+	return svc, nil
 }
 
-func (s *Store) UpdateUpstream(ctx context.Context, upstreamName, serviceName string, upstream *olaf.Upstream) (err error) {
-	return olaf.ErrMethodNotImplemented
+// setTimestamps sets the timestamps, which are not specified, of all the
+// entities to t.
+func setTimestamps(data *olaf.Data, t int64) {
+	stamp := func(createdAt, updatedAt *int64) {
+		if *createdAt == 0 {
+			*createdAt = t
+		}
+		if *updatedAt == 0 {
+			*updatedAt = *createdAt
+		}
+	}
+
+	for _, svc := range data.Services {
+		stamp(&svc.CreatedAt, &svc.UpdatedAt)
+		if svc.Upstream != nil {
+			stamp(&svc.Upstream.CreatedAt, &svc.Upstream.UpdatedAt)
+		}
+	}
+	for _, r := range data.Routes {
+		stamp(&r.CreatedAt, &r.UpdatedAt)
+	}
+	for _, p := range data.Plugins {
+		stamp(&p.CreatedAt, &p.UpdatedAt)
+	}
 }
 
 // Parse recognizes and parses the YAML content.
@@ -185,10 +416,22 @@ func Parse(in []byte) (*olaf.Data, error) {
 		Routes:   make(map[string]*olaf.Route),
 		Plugins:  make(map[string]*olaf.Plugin),
 	}
+	ids := make(contentIDs)
 
 	for i, s := range c.Services { // global services
+		// The key for deriving the upstream ID, which is the service ID if
+		// the service is unnamed.
+		upstreamKey := s.Name
+		if s.ID == "" {
+			if s.Name == "" {
+				s.ID = ids.get(olaf.KindService, s.Upstream, s.Tags)
+			} else {
+				s.ID = olaf.NameID(olaf.KindService, s.Name)
+			}
+		}
 		if s.Name == "" {
 			s.Name = fmt.Sprintf("service_%d", i)
+			upstreamKey = s.ID
 		}
 
 		var u *olaf.Upstream
@@ -201,10 +444,13 @@ func Parse(in []byte) (*olaf.Data, error) {
 				})
 			}
 			u = &olaf.Upstream{
+				ID:         s.Upstream.ID,
 				Backends:   backends,
 				HTTP:       &olaf.TransportHTTP{DialTimeout: s.Upstream.DialTimeout},
 				HeaderUp:   s.Upstream.HeaderUp,
 				HeaderDown: s.Upstream.HeaderDown,
+				CreatedAt:  s.Upstream.CreatedAt,
+				UpdatedAt:  s.Upstream.UpdatedAt,
 			}
 			if s.Upstream.LBPolicy != "" || s.Upstream.LBTryDuration != "" || s.Upstream.LBTryInterval != "" {
 				u.LoadBalancing = &olaf.LoadBalancing{
@@ -224,12 +470,26 @@ func Parse(in []byte) (*olaf.Data, error) {
 			}
 		}
 
+		if u != nil && u.ID == "" {
+			u.ID = olaf.NameID(olaf.KindUpstream, upstreamKey)
+		}
 		data.Services[s.Name] = &olaf.Service{
-			Name:     s.Name,
-			Upstream: u,
+			ID:        s.ID,
+			Name:      s.Name,
+			Upstream:  u,
+			Tags:      s.Tags,
+			CreatedAt: s.CreatedAt,
+			UpdatedAt: s.UpdatedAt,
 		}
 
 		for j, r := range s.Routes { // routes associated to a service
+			if r.Route.ID == "" {
+				if r.Route.Name == "" {
+					r.Route.ID = ids.get(olaf.KindRoute, s.ID, canonicalMatcher(r.Route.Matcher))
+				} else {
+					r.Route.ID = olaf.NameID(olaf.KindRoute, r.Route.Name)
+				}
+			}
 			if r.Route.Name == "" {
 				r.Route.Name = fmt.Sprintf("%s_route_%d", s.Name, j)
 			}
@@ -237,12 +497,18 @@ func Parse(in []byte) (*olaf.Data, error) {
 			data.Routes[r.Route.Name] = r.Route
 
 			for k, p := range r.Plugins { // plugins applied to a route
+				if p.ID == "" && p.Name == "" {
+					p.ID = ids.get(olaf.KindPlugin, r.Route.ID, p.Type, p.Config)
+				}
 				if p.Name == "" {
 					p.Name = fmt.Sprintf("%s_plugin_%d", r.Route.Name, k)
 				}
 				if p.OrderAfter == "" && k > 0 {
 					p.OrderAfter = r.Plugins[k-1].Type
 				}
+				if p.ID == "" {
+					p.ID = olaf.NameID(olaf.KindPlugin, p.Name)
+				}
 				p.ServiceName = s.Name
 				p.RouteName = r.Route.Name
 				data.Plugins[p.Name] = p
@@ -250,32 +516,86 @@ func Parse(in []byte) (*olaf.Data, error) {
 		}
 
 		for j, p := range s.Plugins { // plugins applied to a service
+			if p.ID == "" && p.Name == "" {
+				p.ID = ids.get(olaf.KindPlugin, s.ID, p.Type, p.Config)
+			}
 			if p.Name == "" {
 				p.Name = fmt.Sprintf("%s_plugin_%d", s.Name, j)
 			}
 			if p.OrderAfter == "" && j > 0 {
 				p.OrderAfter = s.Plugins[j-1].Type
 			}
+			if p.ID == "" {
+				p.ID = olaf.NameID(olaf.KindPlugin, p.Name)
+			}
 			p.ServiceName = s.Name
 			data.Plugins[p.Name] = p
 		}
 	}
 
 	for i, p := range c.Plugins { // global plugins
+		if p.ID == "" && p.Name == "" {
+			p.ID = ids.get(olaf.KindPlugin, "", p.Type, p.Config)
+		}
 		if p.Name == "" {
 			p.Name = fmt.Sprintf("plugin_%d", i)
 		}
 		if p.OrderAfter == "" && i > 0 {
 			p.OrderAfter = c.Plugins[i-1].Type
 		}
+		if p.ID == "" {
+			p.ID = olaf.NameID(olaf.KindPlugin, p.Name)
+		}
 		data.Plugins[p.Name] = p
 	}
 
 	return data, nil
 }
 
+// contentIDs generates the IDs of the unnamed entities from their contents,
+// rather than from their default names, which depend on their positions in
+// the file. Thus, adding or removing an entity does not change the IDs of
+// its siblings.
+type contentIDs map[string]int
+
+// get returns the ID for the entity of the given kind and contents. Entities
+// with the same contents are told apart by the order of their occurrences.
+func (ids contentIDs) get(kind string, contents ...interface{}) string {
+	b, _ := json.Marshal(contents)
+	key := string(b)
+	if n := ids[kind+":"+key]; n > 0 {
+		key = fmt.Sprintf("%s#%d", key, n)
+	}
+	ids[kind+":"+string(b)]++
+	return olaf.NameID(kind, key)
+}
+
+// canonicalMatcher returns a copy of m, whose values are sorted, so that the
+// order of them makes no difference.
+func canonicalMatcher(m olaf.Matcher) olaf.Matcher {
+	sorted := func(values []string) []string {
+		values = append([]string(nil), values...)
+		sort.Strings(values)
+		return values
+	}
+	c := olaf.Matcher{
+		Protocol: m.Protocol,
+		Methods:  sorted(m.Methods),
+		Hosts:    sorted(m.Hosts),
+		Paths:    sorted(m.Paths),
+	}
+	if len(m.Headers) > 0 {
+		c.Headers = make(map[string][]string, len(m.Headers))
+		for k, v := range m.Headers {
+			c.Headers[k] = sorted(v)
+		}
+	}
+	return c
+}
+
 type (
 	upstream struct {
+		ID          string   `yaml:"id"`
 		Backends    []string `yaml:"backends"`
 		MaxRequests int      `yaml:"max_requests"`
 		DialTimeout string   `yaml:"dial_timeout"`
@@ -292,14 +612,22 @@ type (
 
 		HeaderUp   *olaf.HeaderOps `yaml:"header_up"`
 		HeaderDown *olaf.HeaderOps `yaml:"header_down"`
+
+		CreatedAt int64 `yaml:"created_at"`
+		UpdatedAt int64 `yaml:"updated_at"`
 	}
 
 	service struct {
+		ID       string    `yaml:"id"`
 		Name     string    `yaml:"name"`
 		Upstream *upstream `yaml:"upstream"`
+		Tags     []string  `yaml:"tags"`
 
 		Routes  []*route       `yaml:"routes"`
 		Plugins []*olaf.Plugin `yaml:"plugins"`
+
+		CreatedAt int64 `yaml:"created_at"`
+		UpdatedAt int64 `yaml:"updated_at"`
 	}
 
 	route struct {